@@ -0,0 +1,257 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	rundown "github.com/elseano/rundown/pkg"
+	"github.com/elseano/rundown/pkg/ast"
+	"github.com/elseano/rundown/pkg/exec"
+	"github.com/elseano/rundown/pkg/util"
+	"github.com/manifoldco/promptui"
+	"github.com/mattn/go-runewidth"
+	goldast "github.com/yuin/goldmark/ast"
+	"golang.org/x/exp/maps"
+)
+
+// Maximum number of lines of the section's long description shown in the preview pane.
+const previewLines = 8
+
+var ErrNoSections = errors.New("no sections in document")
+
+type sectionDisplayItem struct {
+	Name        string
+	Code        string
+	Description string
+	Indent      string
+	Preview     string
+	Section     *rundown.Section
+}
+
+// Presents a menu of all the sections in the document, and returns the one chosen.
+// Returns nil when the user chooses to exit.
+func AskSection(loaded *rundown.LoadedDocuments) (*rundown.Section, error) {
+	items := []sectionDisplayItem{
+		{
+			Code:        "exit",
+			Name:        "Exit",
+			Description: "Exit & Quit Rundown",
+		},
+	}
+
+	for _, section := range loaded.GetSections() {
+		if section.Pointer.Silent {
+			continue
+		}
+
+		level := 1
+		if heading, ok := section.Pointer.StartNode.(*goldast.Heading); ok {
+			level = heading.Level
+		}
+
+		indent := strings.Repeat("  ", level-1)
+		if level > 1 {
+			indent = indent + "- "
+		}
+
+		items = append(items, sectionDisplayItem{
+			Code:        section.Pointer.SectionName,
+			Name:        section.Pointer.DescriptionShort,
+			Description: section.Pointer.DescriptionShort,
+			Indent:      indent,
+			Preview:     renderPreview(section),
+			Section:     section,
+		})
+	}
+
+	if len(items) == 1 {
+		return nil, ErrNoSections
+	}
+
+	templates := &promptui.SelectTemplates{
+		Label:    "{{ . | bold }}",
+		Active:   "{{ \">\" | yellow }} {{ .Indent | faint }}{{ .Name | bold | cyan }} ({{ .Code }})",
+		Inactive: "  {{ .Indent | faint }}{{ .Name | cyan }} ({{ .Code | faint }})",
+		Selected: "{{ \">\" | blue }} {{ .Name | blue }}",
+		Details:  "\n{{ .Preview }}",
+	}
+
+	searcher := func(input string, index int) bool {
+		item := items[index]
+		return fuzzyMatch(input, item.Name) || fuzzyMatch(input, item.Code)
+	}
+
+	stdin := exec.NewStdinReader()
+
+	prompt := promptui.Select{
+		Label:             "What to run",
+		Items:             items,
+		Templates:         templates,
+		Size:              10,
+		Searcher:          searcher,
+		StartInSearchMode: true,
+		Stdin:             stdin.Claim(),
+	}
+
+	i, _, err := prompt.Run()
+
+	stdin.Stop()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if i == 0 {
+		return nil, nil
+	}
+
+	return items[i].Section, nil
+}
+
+// Asks for a value for each of the section's options, returning them keyed by the option's environment name.
+func AskOptions(section *rundown.Section) (map[string]string, error) {
+	result := map[string]string{}
+
+	for _, opt := range section.Pointer.Options {
+		value, err := askOption(opt)
+		if err != nil {
+			return nil, err
+		}
+
+		result[opt.OptionAs] = value
+	}
+
+	return result, nil
+}
+
+func askOption(opt *ast.SectionOption) (string, error) {
+	label := opt.OptionName
+	if opt.OptionPrompt.Valid && opt.OptionPrompt.String != "" {
+		label = opt.OptionPrompt.String
+	} else if opt.OptionDescription != "" {
+		label = fmt.Sprintf("%s - %s", opt.OptionName, opt.OptionDescription)
+	}
+
+	stdin := exec.NewStdinReader()
+	defer stdin.Stop()
+
+	// Option types with a fixed set of values are presented as a menu.
+	var choices []string
+
+	switch t := opt.OptionType.(type) {
+	case *ast.TypeBoolean:
+		choices = []string{"true", "false"}
+	case *ast.TypeEnum:
+		choices = t.ValidValues
+	case *ast.TypeKV:
+		choices = maps.Keys(t.Pairs)
+	case *ast.TypeEnumFrom:
+		values, err := t.Values()
+		if err != nil {
+			return "", err
+		}
+
+		// With nothing to pick from, fall back to typing the value.
+		if len(values) > 0 {
+			choices = values
+		}
+	}
+
+	if choices != nil {
+		cursor := 0
+		for i, c := range choices {
+			if opt.OptionDefault.Valid && c == opt.OptionDefault.String {
+				cursor = i
+			}
+		}
+
+		selector := promptui.Select{
+			Label:     label,
+			Items:     choices,
+			CursorPos: cursor,
+			Stdin:     stdin.Claim(),
+		}
+
+		_, value, err := selector.Run()
+		return value, err
+	}
+
+	prompt := promptui.Prompt{
+		Label:     label,
+		Default:   opt.OptionDefault.String,
+		AllowEdit: true,
+		Stdin:     stdin.Claim(),
+		Validate: func(input string) error {
+			if input == "" {
+				if opt.OptionRequired {
+					return errors.New("a value is required")
+				}
+
+				return nil
+			}
+
+			return opt.OptionType.Validate(opt.OptionType.Normalise(input))
+		},
+	}
+
+	return prompt.Run()
+}
+
+// Renders the section's long description for the preview pane, limited to previewLines.
+func renderPreview(section *rundown.Section) string {
+	if section.Pointer.DescriptionLong == nil {
+		return ""
+	}
+
+	str := strings.Builder{}
+	section.Document.Goldmark.Renderer().Render(&str, section.Document.Source, section.Pointer.DescriptionLong)
+
+	lines := strings.Split(strings.TrimSpace(str.String()), "\n")
+	if len(lines) > previewLines {
+		lines = append(lines[0:previewLines], "...")
+	}
+
+	width := util.GetConsoleWidth()
+	for i, line := range lines {
+		lines[i] = fitPreviewLine(line, width)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Cuts the line down to the width of the console. Lines which are cut lose their colours, as cutting them
+// could leave an escape sequence open.
+func fitPreviewLine(line string, width int) string {
+	plain := util.RemoveColors(line)
+	if runewidth.StringWidth(plain) <= width {
+		return line
+	}
+
+	return runewidth.Truncate(plain, width, "")
+}
+
+// Returns true when all the characters of input appear in candidate in order, ignoring case and spaces.
+func fuzzyMatch(input string, candidate string) bool {
+	needle := []rune(strings.ToLower(strings.ReplaceAll(input, " ", "")))
+	if len(needle) == 0 {
+		return true
+	}
+
+	pos := 0
+	for _, r := range strings.ToLower(candidate) {
+		if unicode.IsSpace(r) {
+			continue
+		}
+
+		if r == needle[pos] {
+			pos++
+			if pos == len(needle) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
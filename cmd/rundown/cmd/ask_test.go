@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFitPreviewLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		width    int
+		expected string
+	}{
+		{"fits", "Deploy the app", 20, "Deploy the app"},
+		{"keeps colours when it fits", "\x1b[1mDeploy\x1b[0m", 10, "\x1b[1mDeploy\x1b[0m"},
+		{"cut", "Deploy the app", 6, "Deploy"},
+		{"cut without colours", "\x1b[1mDeploy the app\x1b[0m", 6, "Deploy"},
+		{"multi-byte characters", "Déployer l'app", 4, "Dépl"},
+		{"wide characters", "部署应用程序", 5, "部署"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, fitPreviewLine(test.line, test.width))
+		})
+	}
+}
+
+func TestFuzzyMatch(t *testing.T) {
+	assert.True(t, fuzzyMatch("", "Deploy"))
+	assert.True(t, fuzzyMatch("dpl", "Deploy"))
+	assert.True(t, fuzzyMatch("deploy app", "Deploy the app"))
+	assert.True(t, fuzzyMatch("DEP", "deploy"))
+	assert.False(t, fuzzyMatch("ypd", "Deploy"))
+	assert.False(t, fuzzyMatch("deploys", "Deploy"))
+}
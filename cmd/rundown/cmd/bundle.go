@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/elseano/rundown/pkg/bundle"
+	"github.com/spf13/cobra"
+)
+
+// The name of the built-in command bundling a document into a tool. A section with the same name takes precedence.
+const bundleCommandName = "bundle"
+
+// Creates the bundle command, which writes a copy of rundown with the document and its imports built in.
+func NewBundleCmd() *cobra.Command {
+	var output string
+	var name string
+
+	command := &cobra.Command{
+		Use:           bundleCommandName + " [file]",
+		Short:         "Write a copy of rundown which runs this file, for distributing as a standalone tool",
+		Args:          cobra.MaximumNArgs(1),
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filename := rundownFile
+			if len(args) > 0 {
+				filename = args[0]
+			}
+
+			if filename == "" {
+				return errors.New("no RUNDOWN.md file found in current path or parents, so give the file to bundle")
+			}
+
+			filename, err := filepath.Abs(filename)
+			if err != nil {
+				return err
+			}
+
+			files, err := bundle.DocumentFiles(filename)
+			if err != nil {
+				return err
+			}
+
+			if name == "" {
+				name = strings.TrimSuffix(filepath.Base(output), filepath.Ext(output))
+			}
+
+			executable, err := os.Executable()
+			if err != nil {
+				return err
+			}
+
+			file, err := os.OpenFile(output, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
+			if err != nil {
+				return err
+			}
+
+			defer file.Close()
+
+			manifest := bundle.Manifest{Name: name, Main: filepath.Base(filename)}
+			return bundle.Write(file, executable, manifest, files)
+		},
+	}
+
+	command.Flags().StringVarP(&output, "output", "o", "", "The file to write the tool to")
+	command.Flags().StringVar(&name, "name", "", "The name the tool is run as (defaults to the output's name)")
+	command.MarkFlagRequired("output")
+
+	return command
+}
+
+// Returns true if the arguments run the bundle command, which doesn't need a document in the current directory.
+func isBundleCommand(args []string) bool {
+	for _, arg := range args[1:] {
+		if !strings.HasPrefix(arg, "-") {
+			return arg == bundleCommandName
+		}
+	}
+
+	return false
+}
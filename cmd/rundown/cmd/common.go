@@ -1,5 +1,7 @@
 package cmd
 
+import "github.com/elseano/rundown/pkg/bundle"
+
 var (
 	flagCodes          bool
 	flagDebug          bool
@@ -12,6 +14,11 @@ var (
 	flagFilename       string
 	flagCompletions    string
 	flagNonInteractive bool
+	flagYes            bool
+	flagKeepWorkspace  bool
+	flagParallel       bool
+	flagKeepGoing      bool
+	flagShareEnv       bool
 
 	flagViewOnly  bool
 	flagCheckOnly bool
@@ -19,4 +26,7 @@ var (
 
 	rundownFile   string
 	argShortcodes = []string{}
+
+	// Set when running a tool made by the bundle command.
+	activeBundle *bundle.Bundle
 )
@@ -1,40 +1,41 @@
 package cmd
 
-// func SetupDefaultCommand(defaultCommand string) {
-// 	commands := nonRootSubCmds()
-// 	fmt.Printf("Commands: %#v\n", commands)
-
-// 	givenCommand := ""
-// 	if len(os.Args) > 1 {
-// 		givenCommand = os.Args[1]
-// 	}
-
-// 	for _, v := range commands {
-// 		if givenCommand == v {
-// 			fmt.Printf("DC %s is %s\n", defaultCommand, v)
-// 			return
-// 		}
-// 	}
-
-// 	fmt.Println("Adding default")
-// 	os.Args = append([]string{os.Args[0], defaultCommand}, os.Args[1:]...)
-// }
-
-// func nonRootSubCmds() (l []string) {
-// l = append(l, "help")
-// 	for _, c := range rootCmd.Commands() {
-// 		// isAlias := false
-// 		// append(c.Aliases, c.Name())
-// 		// for _, a := range append(c.Aliases, c.Name()) {
-// 		// 	if a == rootCmd.Aliases[0] {
-// 		// 		isAlias = true
-// 		// 		break
-// 		// 	}
-// 		// }
-// 		// if !isAlias {
-// 		l = append(l, c.Name())
-// 		l = append(l, c.Aliases...)
-// 		// }
-// 	}
-// 	return
-// }
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Flags which mean the root command itself was requested, so the default section shouldn't be run.
+var rootOnlyFlags = []string{"-h", "--help", "--version", "--completions", "--serve", "--dump", "--ask", "--ask-repeat"}
+
+// Prepends the default section to the arguments, unless a command has already been given.
+// Options following the default section (i.e. --env prod) are passed through to it.
+func SetupDefaultCommand(root *cobra.Command, defaultCommand string, args []string) []string {
+	if defaultCommand == "" {
+		return args
+	}
+
+	if hasRootOnlyFlag(args) {
+		return args
+	}
+
+	// Find reports an error for unknown commands, which we leave for cobra to report.
+	if found, _, err := root.Find(args); err != nil || found != root {
+		return args
+	}
+
+	return append([]string{defaultCommand}, args...)
+}
+
+func hasRootOnlyFlag(args []string) bool {
+	for _, arg := range args {
+		for _, flag := range rootOnlyFlags {
+			if arg == flag || strings.HasPrefix(arg, flag+"=") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+
+	rundown "github.com/elseano/rundown/pkg"
+	"github.com/elseano/rundown/pkg/ports"
+	"github.com/spf13/cobra"
+)
+
+// The name of the built-in command generating reference docs. A section with the same name takes precedence.
+const docsCommandName = "docs"
+
+// Creates the docs command, which writes a reference of the document's commands as a man page, markdown or HTML.
+func NewDocsCmd(loaded *rundown.LoadedDocuments) *cobra.Command {
+	var format string
+	var name string
+
+	command := &cobra.Command{
+		Use:           docsCommandName,
+		Short:         "Write a reference of this file's commands, as " + strings.Join(ports.DocsFormats, ", "),
+		Args:          cobra.NoArgs,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return ports.WriteDocs(os.Stdout, loaded, name, format)
+		},
+	}
+
+	command.Flags().StringVar(&format, "format", "markdown", "The format to write, one of: "+strings.Join(ports.DocsFormats, ", "))
+	command.Flags().StringVar(&name, "name", programName(), "The name the commands are run with")
+
+	command.RegisterFlagCompletionFunc("format", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return ports.DocsFormats, cobra.ShellCompDirectiveNoFileComp
+	})
+
+	return command
+}
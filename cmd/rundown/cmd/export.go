@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	rundown "github.com/elseano/rundown/pkg"
+	"github.com/elseano/rundown/pkg/ports"
+	"github.com/spf13/cobra"
+)
+
+// The name of the built-in command exporting a section as a script. A section with the same name takes precedence.
+const exportCommandName = "export"
+
+// Creates the export command, which writes a section as a standalone script.
+func NewExportCmd(loaded *rundown.LoadedDocuments) *cobra.Command {
+	var format string
+	var output string
+
+	command := &cobra.Command{
+		Use:           exportCommandName + " <section>",
+		Short:         "Write a section as a standalone script, for running without rundown",
+		Args:          cobra.ExactArgs(1),
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var section *rundown.Section
+			for _, s := range loaded.GetSections() {
+				if s.Pointer.SectionName == args[0] {
+					section = s
+				}
+			}
+
+			if section == nil {
+				return fmt.Errorf("unknown section %q", args[0])
+			}
+
+			var w io.Writer = os.Stdout
+			if output != "" {
+				file, err := os.OpenFile(output, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
+				if err != nil {
+					return err
+				}
+
+				defer file.Close()
+				w = file
+			}
+
+			return ports.ExportSection(w, section, format)
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) > 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+
+			names := []string{}
+			for _, s := range loaded.GetSections() {
+				names = append(names, s.Pointer.SectionName)
+			}
+
+			return names, cobra.ShellCompDirectiveNoFileComp
+		},
+	}
+
+	command.Flags().StringVar(&format, "format", "bash", "The format to write, one of: "+strings.Join(ports.ExportFormats, ", "))
+	command.Flags().StringVarP(&output, "output", "o", "", "Write the script to this file, rather than stdout")
+
+	command.RegisterFlagCompletionFunc("format", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return ports.ExportFormats, cobra.ShellCompDirectiveNoFileComp
+	})
+
+	return command
+}
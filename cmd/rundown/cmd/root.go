@@ -4,19 +4,32 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	shared "github.com/elseano/rundown/cmd"
 	rundown "github.com/elseano/rundown/pkg"
 	"github.com/elseano/rundown/pkg/ast"
+	"github.com/elseano/rundown/pkg/bundle"
+	"github.com/elseano/rundown/pkg/errs"
 	"github.com/elseano/rundown/pkg/ports"
 	"github.com/elseano/rundown/pkg/util"
+	"github.com/manifoldco/promptui"
+	"github.com/mattn/go-isatty"
 	"github.com/muesli/reflow/indent"
 	"github.com/spf13/cobra"
 )
 
 func Execute(version string, gitCommit string) error {
-	cmd := NewDocRootCmd(os.Args)
+	rundown.Version = version
+
+	if executable, err := os.Executable(); err == nil {
+		if activeBundle, err = bundle.Read(executable); err != nil {
+			return err
+		}
+	}
+
+	cmd := NewDocRootCmd(ShebangArgs(os.Args))
 	cmd.Version = version
 	if gitCommit != "" {
 		cmd.Version += " (" + gitCommit + ")"
@@ -43,8 +56,15 @@ func NewDocRootCmd(args []string) *cobra.Command {
 	docRoot.ParseFlags(args)
 	docRoot.Root().CompletionOptions.DisableDefaultCmd = true
 
-	rundownFile = shared.RundownFile(flagFilename)
+	rundownFile = findRundownFile()
 	if rundownFile == "" {
+		if isBundleCommand(args) {
+			docRoot.AddCommand(NewBundleCmd())
+			docRoot.SetArgs(args[1:])
+
+			return docRoot
+		}
+
 		if flagCompletions == "" {
 			fmt.Fprintf(os.Stderr, "Error: No RUNDOWN.md file found in current path or parents.\n\n")
 			os.Exit(1)
@@ -53,7 +73,7 @@ func NewDocRootCmd(args []string) *cobra.Command {
 		}
 	}
 
-	loaded, err := rundown.Load(rundownFile)
+	loaded, err := loadRundown()
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			if flagFilename != "" {
@@ -67,44 +87,76 @@ func NewDocRootCmd(args []string) *cobra.Command {
 		}
 	}
 
+	defaultSection := flagDefault
+	sections := map[string]*rundown.Section{}
+
 	if loaded != nil {
 		for _, section := range loaded.GetSections() {
 			if !section.Pointer.Silent {
 				cmd := ports.BuildCobraCommand(rundownFile, section, flagDebug)
 				if cmd != nil {
 					docRoot.AddCommand(cmd)
+					sections[section.Pointer.SectionName] = section
 				}
 			}
 		}
-	}
 
-	return docRoot
-}
+		if found, _, err := docRoot.Find([]string{docsCommandName}); err != nil || found == docRoot {
+			docRoot.AddCommand(NewDocsCmd(loaded))
+		}
 
-func NewRootCmd() *cobra.Command {
+		if found, _, err := docRoot.Find([]string{exportCommandName}); err != nil || found == docRoot {
+			docRoot.AddCommand(NewExportCmd(loaded))
+		}
 
-	rundownFile = shared.RundownFile(flagFilename)
+		if found, _, err := docRoot.Find([]string{bundleCommandName}); activeBundle == nil && (err != nil || found == docRoot) {
+			docRoot.AddCommand(NewBundleCmd())
+		}
 
-	doc, err := rundown.Load(rundownFile)
-	longDesc := ""
+		if defaultSection == "" {
+			defaultSection = ast.GetDefaultSection(loaded.MasterDocument.Document)
+		}
 
-	if err == nil {
-		if help := ast.GetRootHelp(doc.MasterDocument.Document); help != nil {
+		// The file is only known once the flags are parsed, such as when it's run through a shebang.
+		if help := ast.GetRootHelp(loaded.MasterDocument.Document); help != nil {
 			str := strings.Builder{}
 			writer := indent.NewWriterPipe(&str, 2, nil)
-			doc.MasterDocument.Goldmark.Renderer().Render(writer, doc.MasterDocument.Source, help)
-			longDesc = "\n\n" + str.String()
+			loaded.MasterDocument.Goldmark.Renderer().Render(writer, loaded.MasterDocument.Source, help)
+
+			if activeBundle != nil {
+				docRoot.Long = str.String()
+			} else {
+				docRoot.Long += "\n\n" + str.String()
+			}
+		}
+	}
+
+	if len(args) > 0 && !hasRootOnlyFlag(args[1:]) {
+		// Several sections are run in one go, such as `rundown build test`.
+		if groups := SplitSectionArgs(docRoot, sections, args[1:]); len(groups) > 1 {
+			multiCmd := NewRunSectionsCmd(docRoot, sections, groups)
+			multiCmd.SetArgs([]string{})
+
+			return multiCmd
 		}
 	}
 
+	if len(args) > 0 {
+		docRoot.SetArgs(SetupDefaultCommand(docRoot, defaultSection, args[1:]))
+	}
+
+	return docRoot
+}
+
+func NewRootCmd() *cobra.Command {
 	rootCmd := &cobra.Command{
-		Use:           "rundown [command] [flags]...",
+		Use:           programName() + " [command] [flags]...",
 		Short:         "Execute a markdown file",
-		Long:          "Rundown turns Markdown files into console scripts." + longDesc,
+		Long:          "Rundown turns Markdown files into console scripts.",
 		SilenceUsage:  true,
 		SilenceErrors: false,
 		PreRun: func(cmd *cobra.Command, args []string) {
-			rundownFile = shared.RundownFile(flagFilename)
+			rundownFile = findRundownFile()
 			if flagDebug {
 				devNull, _ := os.Create("rundown.log")
 				util.RedirectLogger(devNull)
@@ -137,11 +189,40 @@ func NewRootCmd() *cobra.Command {
 			}
 
 			if flagDump {
-				doc, _ := rundown.Load(rundownFile)
+				doc, _ := loadRundown()
 				doc.MasterDocument.Document.Dump(doc.MasterDocument.Source, 0)
+				return nil
 			}
 
-			return nil
+			loaded, err := loadRundown()
+			if err != nil {
+				return err
+			}
+
+			loaded.MasterDocument.Context.AssumeYes = flagYes
+			loaded.MasterDocument.Context.KeepWorkspace = flagKeepWorkspace
+
+			// Documents without any sections are executed from top to bottom.
+			if len(loaded.GetSections()) == 0 {
+				return ports.RunDocument(loaded.MasterDocument, false)
+			}
+
+			if flagAsk || flagAskRepeat {
+				if !isatty.IsTerminal(os.Stdin.Fd()) {
+					return errors.New("--ask and --ask-repeat need a terminal to show the menu")
+				}
+
+				if flagAskRepeat {
+					return runInteractiveRepeat(loaded)
+				}
+			}
+
+			if isatty.IsTerminal(os.Stdin.Fd()) {
+				_, err := runInteractive(loaded)
+				return err
+			}
+
+			return cmd.Help()
 		},
 	}
 
@@ -150,8 +231,23 @@ func NewRootCmd() *cobra.Command {
 	rootCmd.PersistentFlags().StringVarP(&flagFilename, "file", "f", "", "File to run (defaults to RUNDOWN.md then README.md)")
 	rootCmd.PersistentFlags().StringVar(&flagCompletions, "completions", "", "Render shell completions for given shell (bash, zsh, fish, powershell)")
 	rootCmd.PersistentFlags().BoolVar(&flagDebug, "debug", false, "Write debugging info to rundown.log")
+	rootCmd.PersistentFlags().StringVar(&flagDefault, "default", "", "Section to run when no command is given")
+	rootCmd.PersistentFlags().BoolVar(&flagAsk, "ask", false, "Show a menu of sections to run when no command is given")
+	rootCmd.PersistentFlags().BoolVar(&flagAskRepeat, "ask-repeat", false, "Like --ask, but return to the menu after each run until it's exited")
 	rootCmd.PersistentFlags().StringVar(&flagServePort, "serve", "", "Set the port to serve a HTML interface for Rundown")
 	rootCmd.PersistentFlags().Bool("dump", false, "Dump the AST to be executed")
+	rootCmd.PersistentFlags().BoolVarP(&flagYes, "yes", "y", false, "Answer yes to confirmations, required to run dangerous sections in CI")
+	rootCmd.PersistentFlags().BoolVar(&flagKeepWorkspace, "keep-workspace", false, "Keep the run's workspace of scripts, saved files, outputs and artifacts")
+	rootCmd.PersistentFlags().BoolVar(&flagParallel, "parallel", false, "Run the given sections at the same time")
+	rootCmd.PersistentFlags().BoolVar(&flagKeepGoing, "keep-going", false, "Run the remaining sections after one of the given sections fails")
+	rootCmd.PersistentFlags().BoolVar(&flagShareEnv, "share-env", false, "Pass the variables each of the given sections captures on to the sections after it")
+
+	// Bundled tools always run their own document, and only have its help.
+	if activeBundle != nil {
+		rootCmd.Short = ""
+		rootCmd.Long = ""
+		rootCmd.PersistentFlags().Lookup("file").Hidden = true
+	}
 
 	rootCmd.Flag("completions").Hidden = true
 	rootCmd.Flag("dump").Hidden = true
@@ -163,6 +259,81 @@ func NewRootCmd() *cobra.Command {
 	return rootCmd
 }
 
+// Returns the document to run. Bundled tools run their own document, as if it were in the current directory.
+func findRundownFile() string {
+	if activeBundle != nil {
+		dir, _ := os.Getwd()
+		return filepath.Join(dir, activeBundle.Main)
+	}
+
+	return shared.RundownFile(flagFilename)
+}
+
+func loadRundown() (*rundown.LoadedDocuments, error) {
+	if activeBundle != nil {
+		return rundown.LoadFS(activeBundle.Files, activeBundle.Main, filepath.Dir(rundownFile))
+	}
+
+	return rundown.Load(rundownFile)
+}
+
+// Returns the name the program is run as, used in help and reference docs.
+func programName() string {
+	if activeBundle != nil {
+		return activeBundle.Name
+	}
+
+	return "rundown"
+}
+
+// Presents the section picker, and runs the chosen section after asking for its options.
+// Returns false when the picker is exited, or interrupted with Ctrl-C.
+func runInteractive(loaded *rundown.LoadedDocuments) (bool, error) {
+	KillReadlineBell()
+
+	section, err := AskSection(loaded)
+	if errors.Is(err, promptui.ErrInterrupt) {
+		return false, nil
+	} else if err != nil || section == nil {
+		return false, err
+	}
+
+	options, err := AskOptions(section)
+	if errors.Is(err, promptui.ErrInterrupt) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	section.Document.Context.AssumeYes = flagYes
+	section.Document.Context.KeepWorkspace = flagKeepWorkspace
+
+	return true, ports.RunSection(section, options, false)
+}
+
+// Presents the section picker again after each run, until it's exited. Runs which fail return to the picker,
+// as their failure has already been shown.
+func runInteractiveRepeat(loaded *rundown.LoadedDocuments) error {
+	for {
+		ran, err := runInteractive(loaded)
+
+		var executionError *errs.ExecutionError
+		switch {
+		case !ran || errors.Is(err, errs.ErrCancelled):
+			return err
+		case err != nil && !errors.As(err, &executionError) && !errors.Is(err, errs.ErrStopFail):
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		}
+
+		fmt.Println()
+
+		// Running a section changes the document, so each run gets a fresh copy.
+		if loaded, err = loadRundown(); err != nil {
+			return err
+		}
+	}
+}
+
 func init() {
 
 }
@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	rundown "github.com/elseano/rundown/pkg"
+	"github.com/elseano/rundown/pkg/ports"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Splits the arguments into one group per section, such as `build --env prod test`, each starting with the
+// section's name followed by its flags. Arguments before the first section, such as flags for rundown itself,
+// are given with the first section.
+func SplitSectionArgs(root *cobra.Command, sections map[string]*rundown.Section, args []string) [][]string {
+	groups := [][]string{}
+	leading := []string{}
+	var current *cobra.Command
+
+	appendArg := func(arg string) {
+		if len(groups) == 0 {
+			leading = append(leading, arg)
+		} else {
+			groups[len(groups)-1] = append(groups[len(groups)-1], arg)
+		}
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		switch {
+		case arg == "--":
+			for _, rest := range args[i:] {
+				appendArg(rest)
+			}
+
+			i = len(args)
+		case strings.HasPrefix(arg, "-"):
+			appendArg(arg)
+
+			if i+1 < len(args) && flagTakesValue(root, current, arg) {
+				i++
+				appendArg(args[i])
+			}
+		case sections[arg] != nil:
+			current, _, _ = root.Find([]string{arg})
+			groups = append(groups, append([]string{arg}, leading...))
+			leading = nil
+		default:
+			appendArg(arg)
+		}
+	}
+
+	return groups
+}
+
+// Returns true if the flag is given its value in the next argument, rather than being a boolean or including
+// its value (i.e. --env=prod).
+func flagTakesValue(root *cobra.Command, cmd *cobra.Command, arg string) bool {
+	if strings.Contains(arg, "=") {
+		return false
+	}
+
+	lookup := func(flags *pflag.FlagSet) *pflag.Flag {
+		if strings.HasPrefix(arg, "--") {
+			return flags.Lookup(arg[2:])
+		}
+
+		// Combined shorthands (i.e. -yf file) take a value when the last of them does.
+		return flags.ShorthandLookup(arg[len(arg)-1:])
+	}
+
+	flag := lookup(root.PersistentFlags())
+	if flag == nil && cmd != nil {
+		flag = lookup(cmd.LocalNonPersistentFlags())
+	}
+
+	return flag != nil && flag.NoOptDefVal == ""
+}
+
+// Returns a command which runs several sections in one go, given their arguments from SplitSectionArgs.
+// Each section's flags are parsed by the section's own command, along with the flags for rundown itself.
+func NewRunSectionsCmd(root *cobra.Command, sections map[string]*rundown.Section, groups [][]string) *cobra.Command {
+	return &cobra.Command{
+		Use:                root.Use,
+		SilenceUsage:       true,
+		SilenceErrors:      true,
+		DisableFlagParsing: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			runs := []ports.SectionRun{}
+
+			for _, group := range groups {
+				sectionCmd, _, err := root.Find(group[:1])
+				if err != nil {
+					return err
+				}
+
+				if err := sectionCmd.ParseFlags(group[1:]); err != nil {
+					return fmt.Errorf("%s: %w", group[0], err)
+				}
+
+				if extra := sectionCmd.Flags().Args(); len(extra) > 0 {
+					return fmt.Errorf("%s: unexpected argument %q", group[0], extra[0])
+				}
+
+				runs = append(runs, ports.SectionRun{Name: group[0], Options: ports.ChangedOptions(sectionCmd, sections[group[0]])})
+			}
+
+			load := func() (*rundown.LoadedDocuments, error) {
+				loaded, err := loadRundown()
+				if err != nil {
+					return nil, err
+				}
+
+				loaded.Context.AssumeYes = flagYes
+				loaded.Context.KeepWorkspace = flagKeepWorkspace
+
+				return loaded, nil
+			}
+
+			return ports.RunSections(os.Stdout, load, runs, ports.RunSectionsConfig{
+				Parallel:  flagParallel,
+				KeepGoing: flagKeepGoing,
+				ShareEnv:  flagShareEnv,
+			})
+		},
+	}
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"testing"
+
+	rundown "github.com/elseano/rundown/pkg"
+	"github.com/elseano/rundown/pkg/ports"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitSectionArgs(t *testing.T) {
+	loaded, err := rundown.LoadString(`
+# Build <r section="build"/>
+
+<r opt="env" type="string"/>
+<r opt="release" type="bool"/>
+
+# Test <r section="test"/>
+`, "tool.md")
+	require.NoError(t, err)
+
+	root := NewRootCmd()
+	sections := map[string]*rundown.Section{}
+
+	for _, section := range loaded.GetSections() {
+		root.AddCommand(ports.BuildCobraCommand("tool.md", section, false))
+		sections[section.Pointer.SectionName] = section
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected [][]string
+	}{
+		{"one section", []string{"build", "--env", "prod"}, [][]string{{"build", "--env", "prod"}}},
+		{"several sections", []string{"build", "--env", "prod", "test"}, [][]string{{"build", "--env", "prod"}, {"test"}}},
+		{"flag value named like a section", []string{"build", "--env", "test", "test"}, [][]string{{"build", "--env", "test"}, {"test"}}},
+		{"boolean flags", []string{"build", "--release", "test", "--yes"}, [][]string{{"build", "--release"}, {"test", "--yes"}}},
+		{"leading flags", []string{"--parallel", "-f", "tool.md", "build", "test"}, [][]string{{"build", "--parallel", "-f", "tool.md"}, {"test"}}},
+		{"inline values", []string{"build", "--env=test", "test"}, [][]string{{"build", "--env=test"}, {"test"}}},
+		{"no sections", []string{"--help"}, [][]string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, SplitSectionArgs(root, sections, test.args))
+		})
+	}
+}
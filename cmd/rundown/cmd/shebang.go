@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// Rewrites the arguments rundown is run with from a shebang line, such as `#!/usr/bin/env -S rundown --ask`, into
+// the arguments it'd be given on the command line. Arguments without a script are returned as they are.
+//
+// The kernel gives the script straight after the program, or after the shebang line's flags. Kernels such as
+// Linux pass the flags as a single argument, so that argument is split on spaces. The script is given as the --file.
+func ShebangArgs(args []string) []string {
+	if len(args) > 1 && isShebangScript(args[1]) {
+		return append([]string{args[0], "--file", args[1]}, args[2:]...)
+	}
+
+	if len(args) > 2 && isShebangFlags(args[1]) && isShebangScript(args[2]) {
+		result := append([]string{args[0]}, strings.Fields(args[1])...)
+		result = append(result, "--file", args[2])
+		return append(result, args[3:]...)
+	}
+
+	return args
+}
+
+// Returns true if the argument could be the flags from a shebang line, rather than a flag given the script.
+func isShebangFlags(arg string) bool {
+	return strings.HasPrefix(arg, "-") && arg != "--" && arg != "-f" && arg != "--file"
+}
+
+// Returns true if the file starts with a shebang line running rundown.
+func isShebangScript(filename string) bool {
+	info, err := os.Stat(filename)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return false
+	}
+
+	defer file.Close()
+
+	line, _ := bufio.NewReader(file).ReadString('\n')
+	return strings.HasPrefix(line, "#!") && strings.Contains(line, "rundown")
+}
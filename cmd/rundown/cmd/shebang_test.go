@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShebangArgs(t *testing.T) {
+	dir := t.TempDir()
+
+	script := filepath.Join(dir, "tool.md")
+	require.NoError(t, os.WriteFile(script, []byte("#!/usr/bin/env -S rundown --ask\n\n# Deploy\n"), 0755))
+
+	other := filepath.Join(dir, "notes.md")
+	require.NoError(t, os.WriteFile(other, []byte("# Notes\n"), 0644))
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string
+	}{
+		{"split by the kernel", []string{"rundown", "--default deploy --yes", script, "--env", "prod"}, []string{"rundown", "--default", "deploy", "--yes", "--file", script, "--env", "prod"}},
+		{"split by env", []string{"rundown", "--ask", script, "deploy"}, []string{"rundown", "--ask", "--file", script, "deploy"}},
+		{"without flags", []string{"rundown", script, "deploy"}, []string{"rundown", "--file", script, "deploy"}},
+		{"not a script", []string{"rundown", "--config", other}, []string{"rundown", "--config", other}},
+		{"script as a later argument", []string{"rundown", "deploy", "--config", script, "--msg", "a b"}, []string{"rundown", "deploy", "--config", script, "--msg", "a b"}},
+		{"file given", []string{"rundown", "-f", script, "deploy"}, []string{"rundown", "-f", script, "deploy"}},
+		{"after --", []string{"rundown", "--", script}, []string{"rundown", "--", script}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, ShebangArgs(test.args))
+		})
+	}
+}
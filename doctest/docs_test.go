@@ -125,11 +125,22 @@ func TestDocumentation(t *testing.T) {
 
 							if invocation != nil {
 								invocationStr := string(invocation.Text(section.Document.Source))
-								sectionName := strings.Replace(invocationStr, "rundown ", "", 1)
-
-								t.Logf("Executing section %s", sectionName)
-
-								rd.MasterDocument.Document = ast.PruneDocumentToSection(rd.MasterDocument.Document, sectionName)
+								sectionName := ""
+
+								for _, arg := range strings.Fields(strings.Replace(invocationStr, "rundown ", "", 1)) {
+									switch {
+									case arg == "--yes":
+										rd.MasterDocument.Context.AssumeYes = true
+									case !strings.HasPrefix(arg, "-"):
+										sectionName = arg
+									}
+								}
+
+								if sectionName != "" {
+									t.Logf("Executing section %s", sectionName)
+
+									rd.MasterDocument.Document = ast.PruneDocumentToSection(rd.MasterDocument.Document, sectionName)
+								}
 							}
 
 							out = util.CaptureStdout(func() {
@@ -141,10 +152,12 @@ func TestDocumentation(t *testing.T) {
 
 							executionContext := rd.MasterDocument.Context
 							executionContext.ImportRawEnv(os.Environ())
+							executionContext.ApplyEnvDefaults()
 
 							t.Logf("Env is %+v", executionContext.Env)
 
-							err := rd.MasterDocument.Goldmark.Renderer().Render(&output, sourceText, rd.MasterDocument.Document)
+							err := rd.MasterDocument.RenderNode(&output, rd.MasterDocument.Document)
+							executionContext.CloseWorkspace()
 
 							info := string(fcbExpected.Info.Text(section.Document.Source))
 
@@ -223,8 +236,12 @@ func (s *DocTestSpinner) NewStep(message string) {
 	s.substep = message
 }
 
-func (s *DocTestSpinner) HideAndExecute(f func()) {
+func (s *DocTestSpinner) Progress(current int, total int, label string) {
+	s.w.Write([]byte(fmt.Sprintf("    %d/%d %s\n", current, total, label)))
+}
 
+func (s *DocTestSpinner) HideAndExecute(f func()) {
+	f()
 }
 
 func (s *DocTestSpinner) CurrentHeading() string {
@@ -43,6 +43,10 @@ func main() {
 		os.Exit(1)
 	}
 
+	if errors.Is(err, errs.ErrCancelled) {
+		os.Exit(130)
+	}
+
 	if err != nil {
 		fmt.Printf("Error: %s\n", err.Error())
 		os.Exit(1)
@@ -0,0 +1,33 @@
+package ast
+
+import (
+	goldast "github.com/yuin/goldmark/ast"
+)
+
+// Content which runs once the section has finished, whether it succeeded, failed or was cancelled.
+// When OnCancel is set, it only runs if the user cancelled the run.
+type Cleanup struct {
+	goldast.BaseBlock
+	OnCancel  bool
+	Triggered bool
+}
+
+func NewCleanup(onCancel bool) *Cleanup {
+	return &Cleanup{
+		BaseBlock: goldast.NewParagraph().BaseBlock,
+		OnCancel:  onCancel,
+	}
+}
+
+var KindCleanup = goldast.NewNodeKind("Cleanup")
+
+// Kind implements Node.Kind.
+func (n *Cleanup) Kind() goldast.NodeKind {
+	return KindCleanup
+}
+
+func (n *Cleanup) Dump(source []byte, level int) {
+	goldast.DumpHelper(n, source, level, map[string]string{
+		"OnCancel": boolToStr(n.OnCancel),
+	}, nil)
+}
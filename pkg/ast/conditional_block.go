@@ -10,6 +10,9 @@ type ConditionalStart struct {
 	ConditionalImpl
 	ID  string
 	End *ConditionalEnd
+
+	// The previous branch when this is an else or else-if. Only one branch in the chain runs.
+	Else *ConditionalStart
 }
 
 type ConditionalEnd struct {
@@ -39,7 +42,23 @@ func (n *ConditionalStart) Kind() goldast.NodeKind {
 }
 
 func (n *ConditionalStart) Dump(source []byte, level int) {
-	goldast.DumpHelper(n, source, level, map[string]string{"IfScript": n.ifScript, "ID": n.ID}, nil)
+	attrs := map[string]string{"IfScript": n.ifScript, "ID": n.ID}
+	if n.Else != nil {
+		attrs["Else"] = n.Else.ID
+	}
+
+	goldast.DumpHelper(n, source, level, attrs, nil)
+}
+
+// Returns true if an earlier branch in the chain ran, so this branch must be skipped.
+func (n *ConditionalStart) EarlierBranchTaken() bool {
+	for branch := n.Else; branch != nil; branch = branch.Else {
+		if branch.HasResult() && branch.GetResult() {
+			return true
+		}
+	}
+
+	return false
 }
 
 func (n *ConditionalStart) GetEndSkipNode(goldast.Node) goldast.Node {
@@ -0,0 +1,37 @@
+package ast
+
+import (
+	goldast "github.com/yuin/goldmark/ast"
+)
+
+// Pauses execution until the user confirms they want to continue.
+type Confirm struct {
+	goldast.BaseBlock
+
+	// The question asked, i.e. "This will drop the database. Continue?"
+	Prompt string
+
+	// When set, the user must type this value to continue, rather than answering yes.
+	Expect string
+}
+
+func NewConfirm(prompt string) *Confirm {
+	return &Confirm{
+		BaseBlock: goldast.NewParagraph().BaseBlock,
+		Prompt:    prompt,
+	}
+}
+
+var KindConfirm = goldast.NewNodeKind("Confirm")
+
+// Kind implements Node.Kind.
+func (n *Confirm) Kind() goldast.NodeKind {
+	return KindConfirm
+}
+
+func (n *Confirm) Dump(source []byte, level int) {
+	goldast.DumpHelper(n, source, level, map[string]string{
+		"Prompt": n.Prompt,
+		"Expect": n.Expect,
+	}, nil)
+}
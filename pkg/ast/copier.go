@@ -43,6 +43,7 @@ func CopyNode(node goldast.Node) goldast.Node {
 		new.ifScript = n.ifScript
 		new.CaptureEnvironment = n.CaptureEnvironment
 		new.CaptureStdoutInto = n.CaptureStdoutInto
+		new.CaptureStderrInto = n.CaptureStderrInto
 		new.Execute = n.Execute
 		new.Language = n.Language
 		new.ReplaceProcess = n.ReplaceProcess
@@ -55,6 +56,10 @@ func CopyNode(node goldast.Node) goldast.Node {
 		new.SpinnerName = n.SpinnerName
 		new.SubstituteEnvironment = n.SubstituteEnvironment
 		new.With = n.With
+		new.ProgressFrom = n.ProgressFrom
+		new.Artifacts = n.Artifacts
+		new.Cwd = n.Cwd
+		new.Source = n.Source
 
 		return new
 
@@ -121,14 +126,102 @@ func CopyNode(node goldast.Node) goldast.Node {
 
 	case *InvokeBlock:
 		new := NewInvokeBlock()
-		new.Args = n.Args
+		for k, v := range n.Args {
+			new.Args[k] = v
+		}
 		new.AsDependency = n.AsDependency
 		new.Invoke = n.Invoke
+		new.Target = n.Target
+		CopySettings(n, new)
+		CopyChildren(n, new)
+
+		return new
+
+	case *Foreach:
+		new := NewForeach()
+		new.Axes = n.Axes
+		new.Matrix = n.Matrix
+		new.Parallel = n.Parallel
+		new.FailFast = n.FailFast
+		CopySettings(n, new)
+		CopyChildren(n, new)
+		return new
+
+	case *OnFailure:
+		new := NewOnFailure()
+		new.FailureMessageRegexp = n.FailureMessageRegexp
+		new.Stream = n.Stream
+		CopySettings(n, new)
+		CopyChildren(n, new)
+		return new
+
+	case *Cleanup:
+		new := NewCleanup(n.OnCancel)
+		CopySettings(n, new)
+		CopyChildren(n, new)
+		return new
+
+	case *Requires:
+		new := NewRequires(n.Requirements)
+		CopySettings(n, new)
+		return new
+
+	case *Confirm:
+		new := NewConfirm(n.Prompt)
+		new.Expect = n.Expect
+		CopySettings(n, new)
+		CopyChildren(n, new)
+		return new
+
+	case *ConditionalStart:
+		// The end is copied along with the start, see CopyChildren.
+		new := NewConditionalStart()
+		CopySettings(n, new)
+		return new
+
+	case *goldast.TextBlock:
+		new := goldast.NewTextBlock()
+		CopySettings(n, new)
+		CopyChildren(n, new)
+		return new
+
+	case *goldast.List:
+		new := goldast.NewList(n.Marker)
+		new.IsTight = n.IsTight
+		new.Start = n.Start
 		CopySettings(n, new)
 		CopyChildren(n, new)
+		return new
 
+	case *goldast.ListItem:
+		new := goldast.NewListItem(n.Offset)
+		CopySettings(n, new)
+		CopyChildren(n, new)
 		return new
 
+	case *goldast.Link:
+		new := goldast.NewLink()
+		new.Destination = n.Destination
+		new.Title = n.Title
+		CopySettings(n, new)
+		CopyChildren(n, new)
+		return new
+
+	case *goldast.Blockquote:
+		new := goldast.NewBlockquote()
+		CopySettings(n, new)
+		CopyChildren(n, new)
+		return new
+
+	case *goldast.ThematicBreak:
+		new := goldast.NewThematicBreak()
+		CopySettings(n, new)
+		return new
+
+	case *SectionOption:
+		// Options are parsed by the invoke from its target's pointer, so copies don't need them.
+		return nil
+
 	}
 
 	fmt.Printf("Could not copy %T\n", node)
@@ -136,11 +229,43 @@ func CopyNode(node goldast.Node) goldast.Node {
 }
 
 func CopyChildren(from goldast.Node, to goldast.Node) {
+	// Conditionals skip to their end when false, so each copied end must belong to its copied start.
+	// Ends outside the copied nodes are placed at the end of the copy.
+	ends := map[*ConditionalEnd]*ConditionalEnd{}
+	unclosed := []*ConditionalEnd{}
+
+	// Else branches must follow the copied branch before them.
+	starts := map[*ConditionalStart]*ConditionalStart{}
+
 	for child := from.FirstChild(); child != nil; child = child.NextSibling() {
+		if end, ok := child.(*ConditionalEnd); ok {
+			if copiedEnd, found := ends[end]; found {
+				to.AppendChild(to, copiedEnd)
+				delete(ends, end)
+			}
+
+			continue
+		}
+
 		copied := CopyNode(child)
 		if copied != nil {
 			to.AppendChild(to, copied)
 		}
+
+		if start, ok := child.(*ConditionalStart); ok && copied != nil {
+			copiedStart := copied.(*ConditionalStart)
+			copiedStart.Else = starts[start.Else]
+			starts[start] = copiedStart
+
+			ends[start.End] = copiedStart.End
+			unclosed = append(unclosed, copiedStart.End)
+		}
+	}
+
+	for i := len(unclosed) - 1; i >= 0; i-- {
+		if unclosed[i].Parent() == nil {
+			to.AppendChild(to, unclosed[i])
+		}
 	}
 }
 
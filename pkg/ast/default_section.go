@@ -0,0 +1,46 @@
+package ast
+
+import (
+	goldast "github.com/yuin/goldmark/ast"
+)
+
+// The default section block names the section to run when rundown is invoked without a command.
+//
+// For example: <r default-section="build"/>
+type DefaultSection struct {
+	goldast.BaseBlock
+
+	SectionName string
+}
+
+// NewDefaultSection returns a new DefaultSection node.
+func NewDefaultSection(name string) *DefaultSection {
+	return &DefaultSection{
+		BaseBlock:   goldast.NewParagraph().BaseBlock,
+		SectionName: name,
+	}
+}
+
+// KindDefaultSection is a NodeKind of the DefaultSection node.
+var KindDefaultSection = goldast.NewNodeKind("DefaultSection")
+
+// Kind implements Node.Kind.
+func (n *DefaultSection) Kind() goldast.NodeKind {
+	return KindDefaultSection
+}
+
+func (n *DefaultSection) Dump(source []byte, level int) {
+	goldast.DumpHelper(n, source, level, map[string]string{"SectionName": n.SectionName}, nil)
+}
+
+// Walks through the document, returning the name of the first default section found, or an empty string.
+func GetDefaultSection(doc goldast.Node) string {
+	if def, ok := FindNode(doc, func(n goldast.Node) bool {
+		_, ok := n.(*DefaultSection)
+		return ok
+	}).(*DefaultSection); ok {
+		return def.SectionName
+	}
+
+	return ""
+}
@@ -30,6 +30,7 @@ type ExecutionBlock struct {
 	ShowStdout            bool
 	ShowStderr            bool
 	CaptureStdoutInto     string
+	CaptureStderrInto     string
 	Reveal                bool
 	Execute               bool
 	CaptureEnvironment    []string
@@ -41,6 +42,14 @@ type ExecutionBlock struct {
 	ReplaceProcess        bool
 	SkipOnSuccess         bool
 	SkipOnFailure         bool
+	ProgressFrom          string
+	Artifacts             string
+
+	// Directory the block runs in, relative to the file it's declared in. Empty runs it in the document's directory.
+	Cwd string
+
+	// Where the code starts in the markdown, so failures can be reported against it.
+	Source SourcePosition
 }
 
 // NewRundownBlock returns a new RundownBlock node.
@@ -69,6 +78,8 @@ func (n *ExecutionBlock) Dump(source []byte, level int) {
 	goldast.DumpHelper(n, source, level, map[string]string{
 		"ShowStdout":            boolToStr(n.ShowStdout),
 		"ShowStderr":            boolToStr(n.ShowStderr),
+		"CaptureStdoutInto":     n.CaptureStdoutInto,
+		"CaptureStderrInto":     n.CaptureStderrInto,
 		"Reveal":                boolToStr(n.Reveal),
 		"Execute":               boolToStr(n.Execute),
 		"CaptureEnvironment":    strings.Join(n.CaptureEnvironment, ","),
@@ -78,6 +89,10 @@ func (n *ExecutionBlock) Dump(source []byte, level int) {
 		"SpinnerMode":           fmt.Sprintf("%#v", n.SpinnerMode),
 		"With":                  n.With,
 		"Language":              n.Language,
+		"ProgressFrom":          n.ProgressFrom,
+		"Artifacts":             n.Artifacts,
+		"Cwd":                   n.Cwd,
+		"Source":                n.Source.String(),
 		"SkipOnSuccess":         boolToStr(n.SkipOnSuccess),
 	}, nil)
 }
@@ -0,0 +1,182 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/elseano/rundown/pkg/util"
+	goldast "github.com/yuin/goldmark/ast"
+)
+
+// Repeats its content once for each combination of values, i.e. once per region.
+type Foreach struct {
+	goldast.BaseBlock
+	ConditionalImpl
+
+	Axes []ForeachAxis
+
+	// When set, the axes are options of the invoked section, rather than environment variables.
+	Matrix bool
+
+	// How many iterations run at once. Zero runs them one after the other, and -1 runs them all at once.
+	Parallel int
+
+	// Stops starting new iterations once one has failed.
+	FailFast bool
+}
+
+// A variable and the values it takes, such as REGION in "us-east-1,eu-west-1".
+type ForeachAxis struct {
+	Name string
+
+	// Comma separated, and substituted from the environment when the foreach runs.
+	Values string
+}
+
+func NewForeach() *Foreach {
+	return &Foreach{
+		BaseBlock: goldast.NewParagraph().BaseBlock,
+	}
+}
+
+var KindForeach = goldast.NewNodeKind("Foreach")
+
+// Kind implements Node.Kind.
+func (n *Foreach) Kind() goldast.NodeKind {
+	return KindForeach
+}
+
+func (n *Foreach) Dump(source []byte, level int) {
+	axes := []string{}
+	for _, axis := range n.Axes {
+		axes = append(axes, axis.Name+"="+axis.Values)
+	}
+
+	goldast.DumpHelper(n, source, level, map[string]string{
+		"Axes":     strings.Join(axes, "; "),
+		"Matrix":   boolToStr(n.Matrix),
+		"Parallel": fmt.Sprintf("%d", n.Parallel),
+		"FailFast": boolToStr(n.FailFast),
+	}, nil)
+}
+
+// Parses a matrix such as "region=us-east-1,eu-west-1; tier=web,worker".
+func ParseMatrix(matrix string) ([]ForeachAxis, error) {
+	result := []ForeachAxis{}
+
+	for _, part := range strings.Split(matrix, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name, values, found := strings.Cut(part, "=")
+		name = strings.TrimSpace(name)
+
+		if !found || name == "" {
+			return nil, fmt.Errorf("invalid matrix: %q, expected name=value1,value2", part)
+		}
+
+		result = append(result, ForeachAxis{Name: name, Values: strings.TrimSpace(values)})
+	}
+
+	if len(result) == 0 {
+		return nil, fmt.Errorf("matrix is empty")
+	}
+
+	return result, nil
+}
+
+// Splits a list of values separated by commas or whitespace.
+func SplitForeachValues(values string) []string {
+	return strings.FieldsFunc(values, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+}
+
+// Builds a copy of the content for every combination of the axes' values, in order.
+// Returns no iterations when an axis has no values.
+func (n *Foreach) Iterations(env map[string]string) []*ForeachIteration {
+	combinations := [][]string{{}}
+
+	for _, axis := range n.Axes {
+		values := SplitForeachValues(util.SubEnv(env, axis.Values))
+		next := [][]string{}
+
+		for _, combination := range combinations {
+			for _, value := range values {
+				next = append(next, append(append([]string{}, combination...), value))
+			}
+		}
+
+		combinations = next
+	}
+
+	result := []*ForeachIteration{}
+
+	for _, combination := range combinations {
+		iteration := NewForeachIteration()
+		labels := []string{}
+
+		for i, axis := range n.Axes {
+			iteration.Values[axis.Name] = combination[i]
+			labels = append(labels, axis.Name+"="+combination[i])
+		}
+
+		iteration.Label = strings.Join(labels, ", ")
+
+		CopyChildren(n, iteration)
+
+		if n.Matrix {
+			for child := iteration.FirstChild(); child != nil; child = child.NextSibling() {
+				if invoke, ok := child.(*InvokeBlock); ok {
+					for name, value := range iteration.Values {
+						invoke.Args[name] = value
+					}
+				}
+			}
+		} else {
+			iteration.Env = iteration.Values
+		}
+
+		result = append(result, iteration)
+	}
+
+	return result
+}
+
+// One repetition of a foreach's content.
+type ForeachIteration struct {
+	goldast.BaseBlock
+
+	// Describes the values, i.e. "REGION=us-east-1".
+	Label string
+
+	// The value of each axis.
+	Values map[string]string
+
+	// The environment variables set while the iteration runs. Empty for matrix invocations, which pass the values as options.
+	Env map[string]string
+}
+
+func NewForeachIteration() *ForeachIteration {
+	return &ForeachIteration{
+		BaseBlock: goldast.NewParagraph().BaseBlock,
+		Values:    map[string]string{},
+		Env:       map[string]string{},
+	}
+}
+
+var KindForeachIteration = goldast.NewNodeKind("ForeachIteration")
+
+// Kind implements Node.Kind.
+func (n *ForeachIteration) Kind() goldast.NodeKind {
+	return KindForeachIteration
+}
+
+func (n *ForeachIteration) Dump(source []byte, level int) {
+	goldast.DumpHelper(n, source, level, map[string]string{
+		"Label": n.Label,
+	}, nil)
+}
@@ -0,0 +1,125 @@
+package ast
+
+import (
+	"bytes"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FrontMatter holds the document-wide settings declared in a YAML block at the top of a rundown file.
+//
+// For example:
+//
+//	---
+//	shell: bash
+//	stdout: true
+//	env:
+//	  REGION: us-east-1
+//	---
+type FrontMatter struct {
+	Shell       string              `yaml:"shell"`       // Language used for fences without one.
+	Interpreter string              `yaml:"interpreter"` // Default `with` for fences without a language.
+	Stdout      bool                `yaml:"stdout"`      // Show stdout unless the block says otherwise.
+	Spinner     string              `yaml:"spinner"`     // Default spinner mode: visible, hidden, named or named-all.
+	Requires    []string            `yaml:"requires"`    // Tools required to run the document, i.e. "docker>=24".
+	Env         map[string]string   `yaml:"env"`         // Environment defaults, unless already set.
+	Imports     []FrontMatterImport `yaml:"imports"`     // Additional documents to import.
+	MinVersion  string              `yaml:"min-version"` // Minimum version of rundown required.
+	Cwd         string              `yaml:"cwd"`         // Directory blocks run in, relative to the document.
+
+	// How to run additional languages. Left undecoded, as interpreters are defined by the executor.
+	Interpreters yaml.Node `yaml:"interpreters"`
+}
+
+// An import is either a filename, or a mapping of prefix and file.
+type FrontMatterImport struct {
+	Prefix string `yaml:"prefix"`
+	File   string `yaml:"file"`
+}
+
+func (i *FrontMatterImport) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		i.File = value.Value
+		return nil
+	}
+
+	type plain FrontMatterImport
+	return value.Decode((*plain)(i))
+}
+
+// Returns the spinner mode declared, and whether one was declared at all.
+func (f *FrontMatter) SpinnerMode() (SpinnerMode, bool) {
+	switch f.Spinner {
+	case "visible":
+		return SpinnerModeVisible, true
+	case "hidden", "nospin":
+		return SpinnerModeHidden, true
+	case "named":
+		return SpinnerModeInlineFirst, true
+	case "named-all", "sub-spinners":
+		return SpinnerModeInlineAll, true
+	}
+
+	return SpinnerModeVisible, false
+}
+
+var frontMatterDelimiter = []byte("---")
+
+// Extracts the front matter from the source, if there is one. A shebang line may precede it.
+//
+// The shebang line and front matter in the returned source are blanked out with spaces rather than removed,
+// so byte offsets and line numbers in the remaining markdown remain the same.
+func ParseFrontMatter(source []byte) (*FrontMatter, []byte, error) {
+	frontMatter := &FrontMatter{}
+
+	start := 0
+	if bytes.HasPrefix(source, []byte("#!")) {
+		if eol := bytes.IndexByte(source, '\n'); eol != -1 {
+			start = eol + 1
+		}
+	}
+
+	lines := bytes.SplitAfter(source[start:], []byte("\n"))
+	if len(lines) == 0 || !bytes.Equal(bytes.TrimRight(lines[0], "\r\n"), frontMatterDelimiter) {
+		return frontMatter, blank(source, 0, start), nil
+	}
+
+	end := start + len(lines[0])
+	for _, line := range lines[1:] {
+		lineStart := end
+		end += len(line)
+
+		trimmed := bytes.TrimRight(line, "\r\n")
+		if bytes.Equal(trimmed, frontMatterDelimiter) || bytes.Equal(trimmed, []byte("...")) {
+			yamlSource := source[start+len(lines[0]) : lineStart]
+
+			if err := yaml.Unmarshal(yamlSource, frontMatter); err != nil {
+				return nil, source, fmt.Errorf("invalid front matter: %w", err)
+			}
+
+			return frontMatter, blank(source, 0, end), nil
+		}
+	}
+
+	// No closing delimiter, so it's just a thematic break.
+	return frontMatter, blank(source, 0, start), nil
+}
+
+// Returns a copy of the source with the characters between start and end replaced by spaces, keeping line breaks.
+func blank(source []byte, start int, end int) []byte {
+	if start == end {
+		return source
+	}
+
+	blanked := make([]byte, len(source))
+	copy(blanked, source)
+
+	for i := start; i < end; i++ {
+		if blanked[i] != '\n' && blanked[i] != '\r' {
+			blanked[i] = ' '
+		}
+	}
+
+	return blanked
+}
@@ -6,9 +6,19 @@ import (
 	goldast "github.com/yuin/goldmark/ast"
 )
 
+// Which of a script's output streams an OnFailure handler matches against.
+type FailureStream string
+
+const (
+	FailureStreamBoth   FailureStream = "both"
+	FailureStreamStdout FailureStream = "stdout"
+	FailureStreamStderr FailureStream = "stderr"
+)
+
 type OnFailure struct {
 	goldast.BaseBlock
 	FailureMessageRegexp string
+	Stream               FailureStream
 	Triggered            bool
 }
 
@@ -16,6 +26,7 @@ type OnFailure struct {
 func NewOnFailure() *OnFailure {
 	return &OnFailure{
 		BaseBlock: goldast.NewParagraph().BaseBlock,
+		Stream:    FailureStreamBoth,
 	}
 }
 
@@ -28,7 +39,22 @@ func (n *OnFailure) Kind() goldast.NodeKind {
 }
 
 func (n *OnFailure) Dump(source []byte, level int) {
-	goldast.DumpHelper(n, source, level, map[string]string{}, nil)
+	goldast.DumpHelper(n, source, level, map[string]string{
+		"FailureMessageRegexp": n.FailureMessageRegexp,
+		"Stream":               string(n.Stream),
+	}, nil)
+}
+
+// Checks the handler against the script's output, using the stream the handler is interested in.
+func (n *OnFailure) MatchesOutput(stdout []byte, stderr []byte) bool {
+	switch n.Stream {
+	case FailureStreamStdout:
+		return n.MatchesError(stdout)
+	case FailureStreamStderr:
+		return n.MatchesError(stderr)
+	default:
+		return n.MatchesError(stdout) || n.MatchesError(stderr)
+	}
 }
 
 func (n *OnFailure) MatchesError(output []byte) bool {
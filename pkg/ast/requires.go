@@ -0,0 +1,146 @@
+package ast
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/elseano/rundown/pkg/util"
+	goldast "github.com/yuin/goldmark/ast"
+)
+
+// A tool which must be present on the PATH, optionally with a version constraint.
+type Requirement struct {
+	Name     string
+	Operator string
+	Version  string
+}
+
+var requirementRegexp = regexp.MustCompile(`^([^\s<>=!]+)\s*(?:(>=|<=|==|!=|=|>|<)\s*(\S+))?$`)
+
+// Parses a comma separated list of requirements, such as "docker>=24, kubectl, jq".
+func ParseRequirements(spec string) ([]Requirement, error) {
+	result := []Requirement{}
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		matches := requirementRegexp.FindStringSubmatch(part)
+		if matches == nil {
+			return nil, fmt.Errorf("invalid requirement: %q", part)
+		}
+
+		result = append(result, Requirement{Name: matches[1], Operator: matches[2], Version: matches[3]})
+	}
+
+	return result, nil
+}
+
+// Returns true if the given version meets the requirement's constraint.
+func (r Requirement) Satisfied(version string) bool {
+	if r.Operator == "" {
+		return true
+	}
+
+	cmp := util.CompareVersions(version, r.Version)
+
+	switch r.Operator {
+	case ">=":
+		return cmp >= 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case "<":
+		return cmp < 0
+	case "!=":
+		return cmp != 0
+	default:
+		return cmp == 0
+	}
+}
+
+func (r Requirement) String() string {
+	return r.Name + r.Operator + r.Version
+}
+
+// The requires block declares tools needed to run the document or section.
+//
+// For example: <r requires="docker>=24, kubectl, jq"/>
+type Requires struct {
+	goldast.BaseBlock
+
+	Requirements []Requirement
+}
+
+// NewRequires returns a new Requires node.
+func NewRequires(requirements []Requirement) *Requires {
+	return &Requires{
+		BaseBlock:    goldast.NewParagraph().BaseBlock,
+		Requirements: requirements,
+	}
+}
+
+// KindRequires is a NodeKind of the Requires node.
+var KindRequires = goldast.NewNodeKind("Requires")
+
+// Kind implements Node.Kind.
+func (n *Requires) Kind() goldast.NodeKind {
+	return KindRequires
+}
+
+func (n *Requires) Dump(source []byte, level int) {
+	names := []string{}
+	for _, r := range n.Requirements {
+		names = append(names, r.String())
+	}
+
+	goldast.DumpHelper(n, source, level, map[string]string{"Requirements": strings.Join(names, ", ")}, nil)
+}
+
+// Collects all the requirements declared within the node, without duplicates.
+func GetRequirements(node goldast.Node) []Requirement {
+	result := []Requirement{}
+	seen := map[string]bool{}
+
+	goldast.Walk(node, func(n goldast.Node, entering bool) (goldast.WalkStatus, error) {
+		if requires, ok := n.(*Requires); ok && entering {
+			for _, r := range requires.Requirements {
+				if !seen[r.String()] {
+					seen[r.String()] = true
+					result = append(result, r)
+				}
+			}
+		}
+
+		return goldast.WalkContinue, nil
+	})
+
+	return result
+}
+
+// Returns the requires nodes which apply to the whole document, rather than a single section.
+func getDocumentRequires(doc goldast.Node) []*Requires {
+	result := []*Requires{}
+
+	goldast.Walk(doc, func(n goldast.Node, entering bool) (goldast.WalkStatus, error) {
+		if !entering {
+			return goldast.WalkContinue, nil
+		}
+
+		if _, ok := n.(*SectionPointer); ok {
+			return goldast.WalkSkipChildren, nil
+		}
+
+		if requires, ok := n.(*Requires); ok {
+			result = append(result, requires)
+		}
+
+		return goldast.WalkContinue, nil
+	})
+
+	return result
+}
@@ -2,10 +2,15 @@ package ast
 
 import (
 	"fmt"
+	"net/mail"
+	"net/url"
+	"os"
 	"path"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	goldast "github.com/yuin/goldmark/ast"
 	"golang.org/x/exp/maps"
@@ -24,6 +29,16 @@ type OptionTypeRuntime interface {
 	NormaliseToPath(input string, path string) (string, error)
 }
 
+// Option types which set more environment variables than the option's own, such as a list's items.
+type OptionTypeExpanded interface {
+	Expand(name string, input string) map[string]string
+}
+
+// Option types which accept the min and max attributes.
+type OptionTypeRanged interface {
+	SetRange(min null.String, max null.String) error
+}
+
 type TypeBoolean struct{}
 
 type TypeEnum struct {
@@ -34,13 +49,45 @@ type TypeKV struct {
 	Pairs map[string]string
 }
 
-type TypeString struct{}
+type TypeString struct {
+	Pattern *regexp.Regexp
+}
+
+type TypeInt struct {
+	Min null.Int
+	Max null.Int
+}
+
+type TypeFloat struct {
+	Min null.Float
+	Max null.Float
+}
+
+// A list of values, such as "a,b,c". Exported joined, and as indexed variables with a count.
+type TypeList struct {
+	Separator string
+}
+
+type TypeURL struct{}
 
-type TypeInt struct{}
+type TypeEmail struct{}
+
+// A Go duration, such as 30s or 1h30m.
+type TypeDuration struct{}
+
+// A date as YYYY-MM-DD.
+type TypeDate struct{}
+
+// A semantic version, such as 1.2.3 or v1.2.3-beta.1.
+type TypeSemver struct{}
 
 type TypeFilename struct {
 	MustExist    bool
 	MustNotExist bool
+	Directory    bool
+	Readable     bool
+	Writable     bool
+	Executable   bool
 }
 
 type TypePath struct{}
@@ -55,6 +102,11 @@ type SectionOption struct {
 	OptionDefault     null.String
 	OptionRequired    bool
 	OptionAs          string
+
+	Requires   []string // Options which must also be given when this one is.
+	Conflicts  []string // Options which can't be given with this one.
+	RequiredIf string   // An expression which makes this option required when true, i.e. $OPT_MODE == 'restore'.
+	Group      string   // The heading the option is listed under in help.
 }
 
 // NewRundownBlock returns a new RundownBlock node.
@@ -86,12 +138,20 @@ func (n *SectionOption) Dump(source []byte, level int) {
 		"WillPrompt":  boolToStr(n.OptionPrompt.Valid),
 		"Default":     n.OptionDefault.ValueOrZero(),
 		"Description": n.OptionDescription,
+		"Requires":    strings.Join(n.Requires, ", "),
+		"Conflicts":   strings.Join(n.Conflicts, ", "),
+		"RequiredIf":  n.RequiredIf,
+		"Group":       n.Group,
 	}, nil)
 }
 
 func BuildOptionType(optionType string) (OptionType, error) {
 	optType := strings.ToLower(optionType)
 
+	if strings.HasPrefix(optType, "enum-from:") {
+		return &TypeEnumFrom{Command: strings.TrimSpace(optionType[10:])}, nil
+	}
+
 	if strings.HasPrefix(optType, "enum:") {
 		options := strings.Split(optionType[5:], "|")
 		return &TypeEnum{
@@ -131,22 +191,78 @@ func BuildOptionType(optionType string) (OptionType, error) {
 		return &TypePath{}, nil
 	}
 
-	if strings.HasPrefix(optType, "file:") {
-		fileOp := strings.Replace(optType, "file:", "", 1)
+	if strings.HasPrefix(optType, "float") {
+		return &TypeFloat{}, nil
+	}
+
+	if strings.HasPrefix(optType, "list") {
+		return &TypeList{Separator: ","}, nil
+	}
+
+	switch optType {
+	case "url":
+		return &TypeURL{}, nil
+	case "email":
+		return &TypeEmail{}, nil
+	case "duration":
+		return &TypeDuration{}, nil
+	case "date":
+		return &TypeDate{}, nil
+	case "semver":
+		return &TypeSemver{}, nil
+	}
 
-		return &TypeFilename{MustExist: fileOp == "exist", MustNotExist: fileOp == "not-exist"}, nil
-	} else if optType == "file" {
-		return &TypeFilename{}, nil
+	if kind, checks, _ := strings.Cut(optType, ":"); kind == "file" || kind == "dir" {
+		return buildFilenameType(kind == "dir", checks)
 	}
 
 	return nil, fmt.Errorf("unknown option type `%s`", optType)
 }
 
+// Builds file and dir types from their checks, such as file:exist or dir:exist,writable.
+func buildFilenameType(directory bool, checks string) (OptionType, error) {
+	t := &TypeFilename{Directory: directory}
+
+	if checks == "" {
+		return t, nil
+	}
+
+	for _, check := range strings.Split(checks, ",") {
+		switch strings.TrimSpace(check) {
+		case "exist", "exists":
+			t.MustExist = true
+		case "not-exist", "not-exists":
+			t.MustNotExist = true
+		case "readable":
+			t.Readable = true
+		case "writable":
+			t.Writable = true
+		case "executable":
+			t.Executable = true
+		default:
+			return nil, fmt.Errorf("unknown file check `%s`, expected exist, not-exist, readable, writable or executable", check)
+		}
+	}
+
+	if t.MustNotExist && (t.MustExist || t.Readable || t.Writable || t.Executable) {
+		return nil, fmt.Errorf("not-exist can't be combined with other file checks")
+	}
+
+	return t, nil
+}
+
 func (t *TypeKV) InputType() string       { return "string" }
 func (t *TypeEnum) InputType() string     { return "string" }
 func (t *TypeString) InputType() string   { return "string" }
 func (t *TypeBoolean) InputType() string  { return "bool" }
 func (t *TypeInt) InputType() string      { return "int" }
+func (t *TypeFloat) InputType() string    { return "float" }
+func (t *TypeList) InputType() string     { return "list" }
+func (t *TypeURL) InputType() string      { return "url" }
+func (t *TypeEmail) InputType() string    { return "email" }
+func (t *TypeDuration) InputType() string { return "duration" }
+func (t *TypeDate) InputType() string     { return "date" }
+func (t *TypeSemver) InputType() string   { return "version" }
 func (t *TypeFilename) InputType() string { return "string" }
 func (t *TypePath) InputType() string     { return "string" }
 
@@ -155,14 +271,27 @@ func (t *TypeEnum) ResolvedValue(input string) string     { return input }
 func (t *TypeString) ResolvedValue(input string) string   { return input }
 func (t *TypeBoolean) ResolvedValue(input string) string  { return input }
 func (t *TypeInt) ResolvedValue(input string) string      { return input }
+func (t *TypeFloat) ResolvedValue(input string) string    { return input }
+func (t *TypeList) ResolvedValue(input string) string     { return input }
+func (t *TypeURL) ResolvedValue(input string) string      { return input }
+func (t *TypeEmail) ResolvedValue(input string) string    { return input }
+func (t *TypeDuration) ResolvedValue(input string) string { return input }
+func (t *TypeDate) ResolvedValue(input string) string     { return input }
+func (t *TypeSemver) ResolvedValue(input string) string   { return input }
 func (t *TypeFilename) ResolvedValue(input string) string { return input }
 func (t *TypePath) ResolvedValue(input string) string     { return input }
 
-func (t *TypeKV) Normalise(input string) string     { return input }
-func (t *TypeEnum) Normalise(input string) string   { return input }
-func (t *TypeString) Normalise(input string) string { return input }
-func (t *TypeInt) Normalise(input string) string    { return input }
-func (t *TypePath) Normalise(input string) string   { return input }
+func (t *TypeKV) Normalise(input string) string       { return input }
+func (t *TypeEnum) Normalise(input string) string     { return input }
+func (t *TypeString) Normalise(input string) string   { return input }
+func (t *TypeInt) Normalise(input string) string      { return strings.TrimSpace(input) }
+func (t *TypeFloat) Normalise(input string) string    { return strings.TrimSpace(input) }
+func (t *TypeURL) Normalise(input string) string      { return strings.TrimSpace(input) }
+func (t *TypeEmail) Normalise(input string) string    { return strings.TrimSpace(input) }
+func (t *TypeDuration) Normalise(input string) string { return strings.TrimSpace(input) }
+func (t *TypeDate) Normalise(input string) string     { return strings.TrimSpace(input) }
+func (t *TypeSemver) Normalise(input string) string   { return strings.TrimSpace(input) }
+func (t *TypePath) Normalise(input string) string     { return input }
 
 func (t *TypeBoolean) Normalise(input string) string {
 	if strings.ToLower(input) == "true" {
@@ -172,6 +301,45 @@ func (t *TypeBoolean) Normalise(input string) string {
 	}
 }
 
+// Trims the items and drops empty ones, so "a, b,,c" becomes "a,b,c".
+func (t *TypeList) Normalise(input string) string {
+	return strings.Join(t.Items(input), t.Separator)
+}
+
+func (t *TypeList) Items(input string) []string {
+	items := []string{}
+
+	for _, item := range strings.Split(input, t.Separator) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// Sets NAME_COUNT, and NAME_0, NAME_1... for each item.
+func (t *TypeList) Expand(name string, input string) map[string]string {
+	items := t.Items(input)
+	result := map[string]string{name + "_COUNT": strconv.Itoa(len(items))}
+
+	for i, item := range items {
+		result[fmt.Sprintf("%s_%d", name, i)] = item
+	}
+
+	return result
+}
+
+// Sets NAME_SECONDS, for scripts which can't parse durations.
+func (t *TypeDuration) Expand(name string, input string) map[string]string {
+	duration, err := time.ParseDuration(input)
+	if err != nil {
+		return map[string]string{}
+	}
+
+	return map[string]string{name + "_SECONDS": strconv.FormatInt(int64(duration.Seconds()), 10)}
+}
+
 func (t *TypeEnum) Validate(input string) error {
 	for _, x := range t.ValidValues {
 		if input == x {
@@ -192,7 +360,11 @@ func (t *TypeKV) Validate(input string) error {
 	return fmt.Errorf("\"%s\" must be one of: %s", input, strings.Join(maps.Keys(t.Pairs), ", "))
 }
 
-func (t *TypeString) Validate(string) error {
+func (t *TypeString) Validate(input string) error {
+	if t.Pattern != nil && !t.Pattern.MatchString(input) {
+		return fmt.Errorf("\"%s\" must match %s", input, t.Pattern.String())
+	}
+
 	return nil
 }
 
@@ -201,8 +373,122 @@ func (t *TypeBoolean) Validate(string) error {
 }
 
 func (t *TypeInt) Validate(input string) error {
-	_, err := strconv.Atoi(input)
-	return err
+	value, err := strconv.ParseInt(input, 10, 64)
+	if err != nil {
+		return fmt.Errorf("\"%s\" must be a whole number", input)
+	}
+
+	if (t.Min.Valid && value < t.Min.Int64) || (t.Max.Valid && value > t.Max.Int64) {
+		return fmt.Errorf("\"%s\" must be %s", input, t.Describe())
+	}
+
+	return nil
+}
+
+func (t *TypeFloat) Validate(input string) error {
+	value, err := strconv.ParseFloat(input, 64)
+	if err != nil {
+		return fmt.Errorf("\"%s\" must be a number", input)
+	}
+
+	if (t.Min.Valid && value < t.Min.Float64) || (t.Max.Valid && value > t.Max.Float64) {
+		return fmt.Errorf("\"%s\" must be %s", input, t.Describe())
+	}
+
+	return nil
+}
+
+func (t *TypeList) Validate(input string) error {
+	if len(t.Items(input)) == 0 {
+		return fmt.Errorf("\"%s\" must have at least one item", input)
+	}
+
+	return nil
+}
+
+func (t *TypeURL) Validate(input string) error {
+	u, err := url.Parse(input)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("\"%s\" must be a URL, such as https://example.com", input)
+	}
+
+	return nil
+}
+
+func (t *TypeEmail) Validate(input string) error {
+	address, err := mail.ParseAddress(input)
+	if err != nil || address.Address != input {
+		return fmt.Errorf("\"%s\" must be an email address", input)
+	}
+
+	return nil
+}
+
+func (t *TypeDuration) Validate(input string) error {
+	if _, err := time.ParseDuration(input); err != nil {
+		return fmt.Errorf("\"%s\" must be a duration, such as 30s or 1h30m", input)
+	}
+
+	return nil
+}
+
+func (t *TypeDate) Validate(input string) error {
+	if _, err := time.Parse("2006-01-02", input); err != nil {
+		return fmt.Errorf("\"%s\" must be a date as YYYY-MM-DD", input)
+	}
+
+	return nil
+}
+
+// From semver.org, allowing a leading v.
+var semverPattern = regexp.MustCompile(`^v?(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(?:-((?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*)(?:\.(?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*))*))?(?:\+([0-9a-zA-Z-]+(?:\.[0-9a-zA-Z-]+)*))?$`)
+
+func (t *TypeSemver) Validate(input string) error {
+	if !semverPattern.MatchString(input) {
+		return fmt.Errorf("\"%s\" must be a semantic version, such as 1.2.3", input)
+	}
+
+	return nil
+}
+
+func (t *TypeInt) SetRange(min null.String, max null.String) error {
+	var err error
+
+	if min.Valid {
+		if t.Min.Int64, err = strconv.ParseInt(min.String, 10, 64); err != nil {
+			return fmt.Errorf("min \"%s\" must be a whole number", min.String)
+		}
+		t.Min.Valid = true
+	}
+
+	if max.Valid {
+		if t.Max.Int64, err = strconv.ParseInt(max.String, 10, 64); err != nil {
+			return fmt.Errorf("max \"%s\" must be a whole number", max.String)
+		}
+		t.Max.Valid = true
+	}
+
+	return nil
+}
+
+func (t *TypeFloat) SetRange(min null.String, max null.String) error {
+	var err error
+
+	if min.Valid {
+		if t.Min.Float64, err = strconv.ParseFloat(min.String, 64); err != nil {
+			return fmt.Errorf("min \"%s\" must be a number", min.String)
+		}
+		t.Min.Valid = true
+	}
+
+	if max.Valid {
+		if t.Max.Float64, err = strconv.ParseFloat(max.String, 64); err != nil {
+			return fmt.Errorf("max \"%s\" must be a number", max.String)
+		}
+		t.Max.Valid = true
+	}
+
+	return nil
 }
 
 func (t *TypeEnum) Describe() string {
@@ -214,10 +500,41 @@ func (t *TypeKV) Describe() string {
 }
 
 func (t *TypeString) Describe() string {
+	if t.Pattern != nil {
+		return "matching " + t.Pattern.String()
+	}
+
 	return "any value"
 }
 
 func (t *TypeFilename) Describe() string {
+	kind := "file"
+	if t.Directory {
+		kind = "directory"
+	}
+
+	checks := []string{}
+	if t.Readable {
+		checks = append(checks, "readable")
+	}
+	if t.Writable {
+		checks = append(checks, "writable")
+	}
+	if t.Executable {
+		checks = append(checks, "executable")
+	}
+
+	switch {
+	case len(checks) > 0:
+		return "an existing " + strings.Join(checks, ", ") + " " + kind
+	case t.MustExist:
+		return "an existing " + kind
+	case t.MustNotExist:
+		return "a " + kind + " which doesn't exist"
+	case t.Directory:
+		return "any directory"
+	}
+
 	return "any file name"
 }
 
@@ -230,13 +547,120 @@ func (t *TypeBoolean) Describe() string {
 }
 
 func (t *TypeInt) Describe() string {
-	return "a number"
+	return describeRange("a number", t.Min.Valid, t.Max.Valid, strconv.FormatInt(t.Min.Int64, 10), strconv.FormatInt(t.Max.Int64, 10))
+}
+
+func (t *TypeFloat) Describe() string {
+	format := func(f float64) string { return strconv.FormatFloat(f, 'g', -1, 64) }
+	return describeRange("a decimal number", t.Min.Valid, t.Max.Valid, format(t.Min.Float64), format(t.Max.Float64))
 }
 
-func (t *TypeFilename) Validate(string) error {
+func describeRange(description string, hasMin bool, hasMax bool, min string, max string) string {
+	switch {
+	case hasMin && hasMax:
+		return fmt.Sprintf("%s from %s to %s", description, min, max)
+	case hasMin:
+		return fmt.Sprintf("%s of at least %s", description, min)
+	case hasMax:
+		return fmt.Sprintf("%s of at most %s", description, max)
+	}
+
+	return description
+}
+
+func (t *TypeList) Describe() string {
+	return fmt.Sprintf("a list separated by \"%s\"", t.Separator)
+}
+
+func (t *TypeURL) Describe() string {
+	return "a URL"
+}
+
+func (t *TypeEmail) Describe() string {
+	return "an email address"
+}
+
+func (t *TypeDuration) Describe() string {
+	return "a duration, such as 30s or 1h30m"
+}
+
+func (t *TypeDate) Describe() string {
+	return "a date as YYYY-MM-DD"
+}
+
+func (t *TypeSemver) Describe() string {
+	return "a semantic version, such as 1.2.3"
+}
+
+// Checks the file against the type's existence, directory and permission checks. Input is the absolute path from NormaliseToPath.
+func (t *TypeFilename) Validate(input string) error {
+	info, err := os.Stat(input)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	exists := err == nil
+
+	if t.MustNotExist && exists {
+		return fmt.Errorf("\"%s\" already exists", input)
+	}
+
+	if !exists {
+		if t.MustExist || t.Readable || t.Writable || t.Executable {
+			return fmt.Errorf("\"%s\" doesn't exist", input)
+		}
+
+		return nil
+	}
+
+	if t.Directory && !info.IsDir() {
+		return fmt.Errorf("\"%s\" isn't a directory", input)
+	}
+
+	if !t.Directory && info.IsDir() {
+		return fmt.Errorf("\"%s\" is a directory", input)
+	}
+
+	if t.Readable && !readable(input, info) {
+		return fmt.Errorf("\"%s\" isn't readable", input)
+	}
+
+	if t.Writable && !writable(input, info) {
+		return fmt.Errorf("\"%s\" isn't writable", input)
+	}
+
+	if t.Executable && info.Mode()&0111 == 0 {
+		return fmt.Errorf("\"%s\" isn't executable", input)
+	}
+
 	return nil
 }
 
+func readable(path string, info os.FileInfo) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+
+	f.Close()
+	return true
+}
+
+// Directories can't be opened for writing, so they're checked by their permissions.
+func writable(path string, info os.FileInfo) bool {
+	if info.IsDir() {
+		return info.Mode().Perm()&0222 != 0
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return false
+	}
+
+	f.Close()
+	return true
+}
+
 func (t *TypePath) Validate(string) error {
 	return nil
 }
@@ -247,11 +671,18 @@ func (t *TypeFilename) Normalise(input string) string {
 
 // Takes the path provided in the option, and treats it as relative to the pwd, returning the absolute path.
 func (t *TypeFilename) NormaliseToPath(input string, pwd string) (string, error) {
-	rel := path.Join(pwd, input)
-	return filepath.Abs(rel)
+	return normaliseToPath(input, pwd)
 }
 
 func (t *TypePath) NormaliseToPath(input string, pwd string) (string, error) {
+	return normaliseToPath(input, pwd)
+}
+
+func normaliseToPath(input string, pwd string) (string, error) {
+	if filepath.IsAbs(input) {
+		return filepath.Clean(input), nil
+	}
+
 	rel := path.Join(pwd, input)
 	return filepath.Abs(rel)
 }
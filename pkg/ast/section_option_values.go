@@ -0,0 +1,223 @@
+package ast
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elseano/rundown/pkg/util"
+	"golang.org/x/exp/slices"
+)
+
+// How long the command listing an option's values can run for.
+const valuesFromTimeout = 30 * time.Second
+
+// How long listed values are reused for. Shell completion starts a new process for every completion,
+// so without this the command would run on each tab press.
+const valuesFromCacheTTL = 5 * time.Minute
+
+// An enum whose values are listed by a command, one per line, such as `kubectl get ns -o name | cut -d/ -f2`.
+// The command is only run when the values are first needed. Its values are cached between runs for a few minutes.
+type TypeEnumFrom struct {
+	Command string
+	Dir     string            // The document's directory.
+	Cwd     string            // Where the command runs, relative to Dir. Empty runs it in Dir.
+	Env     map[string]string // The document's environment defaults, for variables which aren't already set.
+
+	// Identify the option in the cache. Without a document, values aren't cached between runs.
+	Document string
+	Option   string
+
+	lock   sync.Mutex
+	loaded bool
+	cached bool
+	values []string
+	err    error
+}
+
+// Returns the values listed by the command, running it the first time unless they're cached.
+func (t *TypeEnumFrom) Values() ([]string, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if !t.loaded {
+		t.loaded = true
+
+		if values, ok := t.readCache(); ok {
+			t.values, t.cached = values, true
+		} else {
+			t.list()
+		}
+	}
+
+	return t.values, t.err
+}
+
+// Runs the command again if the values came from the cache.
+func (t *TypeEnumFrom) refresh() ([]string, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.cached {
+		t.cached = false
+		t.list()
+	}
+
+	return t.values, t.err
+}
+
+func (t *TypeEnumFrom) list() {
+	ctx, cancel := context.WithTimeout(context.Background(), valuesFromTimeout)
+	defer cancel()
+
+	env := map[string]string{}
+	for _, v := range os.Environ() {
+		if name, value, ok := strings.Cut(v, "="); ok {
+			env[name] = value
+		}
+	}
+
+	for name, value := range t.Env {
+		if _, ok := env[name]; !ok {
+			env[name] = value
+		}
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", t.Command)
+	cmd.Dir = t.Dir
+
+	if t.Cwd != "" {
+		if cwd := util.SubEnv(env, t.Cwd); filepath.IsAbs(cwd) {
+			cmd.Dir = cwd
+		} else {
+			cmd.Dir = filepath.Join(t.Dir, cwd)
+		}
+	}
+
+	for name, value := range env {
+		cmd.Env = append(cmd.Env, name+"="+value)
+	}
+
+	output, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			err = fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+
+		t.values, t.err = nil, fmt.Errorf("listing values with \"%s\": %w", t.Command, err)
+		return
+	}
+
+	t.values, t.err = []string{}, nil
+
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			t.values = append(t.values, line)
+		}
+	}
+
+	t.writeCache()
+}
+
+// Returns where the values are cached, keyed by the document, option and command. Empty when they can't be cached.
+func (t *TypeEnumFrom) cacheFile() string {
+	if t.Document == "" {
+		return ""
+	}
+
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+
+	key := sha256.Sum256([]byte(t.Document + "\x00" + t.Option + "\x00" + t.Command))
+
+	return filepath.Join(cacheDir, "rundown", "values", hex.EncodeToString(key[:]))
+}
+
+func (t *TypeEnumFrom) readCache() ([]string, bool) {
+	filename := t.cacheFile()
+	if filename == "" {
+		return nil, false
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil || time.Since(info.ModTime()) > valuesFromCacheTTL {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, false
+	}
+
+	values := []string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			values = append(values, line)
+		}
+	}
+
+	return values, true
+}
+
+// Caches the values for later runs. Failing to is only logged, as the command can run again.
+func (t *TypeEnumFrom) writeCache() {
+	filename := t.cacheFile()
+	if filename == "" {
+		return
+	}
+
+	contents := strings.Join(t.values, "\n") + "\n"
+
+	err := os.MkdirAll(filepath.Dir(filename), 0700)
+	if err == nil {
+		err = os.WriteFile(filename, []byte(contents), 0600)
+	}
+
+	if err != nil {
+		util.Logger.Debug().Msgf("Unable to cache the values of %s: %s", t.Option, err)
+	}
+}
+
+func (t *TypeEnumFrom) InputType() string                 { return "string" }
+func (t *TypeEnumFrom) ResolvedValue(input string) string { return input }
+func (t *TypeEnumFrom) Normalise(input string) string     { return strings.TrimSpace(input) }
+
+func (t *TypeEnumFrom) Validate(input string) error {
+	values, err := t.Values()
+
+	// Cached values may be out of date, such as a namespace created since, so check with the command.
+	if err == nil && !slices.Contains(values, input) {
+		values, err = t.refresh()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if slices.Contains(values, input) {
+		return nil
+	}
+
+	if len(values) == 0 {
+		return fmt.Errorf("\"%s\" isn't valid, \"%s\" didn't list any values", input, t.Command)
+	}
+
+	return fmt.Errorf("\"%s\" must be one of: %s", input, strings.Join(values, ", "))
+}
+
+// Describes the option without running the command, as descriptions are built when the document loads.
+// Backquotes aren't used, as cobra takes them as the flag's value name.
+func (t *TypeEnumFrom) Describe() string {
+	return fmt.Sprintf("one of the values listed by \"%s\"", t.Command)
+}
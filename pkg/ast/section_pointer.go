@@ -5,9 +5,11 @@ import (
 	"os"
 	"strings"
 
+	"github.com/elseano/rundown/pkg/condition"
 	"github.com/elseano/rundown/pkg/util"
 	"github.com/yuin/goldmark/ast"
 	goldast "github.com/yuin/goldmark/ast"
+	"golang.org/x/exp/maps"
 )
 
 type SectionPointer struct {
@@ -21,6 +23,9 @@ type SectionPointer struct {
 	DescriptionLong  *DescriptionBlock
 	Silent           bool
 
+	// Directory the section's blocks run in, unless they declare their own.
+	Cwd string
+
 	ParentSection *SectionPointer
 
 	Dependencies []*SectionPointer
@@ -87,24 +92,31 @@ func (n *SectionPointer) GetOptionByEnvName(name string) *SectionOption {
 	return nil
 }
 
-func (n *SectionPointer) ParseOptions(options map[string]string) (map[string]string, error) {
-	return n.ParseOptionsWithResolution(options, map[string]string{})
+// Parses options given as they are, such as from the command line. The env isn't substituted into their values,
+// but required-if conditions can use it, such as $CI.
+func (n *SectionPointer) ParseOptions(options map[string]string, env map[string]string) (map[string]string, error) {
+	return n.parseOptionsWithResolutionFunc(options, map[string]string{}, env, func(optionName string) *SectionOption {
+		return n.GetOptionByEnvName(optionName)
+	})
 }
 
 func (n *SectionPointer) ParseOptionsWithResolution(options map[string]string, env map[string]string) (map[string]string, error) {
-	return n.parseOptionsWithResolutionFunc(options, env, func(optionName string) *SectionOption {
+	return n.parseOptionsWithResolutionFunc(options, env, env, func(optionName string) *SectionOption {
 		return n.GetOptionByEnvName(optionName)
 	})
 }
 
 func (n *SectionPointer) ParseOptionsWithResolutionByName(options map[string]string, env map[string]string) (map[string]string, error) {
-	return n.parseOptionsWithResolutionFunc(options, env, func(optionName string) *SectionOption {
+	return n.parseOptionsWithResolutionFunc(options, env, env, func(optionName string) *SectionOption {
 		return n.GetOption(optionName)
 	})
 }
 
-func (n *SectionPointer) parseOptionsWithResolutionFunc(options map[string]string, env map[string]string, resolver func(optionName string) *SectionOption) (map[string]string, error) {
+// Values are substituted with env, while conditionEnv is what required-if conditions see alongside the options.
+func (n *SectionPointer) parseOptionsWithResolutionFunc(options map[string]string, env map[string]string, conditionEnv map[string]string, resolver func(optionName string) *SectionOption) (map[string]string, error) {
 	result := map[string]string{}
+	passed := map[*SectionOption]bool{}
+	given := map[*SectionOption]bool{}
 
 	util.Logger.Debug().Msgf("Env is %+v", env)
 	util.Logger.Debug().Msgf("Options is %+v", options)
@@ -114,38 +126,139 @@ func (n *SectionPointer) parseOptionsWithResolutionFunc(options map[string]strin
 		v := util.SubEnv(env, v)
 
 		if option != nil {
-			optionValue := option.OptionType.Normalise(v)
+			// Options which weren't given are left empty, rather than failing their type's checks.
+			if v == "" {
+				result[option.OptionAs] = ""
+				continue
+			}
 
-			util.Logger.Debug().Msgf("Parsing option %s value %s", option.OptionName, optionValue)
+			if err := parseOption(option, v, env, result); err != nil {
+				return nil, err
+			}
 
-			if rt, ok := option.OptionType.(OptionTypeRuntime); ok {
-				wd, err := os.Getwd()
-				if err != nil {
-					return nil, err
-				}
+			// False booleans count as not given for requires and conflicts, as flags are false unless they're passed.
+			passed[option] = true
+			given[option] = option.OptionType.InputType() != "bool" || option.OptionType.Normalise(v) == "true"
+		}
+	}
+
+	// Options which weren't given take their default. Otherwise they're set empty, or false for booleans, so scripts can use them.
+	for _, option := range n.Options {
+		if passed[option] {
+			continue
+		}
+
+		if option.OptionDefault.Valid && option.OptionDefault.String != "" {
+			if err := parseOption(option, option.OptionDefault.String, env, result); err != nil {
+				return nil, err
+			}
+		} else if option.OptionType.InputType() == "bool" {
+			result[option.OptionAs] = "false"
+		} else {
+			result[option.OptionAs] = ""
+		}
+	}
+
+	if err := n.checkOptionRelationships(passed, given, conditionEnv, result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Normalises and validates the option's value, adding it and any variables it expands to into result.
+func parseOption(option *SectionOption, v string, env map[string]string, result map[string]string) error {
+	optionValue := option.OptionType.Normalise(v)
+
+	util.Logger.Debug().Msgf("Parsing option %s value %s", option.OptionName, optionValue)
+
+	if rt, ok := option.OptionType.(OptionTypeRuntime); ok {
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+
+		ov, err := rt.NormaliseToPath(v, wd)
+
+		if err != nil {
+			return err
+		}
+
+		optionValue = ov
+	}
 
-				ov, err := rt.NormaliseToPath(v, wd)
+	if err := option.OptionType.Validate(optionValue); err != nil {
+		return fmt.Errorf("%s: %w", option.OptionName, err)
+	}
+
+	result[option.OptionAs] = fmt.Sprintf("%v", option.OptionType.ResolvedValue(optionValue))
+
+	for k, v := range env {
+		result[option.OptionAs] = strings.Replace(result[option.OptionAs], "$"+k, v, -1)
+	}
 
+	if expanded, ok := option.OptionType.(OptionTypeExpanded); ok {
+		for name, value := range expanded.Expand(option.OptionAs, optionValue) {
+			result[name] = value
+		}
+	}
+
+	return nil
+}
+
+// Enforces required and required-if against the options which were passed, and requires and conflicts against those given.
+func (n *SectionPointer) checkOptionRelationships(passed map[*SectionOption]bool, given map[*SectionOption]bool, env map[string]string, result map[string]string) error {
+	vars := map[string]string{}
+	maps.Copy(vars, env)
+	maps.Copy(vars, result)
+
+	for _, option := range n.Options {
+		if !passed[option] {
+			if option.OptionRequired && !option.OptionDefault.Valid {
+				return fmt.Errorf("%s: a value is required", option.OptionName)
+			}
+
+			if option.RequiredIf != "" {
+				required, err := evaluateRequiredIf(option.RequiredIf, vars)
 				if err != nil {
-					return nil, err
+					return fmt.Errorf("%s: %w", option.OptionName, err)
 				}
 
-				optionValue = ov
+				if required {
+					return fmt.Errorf("%s: a value is required when %s", option.OptionName, option.RequiredIf)
+				}
 			}
 
-			if err := option.OptionType.Validate(optionValue); err != nil {
-				return nil, fmt.Errorf("%s: %w", option.OptionName, err)
-			}
+			continue
+		}
 
-			result[option.OptionAs] = fmt.Sprintf("%v", option.OptionType.ResolvedValue(optionValue))
+		if !given[option] {
+			continue
+		}
 
-			for k, v := range env {
-				result[option.OptionAs] = strings.Replace(result[option.OptionAs], "$"+k, v, -1)
+		for _, name := range option.Requires {
+			if other := n.GetOption(name); other != nil && !given[other] {
+				return fmt.Errorf("%s: requires %s", option.OptionName, name)
+			}
+		}
+
+		for _, name := range option.Conflicts {
+			if other := n.GetOption(name); other != nil && given[other] {
+				return fmt.Errorf("%s: can't be used with %s", option.OptionName, name)
 			}
 		}
 	}
 
-	return result, nil
+	return nil
+}
+
+func evaluateRequiredIf(source string, vars map[string]string) (bool, error) {
+	expression, err := condition.Parse(source)
+	if err != nil {
+		return false, fmt.Errorf("invalid required-if %q: %w", source, err)
+	}
+
+	return expression.Evaluate(condition.Environment{Vars: vars})
 }
 
 func FindSectionInDocument(parent goldast.Node, name string) *SectionPointer {
@@ -240,6 +353,13 @@ func PruneDocumentToSection(doc goldast.Node, sectionName string) *goldast.Docum
 	var sectionPointer *SectionPointer = FindSectionInDocument(doc, sectionName)
 
 	newDoc := goldast.NewDocument()
+
+	// Document level requirements still apply to the section. They're copied, as appending moves the node,
+	// and the document may be pruned again.
+	for _, requires := range getDocumentRequires(doc) {
+		newDoc.AppendChild(newDoc, CopyNode(requires))
+	}
+
 	newDoc.AppendChild(newDoc, sectionPointer)
 	PopulateSkipTargets(newDoc)
 
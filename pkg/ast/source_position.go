@@ -0,0 +1,58 @@
+package ast
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	goldast "github.com/yuin/goldmark/ast"
+)
+
+// Where something was declared in a markdown file.
+type SourcePosition struct {
+	File string `json:"file"`
+	Line int    `json:"line"` // 1-based.
+}
+
+// Returns the position of the first line of code inside a fenced code block.
+func CodeBlockPosition(file string, fcb *goldast.FencedCodeBlock, source []byte) SourcePosition {
+	if fcb.Lines().Len() == 0 {
+		return SourcePosition{File: file}
+	}
+
+	start := fcb.Lines().At(0).Start
+
+	return SourcePosition{File: file, Line: bytes.Count(source[:start], []byte("\n")) + 1}
+}
+
+// Returns true when the line is known.
+func (p SourcePosition) IsValid() bool {
+	return p.Line > 0
+}
+
+// Returns the position the given number of lines further on.
+func (p SourcePosition) Offset(lines int) SourcePosition {
+	return SourcePosition{File: p.File, Line: p.Line + lines}
+}
+
+// Returns the path of the file, relative to the working directory when it's underneath it.
+func (p SourcePosition) RelativeFile() string {
+	if !filepath.IsAbs(p.File) {
+		return p.File
+	}
+
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, p.File); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+
+	return p.File
+}
+
+// Formats the position as file:line, which editors and terminals can jump to.
+func (p SourcePosition) String() string {
+	return fmt.Sprintf("%s:%d", p.RelativeFile(), p.Line)
+}
@@ -0,0 +1,204 @@
+package bundle
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	rundown "github.com/elseano/rundown/pkg"
+)
+
+// A bundle is a copy of the rundown binary with documents appended as a zip archive. The archive is followed by a
+// trailer giving its size, so the binary can find it when it starts:
+//
+//	[rundown binary][zip archive][archive size, 8 bytes][magic, 8 bytes]
+const magic = "RDBUNDLE"
+
+const trailerSize = 8 + len(magic)
+
+const manifestName = "rundown-bundle.json"
+
+// Describes the bundle, and is stored in the archive alongside the documents.
+type Manifest struct {
+	Name string `json:"name"` // The name the tool is run as, used in its help.
+	Main string `json:"main"` // The document the tool runs, which imports the others.
+}
+
+type Bundle struct {
+	Manifest
+
+	// The bundled documents, named relative to the main document.
+	Files fs.FS
+}
+
+// Returns the bundle appended to the executable, or nil if it hasn't got one.
+func Read(executable string) (*Bundle, error) {
+	file, err := os.Open(executable)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	end, archiveSize, err := readTrailer(file)
+	if err != nil || archiveSize == 0 {
+		return nil, err
+	}
+
+	archive := make([]byte, archiveSize)
+	if _, err := file.ReadAt(archive, end); err != nil {
+		return nil, fmt.Errorf("reading bundle: %w", err)
+	}
+
+	files, err := zip.NewReader(bytes.NewReader(archive), archiveSize)
+	if err != nil {
+		return nil, fmt.Errorf("reading bundle: %w", err)
+	}
+
+	manifestData, err := fs.ReadFile(files, manifestName)
+	if err != nil {
+		return nil, fmt.Errorf("reading bundle: %w", err)
+	}
+
+	bundle := &Bundle{Files: files}
+	if err := json.Unmarshal(manifestData, &bundle.Manifest); err != nil {
+		return nil, fmt.Errorf("reading bundle: %w", err)
+	}
+
+	return bundle, nil
+}
+
+// Writes a copy of the executable with the files appended. Executables which are already bundles have their
+// documents replaced.
+func Write(w io.Writer, executable string, manifest Manifest, files map[string][]byte) error {
+	file, err := os.Open(executable)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	end, _, err := readTrailer(file)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(w, io.NewSectionReader(file, 0, end)); err != nil {
+		return err
+	}
+
+	archive := bytes.Buffer{}
+	writer := zip.NewWriter(&archive)
+
+	manifestData, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	names := []string{}
+	for name := range files {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range append([]string{manifestName}, names...) {
+		data := manifestData
+		if name != manifestName {
+			data = files[name]
+		}
+
+		entry, err := writer.Create(name)
+		if err != nil {
+			return err
+		}
+
+		if _, err := entry.Write(data); err != nil {
+			return err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	trailer := make([]byte, 8, trailerSize)
+	binary.LittleEndian.PutUint64(trailer, uint64(archive.Len()))
+	trailer = append(trailer, magic...)
+
+	if _, err := archive.WriteTo(w); err != nil {
+		return err
+	}
+
+	_, err = w.Write(trailer)
+	return err
+}
+
+// Returns the document and its imports, named relative to the document. Imports must be in the document's
+// directory or below it, as bundles don't keep where the documents came from.
+func DocumentFiles(filename string) (map[string][]byte, error) {
+	loaded, err := rundown.Load(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Dir(filename)
+	files := map[string][]byte{}
+
+	documents := append([]*rundown.LoadedDocument{loaded.MasterDocument}, loaded.ImportedDocuments...)
+	for _, document := range documents {
+		name, err := filepath.Rel(dir, document.Filename)
+		if err != nil || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("%s is outside %s, so it can't be bundled", document.Filename, dir)
+		}
+
+		// The loaded source has had its front matter removed, so the file is read again.
+		data, err := ioutil.ReadFile(document.Filename)
+		if err != nil {
+			return nil, err
+		}
+
+		files[filepath.ToSlash(name)] = data
+	}
+
+	return files, nil
+}
+
+// Returns where the executable ends, and the size of the archive appended to it, which is zero if it hasn't got one.
+func readTrailer(file *os.File) (int64, int64, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	size := info.Size()
+	if size < int64(trailerSize) {
+		return size, 0, nil
+	}
+
+	trailer := make([]byte, trailerSize)
+	if _, err := file.ReadAt(trailer, size-int64(trailerSize)); err != nil {
+		return 0, 0, err
+	}
+
+	if string(trailer[8:]) != magic {
+		return size, 0, nil
+	}
+
+	archiveSize := int64(binary.LittleEndian.Uint64(trailer))
+	if archiveSize <= 0 || archiveSize > size-int64(trailerSize) {
+		return 0, 0, errors.New("reading bundle: the archive size is invalid")
+	}
+
+	return size - int64(trailerSize) - archiveSize, archiveSize, nil
+}
@@ -0,0 +1,102 @@
+package bundle
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	rundown "github.com/elseano/rundown/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, filename string, contents string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(filename), 0755))
+	require.NoError(t, os.WriteFile(filename, []byte(contents), 0644))
+}
+
+func TestBundle(t *testing.T) {
+	dir := t.TempDir()
+
+	executable := filepath.Join(dir, "rundown")
+	writeFile(t, executable, "not really an executable")
+
+	writeFile(t, filepath.Join(dir, "docs", "tool.md"), "---\nimports:\n  - prefix: lib\n    file: lib/extra.md\n---\n\n# Deploy <r section=\"deploy\"/>\n")
+	writeFile(t, filepath.Join(dir, "docs", "lib", "extra.md"), "# Hello <r section=\"hello\"/>\n")
+
+	files, err := DocumentFiles(filepath.Join(dir, "docs", "tool.md"))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"tool.md", "lib/extra.md"}, keys(files))
+
+	t.Run("round trip", func(t *testing.T) {
+		tool := filepath.Join(dir, "mytool")
+		writeBundle(t, tool, executable, Manifest{Name: "mytool", Main: "tool.md"}, files)
+
+		contents, err := os.ReadFile(tool)
+		require.NoError(t, err)
+		assert.True(t, bytes.HasPrefix(contents, []byte("not really an executable")))
+
+		bundle, err := Read(tool)
+		require.NoError(t, err)
+		require.NotNil(t, bundle)
+		assert.Equal(t, Manifest{Name: "mytool", Main: "tool.md"}, bundle.Manifest)
+
+		loaded, err := rundown.LoadFS(bundle.Files, bundle.Main, "/work")
+		require.NoError(t, err)
+		assert.Equal(t, "/work/tool.md", loaded.MasterDocument.Filename)
+		assert.Equal(t, "/work/extra.md", loaded.ImportedDocuments[0].Filename)
+		assert.Len(t, loaded.GetSections(), 2)
+	})
+
+	t.Run("rebundling replaces the documents", func(t *testing.T) {
+		first := filepath.Join(dir, "first")
+		writeBundle(t, first, executable, Manifest{Name: "first", Main: "tool.md"}, files)
+
+		second := filepath.Join(dir, "second")
+		writeBundle(t, second, first, Manifest{Name: "second", Main: "tool.md"}, map[string][]byte{"tool.md": files["tool.md"]})
+
+		bundle, err := Read(second)
+		require.NoError(t, err)
+		assert.Equal(t, "second", bundle.Name)
+
+		_, err = bundle.Files.Open("lib/extra.md")
+		assert.Error(t, err)
+
+		firstInfo, _ := os.Stat(first)
+		secondInfo, _ := os.Stat(second)
+		assert.Less(t, secondInfo.Size(), firstInfo.Size())
+	})
+
+	t.Run("not a bundle", func(t *testing.T) {
+		bundle, err := Read(executable)
+		assert.NoError(t, err)
+		assert.Nil(t, bundle)
+	})
+
+	t.Run("imports outside the document's directory", func(t *testing.T) {
+		writeFile(t, filepath.Join(dir, "docs", "outside.md"), "---\nimports:\n  - file: ../shared.md\n---\n")
+		writeFile(t, filepath.Join(dir, "shared.md"), "# Shared <r section=\"shared\"/>\n")
+
+		_, err := DocumentFiles(filepath.Join(dir, "docs", "outside.md"))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "so it can't be bundled")
+	})
+}
+
+func writeBundle(t *testing.T, filename string, executable string, manifest Manifest, files map[string][]byte) {
+	file, err := os.Create(filename)
+	require.NoError(t, err)
+	defer file.Close()
+
+	require.NoError(t, Write(file, executable, manifest, files))
+}
+
+func keys(m map[string][]byte) []string {
+	result := []string{}
+	for k := range m {
+		result = append(result, k)
+	}
+
+	return result
+}
@@ -0,0 +1,301 @@
+// Evaluates if= conditions without starting a shell, such as `$OPT_ENV == 'prod' && !empty($TOKEN)`.
+// Conditions which aren't expressions are shell scripts, and Parse says so with ErrNotExpression.
+package condition
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/elseano/rundown/pkg/util"
+)
+
+// Returned by Parse when the condition isn't an expression, and should be run as a shell script instead.
+var ErrNotExpression = errors.New("not an expression")
+
+// A parsed expression, ready to evaluate.
+type Condition struct {
+	Source string
+	root   node
+}
+
+// What an expression is evaluated against.
+type Environment struct {
+	// The environment variables, as scripts would see them.
+	Vars map[string]string
+
+	// The directory relative paths are resolved against.
+	Dir string
+}
+
+// Parses the condition. Returns ErrNotExpression when it isn't an expression, such as `test -f go.mod`.
+// Other errors are for expressions which are invalid, such as calling an unknown function.
+func Parse(source string) (*Condition, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, ErrNotExpression
+	}
+
+	p := &parser{tokens: tokens}
+
+	root, err := p.parseOr()
+	if err != nil || p.peek().kind != tokenEOF {
+		return nil, ErrNotExpression
+	}
+
+	// A lone variable or string is a command to the shell, i.e. if="$CHECK_COMMAND". Only true and false stand alone.
+	if v, ok := root.(valueNode); ok && v.kind != tokenIdent {
+		return nil, ErrNotExpression
+	}
+
+	if err := check(root); err != nil {
+		return nil, err
+	}
+
+	return &Condition{Source: source, root: root}, nil
+}
+
+// Evaluates the condition, returning whether it's true.
+func (c *Condition) Evaluate(env Environment) (bool, error) {
+	result, err := c.root.eval(env)
+	if err != nil {
+		return false, err
+	}
+
+	return truthy(result), nil
+}
+
+// Expressions produce either a string or a bool.
+type value interface{}
+
+// Strings are true unless they're empty, "0", "false" or "no".
+func truthy(v value) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case string:
+		switch strings.ToLower(v) {
+		case "", "0", "false", "no":
+			return false
+		}
+
+		return true
+	}
+
+	return false
+}
+
+func toString(v value) string {
+	switch v := v.(type) {
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		return v
+	}
+
+	return ""
+}
+
+type node interface {
+	eval(env Environment) (value, error)
+}
+
+// A literal, variable or string.
+type valueNode struct {
+	kind tokenKind
+	text string
+}
+
+func (n valueNode) eval(env Environment) (value, error) {
+	switch n.kind {
+	case tokenVariable, tokenInterpolatedString:
+		return util.SubEnv(env.Vars, n.text), nil
+	case tokenIdent:
+		return n.text == "true", nil
+	}
+
+	return n.text, nil
+}
+
+type notNode struct {
+	operand node
+}
+
+func (n notNode) eval(env Environment) (value, error) {
+	v, err := n.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	return !truthy(v), nil
+}
+
+// The && and || operators, which stop as soon as the result is known.
+type logicalNode struct {
+	operator    string
+	left, right node
+}
+
+func (n logicalNode) eval(env Environment) (value, error) {
+	left, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	if truthy(left) == (n.operator == "||") {
+		return truthy(left), nil
+	}
+
+	right, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	return truthy(right), nil
+}
+
+type comparisonNode struct {
+	operator    string
+	left, right node
+}
+
+func (n comparisonNode) eval(env Environment) (value, error) {
+	left, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.operator {
+	case "=~", "!~":
+		matcher, err := regexp.Compile(toString(right))
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q: %w", toString(right), err)
+		}
+
+		return matcher.MatchString(toString(left)) == (n.operator == "=~"), nil
+	}
+
+	return compare(n.operator, left, right), nil
+}
+
+// Compares numerically when both sides are numbers, and as strings otherwise.
+// Comparing with true or false compares the other side's truthiness.
+func compare(operator string, left value, right value) bool {
+	_, leftBool := left.(bool)
+	_, rightBool := right.(bool)
+
+	if leftBool || rightBool {
+		switch operator {
+		case "==":
+			return truthy(left) == truthy(right)
+		case "!=":
+			return truthy(left) != truthy(right)
+		}
+	}
+
+	cmp := strings.Compare(toString(left), toString(right))
+
+	leftNum, leftErr := strconv.ParseFloat(toString(left), 64)
+	rightNum, rightErr := strconv.ParseFloat(toString(right), 64)
+
+	if leftErr == nil && rightErr == nil {
+		switch {
+		case leftNum < rightNum:
+			cmp = -1
+		case leftNum > rightNum:
+			cmp = 1
+		default:
+			cmp = 0
+		}
+	}
+
+	switch operator {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+
+	return false
+}
+
+type callNode struct {
+	name string
+	args []node
+}
+
+func (n callNode) eval(env Environment) (value, error) {
+	args := []string{}
+
+	for _, arg := range n.args {
+		v, err := arg.eval(env)
+		if err != nil {
+			return nil, err
+		}
+
+		args = append(args, toString(v))
+	}
+
+	return Functions[n.name].Call(env, args), nil
+}
+
+// Checks the functions called exist and have the right number of arguments, and that regular expression literals compile.
+func check(n node) error {
+	switch n := n.(type) {
+	case notNode:
+		return check(n.operand)
+
+	case logicalNode:
+		if err := check(n.left); err != nil {
+			return err
+		}
+
+		return check(n.right)
+
+	case comparisonNode:
+		if err := check(n.left); err != nil {
+			return err
+		}
+
+		if pattern, ok := n.right.(valueNode); ok && (n.operator == "=~" || n.operator == "!~") && pattern.kind == tokenString {
+			if _, err := regexp.Compile(pattern.text); err != nil {
+				return fmt.Errorf("invalid regular expression %q: %w", pattern.text, err)
+			}
+		}
+
+		return check(n.right)
+
+	case callNode:
+		function, ok := Functions[n.name]
+		if !ok {
+			return fmt.Errorf("unknown function %s()", n.name)
+		}
+
+		if len(n.args) != function.Args {
+			return fmt.Errorf("%s() takes %d arguments, got %d", n.name, function.Args, len(n.args))
+		}
+
+		for _, arg := range n.args {
+			if err := check(arg); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package condition
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Expressions and what they evaluate to, in the environment built by testEnvironment.
+var evaluateTests = []struct {
+	expression string
+	expected   bool
+}{
+	{`true`, true},
+	{`false`, false},
+	{`$OPT_ENV == 'prod'`, true},
+	{`$OPT_ENV != "prod"`, false},
+	{`"$OPT_ENV-eu" == 'prod-eu'`, true},
+	{`'$OPT_ENV' == 'prod'`, false},
+	{`${MISSING:-dev} == 'dev'`, true},
+	{`$OPT_ENV == 'prod' && !empty($TOKEN)`, false},
+	{`$OPT_ENV == 'prod' || !empty($TOKEN)`, true},
+	{`!($OPT_ENV == 'prod' && empty($TOKEN))`, false},
+	{`$COUNT > 9`, true},
+	{`$COUNT >= 10 && $COUNT <= 10`, true},
+	{`$COUNT < 9`, false},
+	{`'b' > 'a'`, true},
+	{`$VERSION =~ '^v\d+\.\d+'`, true},
+	{`$VERSION !~ '^v2'`, true},
+	{`$DEBUG == true`, false},
+	{`!$DEBUG`, true},
+	{`defined('TOKEN') && !defined('MISSING')`, true},
+	{`contains($VERSION, '.2.') && starts_with($VERSION, 'v') && ends_with($VERSION, '3')`, true},
+	{`file_exists('go.mod') && !file_exists('pkg')`, true},
+	{`dir_exists('pkg') && !dir_exists('go.mod')`, true},
+	{`command_exists('tool') && !command_exists('missing')`, true},
+	{`os() == '` + runtime.GOOS + `' && arch() == '` + runtime.GOARCH + `'`, true},
+	{`ci()`, false},
+}
+
+func testEnvironment(t *testing.T) Environment {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module x"), 0600))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "pkg"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tool"), []byte("#!/bin/sh"), 0700))
+
+	return Environment{
+		Vars: map[string]string{
+			"OPT_ENV": "prod",
+			"TOKEN":   "",
+			"COUNT":   "10",
+			"VERSION": "v1.2.3",
+			"DEBUG":   "false",
+			"PATH":    dir,
+		},
+		Dir: dir,
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	env := testEnvironment(t)
+
+	for _, test := range evaluateTests {
+		t.Run(test.expression, func(t *testing.T) {
+			expression, err := Parse(test.expression)
+			require.NoError(t, err)
+
+			result, err := expression.Evaluate(env)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expected, result)
+		})
+	}
+}
+
+// The bash version of each expression must agree with Evaluate.
+func TestShell(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash isn't available")
+	}
+
+	env := testEnvironment(t)
+
+	for _, test := range evaluateTests {
+		t.Run(test.expression, func(t *testing.T) {
+			expression, err := Parse(test.expression)
+			require.NoError(t, err)
+
+			script := ShellFunctions + "\nif " + expression.Shell() + "; then exit 0; else exit 1; fi\n"
+
+			cmd := exec.Command(bash, "-euc", script)
+			cmd.Dir = env.Dir
+			cmd.Env = []string{"PATH=" + env.Vars["PATH"] + string(os.PathListSeparator) + os.Getenv("PATH")}
+			for name, value := range env.Vars {
+				if name != "PATH" {
+					cmd.Env = append(cmd.Env, name+"="+value)
+				}
+			}
+
+			output, err := cmd.CombinedOutput()
+			if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 && len(output) == 0 {
+				err = nil
+				assert.False(t, test.expected, expression.Shell())
+			} else {
+				assert.True(t, test.expected, expression.Shell())
+			}
+
+			assert.NoError(t, err, string(output))
+		})
+	}
+}
+
+func TestCI(t *testing.T) {
+	expression, err := Parse(`ci() == 'github'`)
+	require.NoError(t, err)
+
+	result, err := expression.Evaluate(Environment{Vars: map[string]string{"GITHUB_ACTIONS": "true"}})
+	require.NoError(t, err)
+	assert.True(t, result)
+}
+
+func TestShellScriptsAreNotExpressions(t *testing.T) {
+	for _, script := range []string{
+		`test -f go.mod`,
+		`[ "$OPT_ENV" = "prod" ]`,
+		`grep -q foo bar.txt`,
+		`which docker`,
+		`$CHECK_COMMAND`,
+		`'quoted'`,
+		"true\nfalse",
+		`(cd pkg && make)`,
+		`exit 1`,
+	} {
+		_, err := Parse(script)
+		assert.ErrorIs(t, err, ErrNotExpression, script)
+	}
+}
+
+func TestInvalidExpressions(t *testing.T) {
+	for _, expression := range []string{
+		`unknown($X)`,
+		`empty()`,
+		`$X =~ '('`,
+	} {
+		_, err := Parse(expression)
+		if assert.Error(t, err, expression) {
+			assert.NotErrorIs(t, err, ErrNotExpression, expression)
+		}
+	}
+}
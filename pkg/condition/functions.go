@@ -0,0 +1,116 @@
+package condition
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// A function available to expressions.
+type Function struct {
+	Args int
+	Call func(env Environment, args []string) value
+}
+
+// The functions available to expressions, by name. None of them have side effects.
+var Functions = map[string]Function{
+	// empty($TOKEN) is true when the value is an empty string.
+	"empty": {1, func(env Environment, args []string) value {
+		return args[0] == ""
+	}},
+
+	// defined('TOKEN') is true when the environment variable is set, even if it's empty.
+	"defined": {1, func(env Environment, args []string) value {
+		_, ok := env.Vars[args[0]]
+		return ok
+	}},
+
+	"contains": {2, func(env Environment, args []string) value {
+		return strings.Contains(args[0], args[1])
+	}},
+
+	"starts_with": {2, func(env Environment, args []string) value {
+		return strings.HasPrefix(args[0], args[1])
+	}},
+
+	"ends_with": {2, func(env Environment, args []string) value {
+		return strings.HasSuffix(args[0], args[1])
+	}},
+
+	"file_exists": {1, func(env Environment, args []string) value {
+		info, err := os.Stat(env.path(args[0]))
+		return err == nil && !info.IsDir()
+	}},
+
+	"dir_exists": {1, func(env Environment, args []string) value {
+		info, err := os.Stat(env.path(args[0]))
+		return err == nil && info.IsDir()
+	}},
+
+	// command_exists('docker') is true when the command is on the PATH scripts are run with.
+	"command_exists": {1, func(env Environment, args []string) value {
+		return env.lookCommand(args[0])
+	}},
+
+	// The operating system, as Go names it: linux, darwin, windows...
+	"os": {0, func(env Environment, args []string) value {
+		return runtime.GOOS
+	}},
+
+	// The CPU architecture, as Go names it: amd64, arm64...
+	"arch": {0, func(env Environment, args []string) value {
+		return runtime.GOARCH
+	}},
+
+	// The CI system being run in: github, gitlab or unknown. Empty outside of CI, so ci() alone checks for CI.
+	"ci": {0, func(env Environment, args []string) value {
+		return env.ci()
+	}},
+}
+
+// Resolves a path relative to the environment's directory.
+func (e Environment) path(path string) string {
+	if filepath.IsAbs(path) || e.Dir == "" {
+		return path
+	}
+
+	return filepath.Join(e.Dir, path)
+}
+
+// Returns true if the command is an executable on the environment's PATH, or is a path to one.
+func (e Environment) lookCommand(command string) bool {
+	isExecutable := func(path string) bool {
+		info, err := os.Stat(path)
+		return err == nil && !info.IsDir() && info.Mode()&0111 != 0
+	}
+
+	if strings.Contains(command, "/") {
+		return isExecutable(e.path(command))
+	}
+
+	for _, dir := range filepath.SplitList(e.Vars["PATH"]) {
+		if dir != "" && isExecutable(filepath.Join(dir, command)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e Environment) ci() string {
+	_, gitlab := e.Vars["GITLAB_CI"]
+	_, github := e.Vars["GITHUB_ACTIONS"]
+	_, ci := e.Vars["CI"]
+
+	switch {
+	case gitlab:
+		return "gitlab"
+	case github:
+		return "github"
+	case ci:
+		return "unknown"
+	}
+
+	return ""
+}
@@ -0,0 +1,153 @@
+package condition
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenVariable
+	tokenString
+	tokenInterpolatedString
+	tokenNumber
+	tokenIdent
+	tokenOperator
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "!~", "<", ">", "!", "(", ")", ","}
+
+// Splits the source into tokens. Fails on anything an expression can't contain, such as shell flags or brackets.
+func tokenize(source string) ([]token, error) {
+	tokens := []token{}
+	runes := []rune(source)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '$':
+			end, err := scanVariable(runes, i)
+			if err != nil {
+				return nil, err
+			}
+
+			tokens = append(tokens, token{tokenVariable, string(runes[i:end])})
+			i = end
+
+		case r == '\'' || r == '"':
+			value, end, err := scanString(runes, i)
+			if err != nil {
+				return nil, err
+			}
+
+			kind := tokenString
+			if r == '"' {
+				kind = tokenInterpolatedString
+			}
+
+			tokens = append(tokens, token{kind, value})
+			i = end
+
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			end := i + 1
+			for end < len(runes) && (unicode.IsDigit(runes[end]) || runes[end] == '.') {
+				end++
+			}
+
+			tokens = append(tokens, token{tokenNumber, string(runes[i:end])})
+			i = end
+
+		case isIdentStart(r):
+			end := i + 1
+			for end < len(runes) && isIdentPart(runes[end]) {
+				end++
+			}
+
+			tokens = append(tokens, token{tokenIdent, string(runes[i:end])})
+			i = end
+
+		default:
+			operator := ""
+			for _, op := range operators {
+				if strings.HasPrefix(string(runes[i:]), op) {
+					operator = op
+					break
+				}
+			}
+
+			if operator == "" {
+				return nil, fmt.Errorf("unexpected %q", r)
+			}
+
+			tokens = append(tokens, token{tokenOperator, operator})
+			i += len([]rune(operator))
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF}), nil
+}
+
+// Returns the end of a $NAME or ${NAME...} variable starting at i.
+func scanVariable(runes []rune, i int) (int, error) {
+	if i+1 < len(runes) && runes[i+1] == '{' {
+		for end := i + 2; end < len(runes); end++ {
+			if runes[end] == '}' {
+				return end + 1, nil
+			}
+		}
+
+		return 0, fmt.Errorf("unterminated variable")
+	}
+
+	end := i + 1
+	for end < len(runes) && isIdentPart(runes[end]) {
+		end++
+	}
+
+	if end == i+1 {
+		return 0, fmt.Errorf("expected a variable name after $")
+	}
+
+	return end, nil
+}
+
+// Returns the contents of the quoted string starting at i, and where it ends. A backslash escapes the quote
+// or another backslash, and is otherwise kept, so regular expressions such as '\d+' don't need escaping.
+func scanString(runes []rune, i int) (string, int, error) {
+	quote := runes[i]
+	value := strings.Builder{}
+
+	for end := i + 1; end < len(runes); end++ {
+		switch {
+		case runes[end] == '\\' && end+1 < len(runes) && (runes[end+1] == quote || runes[end+1] == '\\'):
+			end++
+			value.WriteRune(runes[end])
+		case runes[end] == quote:
+			return value.String(), end + 1, nil
+		default:
+			value.WriteRune(runes[end])
+		}
+	}
+
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isIdentPart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
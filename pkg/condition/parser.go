@@ -0,0 +1,168 @@
+package condition
+
+import "fmt"
+
+// A recursive descent parser. From lowest to highest precedence:
+//
+//	or         = and { "||" and }
+//	and        = not { "&&" not }
+//	not        = "!" not | comparison
+//	comparison = operand [ ( "==" | "!=" | "<" | "<=" | ">" | ">=" | "=~" | "!~" ) operand ]
+//	operand    = variable | string | number | "true" | "false" | name "(" [ or { "," or } ] ")" | "(" or ")"
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+
+	return t
+}
+
+// Consumes the next token if it's the given operator.
+func (p *parser) accept(operator string) bool {
+	if t := p.peek(); t.kind == tokenOperator && t.text == operator {
+		p.pos++
+		return true
+	}
+
+	return false
+}
+
+func (p *parser) expect(operator string) error {
+	if !p.accept(operator) {
+		return fmt.Errorf("expected %q", operator)
+	}
+
+	return nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.accept("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		left = logicalNode{operator: "||", left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.accept("&&") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		left = logicalNode{operator: "&&", left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.accept("!") {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		return notNode{operand: operand}, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, operator := range []string{"==", "!=", "<=", ">=", "<", ">", "=~", "!~"} {
+		if p.accept(operator) {
+			right, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+
+			return comparisonNode{operator: operator, left: left, right: right}, nil
+		}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseOperand() (node, error) {
+	if p.accept("(") {
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		return inner, p.expect(")")
+	}
+
+	t := p.next()
+
+	switch t.kind {
+	case tokenVariable, tokenString, tokenInterpolatedString, tokenNumber:
+		return valueNode{kind: t.kind, text: t.text}, nil
+
+	case tokenIdent:
+		if t.text == "true" || t.text == "false" {
+			return valueNode{kind: t.kind, text: t.text}, nil
+		}
+
+		// Any other name must be a function call, otherwise it's likely a shell command.
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+
+		call := callNode{name: t.text}
+
+		if p.accept(")") {
+			return call, nil
+		}
+
+		for {
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+
+			call.args = append(call.args, arg)
+
+			if p.accept(")") {
+				return call, nil
+			}
+
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("unexpected %q", t.text)
+}
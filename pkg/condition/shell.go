@@ -0,0 +1,213 @@
+package condition
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/elseano/rundown/pkg/util"
+)
+
+// Bash functions needed by the commands Shell returns. Scripts define these once, before any conditions.
+const ShellFunctions = `# Strings are true unless they're empty, "0", "false" or "no".
+rd_truthy() {
+  case "$(printf '%s' "$1" | tr '[:upper:]' '[:lower:]')" in
+    ""|0|false|no) return 1 ;;
+  esac
+}
+
+rd_bool() {
+  if rd_truthy "$1"; then echo true; else echo false; fi
+}
+
+rd_number() {
+  local number='^[-+]?([0-9]+\.?[0-9]*|\.[0-9]+)([eE][-+]?[0-9]+)?$'
+  [[ $1 =~ $number ]]
+}
+
+# Compares numerically when both sides are numbers, and as strings otherwise.
+rd_compare() {
+  local cmp=0
+  if rd_number "$2" && rd_number "$3"; then
+    cmp=$(awk -v l="$2" -v r="$3" 'BEGIN { print (l < r) ? -1 : (l > r) ? 1 : 0 }')
+  elif [[ $2 < $3 ]]; then
+    cmp=-1
+  elif [[ $2 > $3 ]]; then
+    cmp=1
+  fi
+  case "$1" in
+    "==") [ "$cmp" -eq 0 ] ;;
+    "!=") [ "$cmp" -ne 0 ] ;;
+    "<") [ "$cmp" -lt 0 ] ;;
+    "<=") [ "$cmp" -le 0 ] ;;
+    ">") [ "$cmp" -gt 0 ] ;;
+    ">=") [ "$cmp" -ge 0 ] ;;
+  esac
+}
+
+rd_matches() {
+  [[ $1 =~ $2 ]]
+}
+
+rd_defined() {
+  [ -n "${!1+x}" ]
+}
+
+rd_os() {
+  case "$(uname -s)" in
+    Linux) echo linux ;;
+    Darwin) echo darwin ;;
+    MINGW*|MSYS*|CYGWIN*) echo windows ;;
+    *) uname -s | tr '[:upper:]' '[:lower:]' ;;
+  esac
+}
+
+rd_arch() {
+  case "$(uname -m)" in
+    x86_64|amd64) echo amd64 ;;
+    aarch64|arm64) echo arm64 ;;
+    i?86) echo 386 ;;
+    armv*) echo arm ;;
+    *) uname -m ;;
+  esac
+}
+
+rd_ci() {
+  if [ -n "${GITLAB_CI+x}" ]; then echo gitlab
+  elif [ -n "${GITHUB_ACTIONS+x}" ]; then echo github
+  elif [ -n "${CI+x}" ]; then echo unknown
+  fi
+}
+`
+
+// How each function is written in bash. Functions returning a string give a command printing it.
+var shellFunctions = map[string]struct {
+	format  string
+	returns bool
+}{
+	"empty":          {`[ -z %s ]`, false},
+	"defined":        {`rd_defined %s`, false},
+	"contains":       {`[[ %s == *%s* ]]`, false},
+	"starts_with":    {`[[ %s == %s* ]]`, false},
+	"ends_with":      {`[[ %s == *%s ]]`, false},
+	"file_exists":    {`[ -f %s ]`, false},
+	"dir_exists":     {`[ -d %s ]`, false},
+	"command_exists": {`type -P %s >/dev/null 2>&1`, false},
+	"os":             {`rd_os`, true},
+	"arch":           {`rd_arch`, true},
+	"ci":             {`rd_ci`, true},
+}
+
+// Returns a bash command which succeeds when the condition is true, for scripts exported from a document.
+// The command relies on ShellFunctions. Regular expressions are matched by bash, which supports most of
+// what Go does once shorthands such as \d are expanded.
+func (c *Condition) Shell() string {
+	return shellTest(c.root)
+}
+
+func shellTest(n node) string {
+	switch n := n.(type) {
+	case valueNode:
+		if n.kind == tokenIdent {
+			return n.text
+		}
+
+		return "rd_truthy " + shellWord(n)
+
+	case notNode:
+		return "! { " + shellTest(n.operand) + "; }"
+
+	case logicalNode:
+		return "{ " + shellTest(n.left) + "; } " + n.operator + " { " + shellTest(n.right) + "; }"
+
+	case comparisonNode:
+		switch n.operator {
+		case "=~":
+			return "rd_matches " + shellWord(n.left) + " " + shellPattern(n.right)
+		case "!~":
+			return "! rd_matches " + shellWord(n.left) + " " + shellPattern(n.right)
+		}
+
+		if isBoolean(n.left) || isBoolean(n.right) {
+			switch n.operator {
+			case "==":
+				return fmt.Sprintf(`[ "$(rd_bool %s)" = "$(rd_bool %s)" ]`, shellWord(n.left), shellWord(n.right))
+			case "!=":
+				return fmt.Sprintf(`[ "$(rd_bool %s)" != "$(rd_bool %s)" ]`, shellWord(n.left), shellWord(n.right))
+			}
+		}
+
+		return fmt.Sprintf("rd_compare '%s' %s %s", n.operator, shellWord(n.left), shellWord(n.right))
+
+	case callNode:
+		function := shellFunctions[n.name]
+
+		if function.returns {
+			return fmt.Sprintf(`rd_truthy "$(%s)"`, function.format)
+		}
+
+		args := []interface{}{}
+		for _, arg := range n.args {
+			args = append(args, shellWord(arg))
+		}
+
+		return fmt.Sprintf(function.format, args...)
+	}
+
+	return "false"
+}
+
+// Returns the node as a single shell word. Booleans become "true" or "false", as they do when compared as strings.
+func shellWord(n node) string {
+	if v, ok := n.(valueNode); ok {
+		switch v.kind {
+		case tokenVariable, tokenInterpolatedString:
+			return util.SubEnvShellWord(v.text)
+		case tokenIdent:
+			return v.text
+		}
+
+		return util.ShellQuote(v.text)
+	}
+
+	if call, ok := n.(callNode); ok && shellFunctions[call.name].returns {
+		return `"$(` + shellFunctions[call.name].format + `)"`
+	}
+
+	return `"$(if ` + shellTest(n) + `; then echo true; else echo false; fi)"`
+}
+
+var regexpShorthands = strings.NewReplacer(`\\`, `\\`, `\d`, `[0-9]`, `\D`, `[^0-9]`, `\w`, `[[:alnum:]_]`, `\W`, `[^[:alnum:]_]`, `\s`, `[[:space:]]`, `\S`, `[^[:space:]]`)
+
+var shorthandInClass = regexp.MustCompile(`\[[^\]]*\\[dDwWsS]`)
+
+// Converts a regular expression to one bash's =~ understands, by expanding Go's shorthands such as \d.
+// Shorthands inside brackets are left alone, as they can't be expanded there.
+func ShellRegexp(pattern string) string {
+	if shorthandInClass.MatchString(pattern) {
+		return pattern
+	}
+
+	return regexpShorthands.Replace(pattern)
+}
+
+// Regular expression literals are converted for bash, others are matched as they are.
+func shellPattern(n node) string {
+	if v, ok := n.(valueNode); ok && v.kind == tokenString {
+		return util.ShellQuote(ShellRegexp(v.text))
+	}
+
+	return shellWord(n)
+}
+
+// Returns true if the node evaluates to a bool, rather than a string.
+func isBoolean(n node) bool {
+	switch n := n.(type) {
+	case valueNode:
+		return n.kind == tokenIdent
+	case callNode:
+		return !shellFunctions[n.name].returns
+	}
+
+	return true
+}
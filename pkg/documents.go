@@ -1,9 +1,14 @@
 package rundown
 
 import (
+	"errors"
 	"io"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/elseano/rundown/pkg/ast"
+	"github.com/elseano/rundown/pkg/errs"
 	"github.com/elseano/rundown/pkg/renderer"
 	"github.com/yuin/goldmark"
 	goldast "github.com/yuin/goldmark/ast"
@@ -43,11 +48,12 @@ func (doc *LoadedDocuments) GetSections() []*Section {
 }
 
 type LoadedDocument struct {
-	Filename string
-	Document goldast.Node
-	Source   []byte
-	Goldmark goldmark.Markdown
-	Context  *renderer.Context
+	Filename    string
+	Document    goldast.Node
+	Source      []byte
+	Goldmark    goldmark.Markdown
+	Context     *renderer.Context
+	FrontMatter *ast.FrontMatter
 }
 
 // Walks through the document and returns all the found SectionPointers
@@ -81,5 +87,49 @@ func (doc *LoadedDocument) GetInvokes() []*ast.InvokeBlock {
 }
 
 func (d *LoadedDocument) Render(outputStream io.Writer) error {
-	return d.Goldmark.Renderer().Render(outputStream, d.Source, d.Document)
+	return d.RenderNode(outputStream, d.Document)
+}
+
+// Renders the node, which is either the document or a part of it, then runs the cleanup blocks it reached.
+// Interrupting the run cancels it rather than exiting, so the cleanup blocks still get to run.
+func (d *LoadedDocument) RenderNode(outputStream io.Writer, node goldast.Node) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		for range signals {
+			d.Context.SetCancelled(true)
+		}
+	}()
+
+	defer func() {
+		signal.Stop(signals)
+		close(signals)
+	}()
+
+	renderer := d.Goldmark.Renderer()
+	err := renderer.Render(outputStream, d.Source, node)
+
+	cancelled := errors.Is(err, errs.ErrCancelled) || d.Context.Cancelled()
+
+	// Clear the cancellation so the cleanup scripts can run. Interrupting again cancels those too.
+	d.Context.SetCancelled(false)
+
+	for _, cleanup := range d.Context.TakeCleanups() {
+		if cleanup.OnCancel && !cancelled {
+			continue
+		}
+
+		cleanup.Triggered = true
+
+		if cleanupErr := renderer.Render(outputStream, d.Source, cleanup); cleanupErr != nil && err == nil {
+			err = cleanupErr
+		}
+	}
+
+	if cancelled && err == nil {
+		err = errs.ErrCancelled
+	}
+
+	return err
 }
@@ -12,3 +12,4 @@ func (e *ExecutionError) Error() string {
 
 var ErrStopOk = errors.New("StopOK")
 var ErrStopFail = errors.New("StopFail")
+var ErrCancelled = errors.New("Cancelled")
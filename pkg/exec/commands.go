@@ -31,14 +31,14 @@ func ChangeCommentsToSpinnerCommands(sourceLang string, source []byte) []byte {
 	})
 }
 
-func AddEnvironmentCapture(sourceLang string, script *scripts.Script, captures []string) {
-	switch sourceLang {
-	case "bash", "sh", "fish":
-	default:
+// Adds commands to the end of the script which report the values of the environment variables back to rundown.
+// Languages whose interpreter has no capture-env command are left alone.
+func AddEnvironmentCapture(script *scripts.Script, captures []string) {
+	if script.Interpreter == nil || script.Interpreter.CaptureEnv == "" {
 		return
 	}
 
 	for _, envName := range captures {
-		script.AppendCommand(fmt.Sprintf("echo -n -e \"\x1b]R;SETENV %s=$%s\x9c\"", envName, envName))
+		script.AppendCommand(script.Interpreter.CaptureEnvCommand(envName))
 	}
 }
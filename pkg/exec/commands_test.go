@@ -3,6 +3,7 @@ package exec
 import (
 	"testing"
 
+	"github.com/elseano/rundown/pkg/exec/scripts"
 	"github.com/stretchr/testify/require"
 )
 
@@ -14,3 +15,17 @@ func TestChangeCommentsToSpinnerCommands(t *testing.T) {
 	result = ChangeCommentsToSpinnerCommands("bash", []byte("if true; then\n  #> Do something\n  run_me\n fi"))
 	require.Equal(t, "if true; then\n  echo -n -e \"\x1b]R;SETSPINNER Do something\x9c\"\n  run_me\n fi", string(result))
 }
+
+func TestAddEnvironmentCapture(t *testing.T) {
+	script, err := scripts.NewScript("python3", "python", []byte("x = 1"))
+	require.NoError(t, err)
+
+	AddEnvironmentCapture(script, []string{"FOO"})
+	require.Contains(t, string(script.Suffix), `R;SETENV FOO=" + __import__("os").environ.get("FOO", "")`)
+
+	script, err = scripts.NewScript("cat", "sql", []byte("SELECT 1"))
+	require.NoError(t, err)
+
+	AddEnvironmentCapture(script, []string{"FOO"})
+	require.Nil(t, script.Suffix)
+}
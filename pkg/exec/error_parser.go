@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"fmt"
 	"regexp"
-	"strconv"
 	"strings"
 
 	"github.com/elseano/rundown/pkg/exec/scripts"
@@ -14,17 +13,37 @@ import (
 
 type ErrorSource struct {
 	Source []byte
-	Line   int
+	Line   int // 1-based, within Source.
 }
 
 type ErrorDetails struct {
 	Error       string
 	ErrorSource *ErrorSource
+
+	// Where the failure happened in the markdown, as file:line. Set by the caller, which knows where the script came from.
+	Location string
 }
 
+// Finds where the script failed, using the error parser for the script's language. Falls back to the
+// shell parser when the language's parser doesn't recognise the output.
 func ParseError(script *scripts.Script, stdout string) *ErrorDetails {
-	if index := strings.Index(stdout, script.AbsolutePath); index != -1 {
-		return parseError(stdout, index, script)
+	util.Logger.Debug().Fields(map[string]interface{}{"STDOUT": stdout}).Msgf("Looking up error in STDOUT")
+
+	parsers := []ErrorParser{}
+	if script.Interpreter != nil && script.Interpreter.ErrorFormat != "" {
+		if parser, ok := ErrorParsers[script.Interpreter.ErrorFormat]; ok {
+			parsers = append(parsers, parser)
+		} else {
+			util.Logger.Warn().Msgf("Unknown error-format %s", script.Interpreter.ErrorFormat)
+		}
+	}
+
+	parsers = append(parsers, ErrorParsers[DefaultErrorFormat])
+
+	for _, parser := range parsers {
+		if message, lines := parser.Parse(stdout, script.AbsolutePath); len(lines) > 0 {
+			return locateError(script, message, lines)
+		}
 	}
 
 	return &ErrorDetails{
@@ -34,31 +53,26 @@ func ParseError(script *scripts.Script, stdout string) *ErrorDetails {
 
 var lineMatcher = regexp.MustCompile(`[A-Za-z0-9_/\/-]+\:((\d+)| line\:? *(\d+))\: *(.*)`)
 
-func parseError(stdout string, errorIndex int, source *scripts.Script) *ErrorDetails {
-	util.Logger.Debug().Fields(map[string]interface{}{"STDOUT": stdout}).Msgf("Looking up error in STDOUT")
-
-	if matches := lineMatcher.FindStringSubmatch(stdout[errorIndex:]); matches != nil {
-		line := 0
-		if matches[2] != "" {
-			line, _ = strconv.Atoi(matches[2])
-		} else {
-			line, _ = strconv.Atoi(matches[3])
-		}
-
-		line -= lineOffset(source)
-
-		errText := matches[4]
-
-		return &ErrorDetails{
-			Error: errText,
-			ErrorSource: &ErrorSource{
-				Source: source.OriginalContents,
-				Line:   line,
-			},
+// Picks the innermost line which is part of the original script, rather than something rundown added.
+func locateError(script *scripts.Script, message string, lines []int) *ErrorDetails {
+	offset := lineOffset(script)
+	scriptLines := len(strings.Split(strings.TrimRight(string(script.OriginalContents), "\r\n"), "\n"))
+
+	for _, line := range lines {
+		line -= offset
+
+		if line >= 1 && line <= scriptLines {
+			return &ErrorDetails{
+				Error: message,
+				ErrorSource: &ErrorSource{
+					Source: script.OriginalContents,
+					Line:   line,
+				},
+			}
 		}
 	}
 
-	return &ErrorDetails{Error: stdout}
+	return &ErrorDetails{Error: message}
 }
 
 func (e *ErrorDetails) String(colors aurora.Aurora) string {
@@ -74,7 +88,7 @@ func (e *ErrorDetails) String(colors aurora.Aurora) string {
 			}
 
 			lineIndicator := " "
-			if e.ErrorSource.Line == i {
+			if e.ErrorSource.Line == i+1 {
 				lineIndicator = colors.Red("*").String()
 			}
 
@@ -82,7 +96,14 @@ func (e *ErrorDetails) String(colors aurora.Aurora) string {
 		}
 
 		output.WriteString("\n")
-		output.WriteString(colors.Sprintf(colors.Faint("Line %d: "), e.ErrorSource.Line))
+
+		if e.Location != "" {
+			output.WriteString(colors.Faint(e.Location + ": ").String())
+		} else {
+			output.WriteString(colors.Sprintf(colors.Faint("Line %d: "), e.ErrorSource.Line))
+		}
+	} else if e.Location != "" {
+		output.WriteString(colors.Faint(e.Location+":").String() + "\n")
 	}
 
 	output.WriteString(fmt.Sprintf("%s\n", e.Error))
@@ -91,9 +112,16 @@ func (e *ErrorDetails) String(colors aurora.Aurora) string {
 }
 
 func lineOffset(script *scripts.Script) int {
-	if script.Prefix == nil {
-		return 0
+	offset := 0
+
+	if script.Interpreter != nil {
+		offset += script.Interpreter.WrapperOffset()
+	}
+
+	if len(script.Prefix) == 0 {
+		return offset
 	}
 
-	return bytes.Count(script.Prefix, []byte("\n"))
+	// The prefix is separated from the script by a newline when written.
+	return offset + bytes.Count(script.Prefix, []byte("\n")) + 1
 }
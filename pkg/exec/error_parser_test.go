@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/elseano/rundown/pkg/exec/scripts"
+	"github.com/logrusorgru/aurora"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -19,7 +20,8 @@ func TestErrorParseBash(t *testing.T) {
 	parsed := ParseError(sm, output)
 
 	if assert.NotNil(t, parsed.ErrorSource) {
-		if assert.Equal(t, 5, parsed.ErrorSource.Line) {
+		// Line 5 of the script file is line 4 of the code, as the preamble comes first.
+		if assert.Equal(t, 4, parsed.ErrorSource.Line) {
 			assert.Equal(t, "DOCKER_BUILD_TAG: unbound variable", parsed.Error)
 		}
 	}
@@ -36,8 +38,118 @@ func TestErrorParseBashAdjustsForPrefix(t *testing.T) {
 	parsed := ParseError(sm, output)
 
 	if assert.NotNil(t, parsed.ErrorSource) {
-		if assert.Equal(t, 2, parsed.ErrorSource.Line) {
+		if assert.Equal(t, 1, parsed.ErrorSource.Line) {
 			assert.Equal(t, "DOCKER_BUILD_TAG: unbound variable", parsed.Error)
 		}
 	}
 }
+
+func TestErrorStringUsesLocation(t *testing.T) {
+	details := &ErrorDetails{
+		Error:       "DOCKER_BUILD_TAG: unbound variable",
+		ErrorSource: &ErrorSource{Source: []byte("one\ntwo\nthree\n"), Line: 2},
+		Location:    "docs/deploy.md:142",
+	}
+
+	output := details.String(aurora.NewAurora(false))
+
+	assert.Contains(t, output, "*    2: two\n")
+	assert.Contains(t, output, "docs/deploy.md:142: DOCKER_BUILD_TAG: unbound variable")
+}
+
+func TestErrorParseLanguages(t *testing.T) {
+	contents := []byte("one\ntwo\nthree\nfour\nfive\nsix")
+
+	tests := []struct {
+		language string
+		file     string
+		output   string
+		line     int
+		message  string
+	}{
+		{
+			language: "python",
+			file:     "/tmp/rd-123.py",
+			output:   "Traceback (most recent call last):\n  File \"/tmp/rd-123.py\", line 5, in <module>\n    foo()\n  File \"/tmp/rd-123.py\", line 2, in foo\n    raise ValueError(\"bad thing\")\nValueError: bad thing\n",
+			line:     2,
+			message:  "ValueError: bad thing",
+		},
+		{
+			language: "ruby",
+			file:     "/tmp/rd-123.rb",
+			output:   "/tmp/rd-123.rb:3:in `foo': bad thing (RuntimeError)\n\tfrom /tmp/rd-123.rb:6:in `<main>'\n",
+			line:     3,
+			message:  "bad thing (RuntimeError)",
+		},
+		{
+			language: "node",
+			file:     "/tmp/rd-123.js",
+			output:   "/tmp/rd-123.js:2\n  throw new Error(\"bad thing\");\n  ^\n\nError: bad thing\n    at foo (/tmp/rd-123.js:2:9)\n    at Object.<anonymous> (/tmp/rd-123.js:4:1)\n    at Module._compile (node:internal/modules/cjs/loader:1521:14)\n\nNode.js v20.19.5\n",
+			line:     2,
+			message:  "Error: bad thing",
+		},
+		{
+			language: "go",
+			file:     "/tmp/rd-123.go",
+			output:   "panic: assignment to entry in nil map\n\ngoroutine 1 [running]:\nmain.main()\n\t/tmp/rd-123.go:4 +0x28\nexit status 2\n",
+			line:     4,
+			message:  "panic: assignment to entry in nil map",
+		},
+		{
+			language: "go",
+			file:     "/tmp/rd-123.go",
+			output:   "# command-line-arguments\n../../tmp/rd-123.go:5:2: undefined: foo\n",
+			line:     5,
+			message:  "undefined: foo",
+		},
+		{
+			language: "php",
+			file:     "/tmp/rd-123.php",
+			output:   "PHP Fatal error:  Uncaught Exception: bad thing in /tmp/rd-123.php:3\nStack trace:\n#0 /tmp/rd-123.php(6): foo()\n#1 {main}\n  thrown in /tmp/rd-123.php on line 3\n",
+			line:     3,
+			message:  "Fatal error: Uncaught Exception: bad thing",
+		},
+		{
+			language: "perl",
+			file:     "/tmp/rd-123.pl",
+			output:   "bad thing at /tmp/rd-123.pl line 6.\n",
+			line:     6,
+			message:  "bad thing",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.language, func(t *testing.T) {
+			script := &scripts.Script{
+				AbsolutePath:     test.file,
+				OriginalContents: contents,
+				Interpreter:      scripts.BuiltinInterpreters[test.language],
+			}
+
+			parsed := ParseError(script, test.output)
+
+			if assert.NotNil(t, parsed.ErrorSource) {
+				assert.Equal(t, test.line, parsed.ErrorSource.Line)
+				assert.Equal(t, test.message, parsed.Error)
+			}
+		})
+	}
+}
+
+func TestErrorParseSkipsFramesOutsideScript(t *testing.T) {
+	// The innermost frame is in code rundown added before the script, so the caller's line is used.
+	script := &scripts.Script{
+		AbsolutePath:     "/tmp/rd-123.py",
+		OriginalContents: []byte("one\ntwo"),
+		Prefix:           []byte("def helper():\n    raise ValueError()"),
+		Interpreter:      scripts.BuiltinInterpreters["python"],
+	}
+
+	output := "Traceback (most recent call last):\n  File \"/tmp/rd-123.py\", line 4, in <module>\n  File \"/tmp/rd-123.py\", line 2, in helper\nValueError\n"
+
+	parsed := ParseError(script, output)
+
+	if assert.NotNil(t, parsed.ErrorSource) {
+		assert.Equal(t, 2, parsed.ErrorSource.Line)
+	}
+}
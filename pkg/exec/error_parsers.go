@@ -0,0 +1,254 @@
+package exec
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Finds where a script failed, from the output it produced. Each language reports errors differently.
+type ErrorParser interface {
+	// Returns the error message, and the lines of the script file the output refers to, innermost first.
+	// Returns no lines when the output doesn't mention the script.
+	Parse(output string, scriptFile string) (message string, lines []int)
+}
+
+// Error parsers, keyed by the error-format of an interpreter. Interpreters without an error-format use "shell".
+var ErrorParsers = map[string]ErrorParser{
+	"shell":  shellErrorParser{},
+	"python": pythonErrorParser{},
+	"ruby":   rubyErrorParser{},
+	"node":   nodeErrorParser{},
+	"go":     goErrorParser{},
+	"php":    phpErrorParser{},
+	"perl":   perlErrorParser{},
+}
+
+const DefaultErrorFormat = "shell"
+
+// Matches a file path in the output, for isScriptFile to check.
+const filePath = `[^\s"'(:]+`
+
+var (
+	pythonFrame  = regexp.MustCompile(`File "(` + filePath + `)", line (\d+)`)
+	rubyFrame    = regexp.MustCompile(`(` + filePath + `):(\d+):`)
+	rubyMessage  = regexp.MustCompile(`(?m)^(` + filePath + `):\d+:(?:in [` + "`" + `'][^']*':)? *(.*)$`)
+	nodeFrame    = regexp.MustCompile(`(?m)(` + filePath + `):(\d+)(?::\d+\)?|$)`)
+	goFrame      = regexp.MustCompile(`(` + filePath + `):(\d+)`)
+	goPanic      = regexp.MustCompile(`(?m)^panic: .*$`)
+	goCompile    = regexp.MustCompile(`(?m)^(` + filePath + `):\d+(?::\d+)?: (.*)$`)
+	phpError     = regexp.MustCompile(`(?m)^(?:PHP )?((?:Fatal error|Parse error|Warning|Notice|Deprecated): +.*?) in (` + filePath + `)(?::| on line )(\d+)`)
+	phpFrame     = regexp.MustCompile(`(` + filePath + `)\((\d+)\)`)
+	perlError    = regexp.MustCompile(`(?m)^(.*) at (` + filePath + `) line (\d+)[.,]`)
+	repeatSpaces = regexp.MustCompile(` +`)
+)
+
+// Returns true if the path refers to the script file. Tools don't always print the absolute path (go prints it
+// relative to the working directory), but the temporary file's name is unique, so matching on that is enough.
+func isScriptFile(path string, scriptFile string) bool {
+	return filepath.Base(path) == filepath.Base(scriptFile)
+}
+
+// Returns the line numbers of the matches referring to the script file, in order. The matcher's first group
+// is the file, and its second the line.
+func matchedLines(matcher *regexp.Regexp, output string, scriptFile string) []int {
+	lines := []int{}
+
+	for _, match := range matcher.FindAllStringSubmatch(output, -1) {
+		if !isScriptFile(match[1], scriptFile) {
+			continue
+		}
+
+		if line, err := strconv.Atoi(match[2]); err == nil {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines
+}
+
+// Returns the first match referring to the script file, with the file in the given group, or nil.
+func firstMatch(matcher *regexp.Regexp, output string, scriptFile string, group int) []string {
+	for _, match := range matcher.FindAllStringSubmatch(output, -1) {
+		if isScriptFile(match[group], scriptFile) {
+			return match
+		}
+	}
+
+	return nil
+}
+
+// Returns the last line of the output which isn't blank.
+func lastNonBlankLine(output string) string {
+	lines := strings.Split(strings.TrimRight(output, "\r\n\t "), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// Understands bash style "script: line 5: message" and "script:5: message" errors.
+type shellErrorParser struct{}
+
+func (shellErrorParser) Parse(output string, scriptFile string) (string, []int) {
+	index := strings.Index(output, scriptFile)
+	if index == -1 {
+		return "", nil
+	}
+
+	matches := lineMatcher.FindStringSubmatch(output[index:])
+	if matches == nil {
+		return "", nil
+	}
+
+	line := 0
+	if matches[2] != "" {
+		line, _ = strconv.Atoi(matches[2])
+	} else {
+		line, _ = strconv.Atoi(matches[3])
+	}
+
+	return matches[4], []int{line}
+}
+
+// Understands Python tracebacks, which list the innermost frame last, followed by the exception.
+//
+//	Traceback (most recent call last):
+//	  File "/tmp/rd-123.py", line 2, in <module>
+//	    foo()
+//	ValueError: bad thing
+type pythonErrorParser struct{}
+
+func (pythonErrorParser) Parse(output string, scriptFile string) (string, []int) {
+	lines := matchedLines(pythonFrame, output, scriptFile)
+	if len(lines) == 0 {
+		return "", nil
+	}
+
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+
+	return lastNonBlankLine(output), lines
+}
+
+// Understands Ruby backtraces, which start with the innermost frame and the exception.
+//
+//	/tmp/rd-123.rb:2:in `foo': bad thing (RuntimeError)
+//		from /tmp/rd-123.rb:5:in `<main>'
+type rubyErrorParser struct{}
+
+func (rubyErrorParser) Parse(output string, scriptFile string) (string, []int) {
+	lines := matchedLines(rubyFrame, output, scriptFile)
+	if len(lines) == 0 {
+		return "", nil
+	}
+
+	message := firstMatch(rubyMessage, output, scriptFile, 1)
+	if message == nil {
+		return lastNonBlankLine(output), lines
+	}
+
+	return message[2], lines
+}
+
+// Understands Node stack traces, which start with the line that threw, followed by the exception and its frames.
+//
+//	/tmp/rd-123.js:2
+//	  throw new Error("bad thing");
+//	  ^
+//
+//	Error: bad thing
+//	    at foo (/tmp/rd-123.js:2:9)
+type nodeErrorParser struct{}
+
+func (nodeErrorParser) Parse(output string, scriptFile string) (string, []int) {
+	lines := matchedLines(nodeFrame, output, scriptFile)
+	if len(lines) == 0 {
+		return "", nil
+	}
+
+	// The exception is the line before the stack frames.
+	message := ""
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "at ") {
+			break
+		}
+
+		if strings.TrimSpace(line) != "" {
+			message = strings.TrimSpace(line)
+		}
+	}
+
+	return message, lines
+}
+
+// Understands Go panics and compile errors.
+//
+//	panic: assignment to entry in nil map
+//
+//	goroutine 1 [running]:
+//	main.main()
+//		/tmp/rd-123.go:5 +0x28
+type goErrorParser struct{}
+
+func (goErrorParser) Parse(output string, scriptFile string) (string, []int) {
+	lines := matchedLines(goFrame, output, scriptFile)
+	if len(lines) == 0 {
+		return "", nil
+	}
+
+	if message := goPanic.FindString(output); message != "" {
+		return message, lines
+	}
+
+	if compile := firstMatch(goCompile, output, scriptFile, 1); compile != nil {
+		return compile[2], lines
+	}
+
+	return lastNonBlankLine(output), lines
+}
+
+// Understands PHP errors, and the stack trace following uncaught exceptions.
+//
+//	PHP Fatal error:  Uncaught Exception: bad thing in /tmp/rd-123.php:3
+//	Stack trace:
+//	#0 /tmp/rd-123.php(6): foo()
+type phpErrorParser struct{}
+
+func (phpErrorParser) Parse(output string, scriptFile string) (string, []int) {
+	match := firstMatch(phpError, output, scriptFile, 2)
+	if match == nil {
+		return "", nil
+	}
+
+	line, _ := strconv.Atoi(match[3])
+	lines := append([]int{line}, matchedLines(phpFrame, output, scriptFile)...)
+
+	return repeatSpaces.ReplaceAllString(match[1], " "), lines
+}
+
+// Understands Perl's "message at script line 3." errors.
+type perlErrorParser struct{}
+
+func (perlErrorParser) Parse(output string, scriptFile string) (string, []int) {
+	message := ""
+	lines := []int{}
+
+	for _, match := range perlError.FindAllStringSubmatch(output, -1) {
+		if !isScriptFile(match[2], scriptFile) {
+			continue
+		}
+
+		if message == "" {
+			message = match[1]
+		}
+
+		line, _ := strconv.Atoi(match[3])
+		lines = append(lines, line)
+	}
+
+	if len(lines) == 0 {
+		return "", nil
+	}
+
+	return message, lines
+}
@@ -0,0 +1,35 @@
+package exec
+
+import (
+	"context"
+	go_exec "os/exec"
+	"time"
+
+	rdutil "github.com/elseano/rundown/pkg/util"
+)
+
+// How long to wait for a tool to report its version.
+const versionTimeout = 5 * time.Second
+
+// Finds the tool on the PATH, returning its location.
+// When withVersion is set, the tool is run with --version to determine which version is installed.
+func LookupTool(name string, withVersion bool) (string, string, error) {
+	path, err := go_exec.LookPath(name)
+	if err != nil {
+		return "", "", err
+	}
+
+	if !withVersion {
+		return path, "", nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), versionTimeout)
+	defer cancel()
+
+	output, err := go_exec.CommandContext(ctx, path, "--version").CombinedOutput()
+	if err != nil {
+		rdutil.Logger.Debug().Msgf("%s --version failed: %s", name, err)
+	}
+
+	return path, rdutil.FindVersion(string(output)), nil
+}
@@ -104,14 +104,16 @@ type Process struct {
 	waitGroup sync.WaitGroup
 	mutex     sync.Mutex
 	undoRaw   func()
+	signals   *signalForwarder
 }
 
-func (p *Process) setRawMode() func() {
+// Puts the terminal into raw mode, keeping the size of the process's PTY in step with it.
+func (p *Process) setRawMode(ptmx *os.File) func() {
 	ch := make(chan os.Signal, 1)
 	signal.Notify(ch, syscall.SIGWINCH)
 	go func() {
 		for range ch {
-			if err := pty.InheritSize(os.Stdin, p.pty); err != nil {
+			if err := pty.InheritSize(os.Stdin, ptmx); err != nil {
 				// log.Printf("error resizing pty: %s", err) // Don't care.
 			}
 		}
@@ -148,7 +150,12 @@ func (p *Process) Start() (*io.PipeReader, *io.PipeReader, error) {
 		currentUndoRaw()
 	}
 
-	p.undoRaw = p.setRawMode()
+	stderrPipe, err := p.startWithPty()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p.undoRaw = p.setRawMode(p.pty)
 	currentUndoRaw = p.undoRaw
 
 	undoRawRegisterd.Do(func() {
@@ -159,50 +166,84 @@ func (p *Process) Start() (*io.PipeReader, *io.PipeReader, error) {
 		})
 	})
 
-	var err error
-	if p.pty, err = pty.Start(p.cmd); err != nil {
-		return nil, nil, err
-	}
+	p.waitGroup.Add(3)
 
 	// Copy PTY to output capturing pipe
 	go func() {
-		defer stdoutR.Close()
-
-		p.waitGroup.Add(1)
+		defer p.waitGroup.Done()
+		defer stdoutW.Close()
 
 		_, _ = io.Copy(stdoutW, p.pty)
-
-		p.waitGroup.Done()
 	}()
 
+	// STDERR isn't attached to the PTY, so it stays separate from STDOUT.
 	go func() {
-		defer stderrR.Close()
-
-		p.waitGroup.Add(1)
+		defer p.waitGroup.Done()
+		defer stderrW.Close()
+		defer stderrPipe.Close()
 
-		_, _ = io.Copy(stderrW, p.pty)
-
-		p.waitGroup.Done()
+		_, _ = io.Copy(stderrW, stderrPipe)
 	}()
 
 	// Copy stdin to PTY
 	go func() {
-		p.waitGroup.Add(1)
+		defer p.waitGroup.Done()
 
 		_, _ = io.Copy(p.pty, stdinR)
-
-		p.waitGroup.Done()
-
 	}()
 
 	// Return output stream reader
 	return stdoutR, stderrR, nil
 }
 
+// Starts the command with STDIN and STDOUT attached to a new PTY, and STDERR attached to a pipe.
+// Returns the read end of the STDERR pipe.
+func (p *Process) startWithPty() (*os.File, error) {
+	ptmx, tty, err := pty.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer tty.Close()
+
+	stderrR, stderrW, err := os.Pipe()
+	if err != nil {
+		ptmx.Close()
+		return nil, err
+	}
+	defer stderrW.Close()
+
+	p.cmd.Stdin = tty
+	p.cmd.Stdout = tty
+	p.cmd.Stderr = stderrW
+
+	if p.cmd.SysProcAttr == nil {
+		p.cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	p.cmd.SysProcAttr.Setctty = true
+	p.cmd.SysProcAttr.Setsid = true
+
+	if err := p.cmd.Start(); err != nil {
+		ptmx.Close()
+		stderrR.Close()
+		return nil, err
+	}
+
+	p.pty = ptmx
+	p.signals = forwardSignals(p.cmd, CancelGracePeriod)
+
+	return stderrR, nil
+}
+
+// Returns true if the process was interrupted by SIGINT or SIGTERM.
+func (p *Process) Cancelled() bool {
+	return p.signals.Cancelled()
+}
+
 func (p *Process) Wait() error {
 	defer p.undoRaw()
 
 	err := p.cmd.Wait()
+	p.signals.Stop()
 	p.stdin.Stop()
 	p.waitGroup.Wait()
 
@@ -0,0 +1,31 @@
+package exec
+
+import (
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessSeparatesStderr(t *testing.T) {
+	process := NewProcess(exec.Command("sh", "-c", "echo out; echo err >&2"))
+
+	stdout, stderr, err := process.Start()
+	require.NoError(t, err)
+
+	var stdoutBytes, stderrBytes []byte
+	var wg sync.WaitGroup
+
+	wg.Add(2)
+	go func() { stdoutBytes, _ = io.ReadAll(stdout); wg.Done() }()
+	go func() { stderrBytes, _ = io.ReadAll(stderr); wg.Done() }()
+
+	require.NoError(t, process.Wait())
+	wg.Wait()
+
+	require.Equal(t, "out", strings.TrimSpace(string(stdoutBytes)))
+	require.Equal(t, "err", strings.TrimSpace(string(stderrBytes)))
+}
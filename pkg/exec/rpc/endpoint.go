@@ -23,10 +23,11 @@ type RpcMessage struct {
 	Data string
 }
 
-func Start() (*Endpoint, error) {
+// Starts receiving messages, with the FIFO created inside dir. An empty dir uses the system's temporary directory.
+func Start(dir string) (*Endpoint, error) {
 	endpoint := &Endpoint{}
 
-	if err := endpoint.initialize(); err != nil {
+	if err := endpoint.initialize(dir); err != nil {
 		return nil, err
 	}
 
@@ -40,8 +41,8 @@ func (e *Endpoint) Close() {
 	os.Remove(e.Path)
 }
 
-func (e *Endpoint) initialize() error {
-	file, err := ioutil.TempFile("", "rundown-rpc-*")
+func (e *Endpoint) initialize(dir string) error {
+	file, err := ioutil.TempFile(dir, "rundown-rpc-*")
 	if err != nil {
 		return err
 	}
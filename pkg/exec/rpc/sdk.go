@@ -0,0 +1,215 @@
+package rpc
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/elseano/rundown/pkg/util"
+)
+
+// The SDK protocol lets scripts in any language talk to rundown.
+//
+// Scripts find the protocol via the RUNDOWN_SDK environment variable, which has the form VERSION:DIRECTORY.
+// The directory contains two FIFOs. Scripts write one command per line to "requests", and read
+// replies to prompt and confirm from "responses".
+//
+// Commands are:
+//
+//	set-env NAME=VALUE
+//	set-spinner TITLE
+//	progress CURRENT/TOTAL [LABEL]
+//	log MESSAGE
+//	prompt LABEL              replies "ok VALUE" or "err MESSAGE"
+//	confirm LABEL             replies "ok yes", "ok no" or "err MESSAGE"
+//
+// Newlines and backslashes in arguments and replies are escaped as \n and \\.
+var SDKEnvironmentVariableName = "RUNDOWN_SDK"
+
+const SDKProtocolVersion = 1
+
+// Receives the commands sent by a script.
+type SDKHandler interface {
+	SetEnv(name string, value string)
+	SetSpinner(title string)
+	Progress(current int, total int, label string)
+	Log(message string)
+	Prompt(label string) (string, error)
+	Confirm(label string) (bool, error)
+}
+
+type SDKEndpoint struct {
+	Dir       string
+	handler   SDKHandler
+	requests  *os.File
+	responses *os.File
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Written by rundown itself when closing, so all commands before it are processed first.
+const sdkCloseCommand = "\x00close"
+
+// Starts receiving SDK commands, with the FIFOs in a new directory inside dir. An empty dir uses the system's
+// temporary directory.
+func StartSDK(dir string, handler SDKHandler) (*SDKEndpoint, error) {
+	dir, err := ioutil.TempDir(dir, "rundown-sdk-*")
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := &SDKEndpoint{Dir: dir, handler: handler, done: make(chan struct{})}
+
+	if endpoint.requests, err = openFifo(filepath.Join(dir, "requests")); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	if endpoint.responses, err = openFifo(filepath.Join(dir, "responses")); err != nil {
+		endpoint.requests.Close()
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	go endpoint.receiveLoop()
+
+	return endpoint, nil
+}
+
+func openFifo(path string) (*os.File, error) {
+	if err := syscall.Mkfifo(path, 0600); err != nil {
+		return nil, err
+	}
+
+	// RDWR so it doesn't block on opening, and never sees EOF when a script closes it.
+	return os.OpenFile(path, os.O_RDWR, os.ModeNamedPipe)
+}
+
+// The value of the RUNDOWN_SDK environment variable for scripts.
+func (e *SDKEndpoint) EnvValue() string {
+	return fmt.Sprintf("%d:%s", SDKProtocolVersion, e.Dir)
+}
+
+// Processes any outstanding commands, then shuts down the endpoint. Safe to call more than once.
+func (e *SDKEndpoint) Close() {
+	e.closeOnce.Do(func() {
+		e.requests.WriteString(sdkCloseCommand + "\n")
+		<-e.done
+
+		e.requests.Close()
+		e.responses.Close()
+		os.RemoveAll(e.Dir)
+	})
+}
+
+func (e *SDKEndpoint) receiveLoop() {
+	defer close(e.done)
+
+	reader := bufio.NewReader(e.requests)
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if line == sdkCloseCommand {
+			return
+		}
+
+		util.Logger.Debug().Msgf("Got SDK command: %s", line)
+
+		if reply, ok := e.dispatch(line); ok {
+			e.responses.WriteString(reply + "\n")
+		}
+	}
+}
+
+// Runs the command, returning the reply if the command has one.
+func (e *SDKEndpoint) dispatch(line string) (string, bool) {
+	command, arg, _ := strings.Cut(line, " ")
+	arg = UnescapeSDK(arg)
+
+	switch command {
+	case "set-env":
+		if name, value, ok := strings.Cut(arg, "="); ok {
+			e.handler.SetEnv(strings.TrimSpace(name), value)
+		}
+
+	case "set-spinner":
+		e.handler.SetSpinner(arg)
+
+	case "progress":
+		counts, label, _ := strings.Cut(arg, " ")
+		current, total, _ := strings.Cut(counts, "/")
+
+		c, errC := strconv.Atoi(current)
+		t, errT := strconv.Atoi(total)
+		if errC == nil && errT == nil {
+			e.handler.Progress(c, t, label)
+		}
+
+	case "log":
+		e.handler.Log(arg)
+
+	case "prompt":
+		value, err := e.handler.Prompt(arg)
+		return sdkReply(value, err), true
+
+	case "confirm":
+		confirmed, err := e.handler.Confirm(arg)
+
+		value := "no"
+		if confirmed {
+			value = "yes"
+		}
+
+		return sdkReply(value, err), true
+
+	default:
+		util.Logger.Warn().Msgf("Unknown SDK command: %s", command)
+	}
+
+	return "", false
+}
+
+func sdkReply(value string, err error) string {
+	if err != nil {
+		return "err " + EscapeSDK(err.Error())
+	}
+
+	return "ok " + EscapeSDK(value)
+}
+
+func EscapeSDK(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "\n", "\\n").Replace(s)
+}
+
+func UnescapeSDK(s string) string {
+	result := strings.Builder{}
+
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+
+			if s[i] == 'n' {
+				result.WriteByte('\n')
+			} else {
+				result.WriteByte(s[i])
+			}
+
+			continue
+		}
+
+		result.WriteByte(s[i])
+	}
+
+	return result.String()
+}
@@ -0,0 +1,62 @@
+package rpc
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testHandler struct {
+	env     map[string]string
+	logs    []string
+	answers map[string]string
+}
+
+func (h *testHandler) SetEnv(name string, value string)              { h.env[name] = value }
+func (h *testHandler) SetSpinner(title string)                       {}
+func (h *testHandler) Progress(current int, total int, label string) {}
+func (h *testHandler) Log(message string)                            { h.logs = append(h.logs, message) }
+func (h *testHandler) Prompt(label string) (string, error)           { return h.answers[label], nil }
+func (h *testHandler) Confirm(label string) (bool, error)            { return true, nil }
+
+func TestSDKEndpoint(t *testing.T) {
+	handler := &testHandler{env: map[string]string{}, answers: map[string]string{"Name?": "Multi\nLine"}}
+
+	endpoint, err := StartSDK(t.TempDir(), handler)
+	require.NoError(t, err)
+	require.Regexp(t, `^1:/`, endpoint.EnvValue())
+
+	requests, err := os.OpenFile(filepath.Join(endpoint.Dir, "requests"), os.O_WRONLY, 0)
+	require.NoError(t, err)
+
+	responses, err := os.Open(filepath.Join(endpoint.Dir, "responses"))
+	require.NoError(t, err)
+
+	requests.WriteString("set-env FOO=bar\\nbaz\n")
+	requests.WriteString("log Hello\n")
+	requests.WriteString("prompt Name?\n")
+
+	reply, err := bufio.NewReader(responses).ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "ok Multi\\nLine\n", reply)
+
+	requests.WriteString("log Last\n")
+	requests.Close()
+	responses.Close()
+
+	endpoint.Close()
+
+	require.Equal(t, "bar\nbaz", handler.env["FOO"])
+	require.Equal(t, []string{"Hello", "Last"}, handler.logs)
+
+	_, err = os.Stat(endpoint.Dir)
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestSDKEscaping(t *testing.T) {
+	require.Equal(t, `a\\n\nb`, EscapeSDK("a\\n\nb"))
+	require.Equal(t, "a\\n\nb", UnescapeSDK(EscapeSDK("a\\n\nb")))
+}
@@ -15,27 +15,52 @@ import (
 
 type Runner struct {
 	Script *scripts.Script
-	env    map[string]string
+
+	// Where scripts are written. Empty uses the system's temporary directory.
+	TempDir string
+
+	// How long the script has to exit after being interrupted, before it's killed.
+	CancelGracePeriod time.Duration
+
+	env           map[string]string
+	interpreters  scripts.Interpreters
+	wrapperScript *scripts.Script
 }
 
 func NewRunner() *Runner {
-	return &Runner{env: map[string]string{}}
+	return &Runner{env: map[string]string{}, interpreters: scripts.DefaultInterpreters(), CancelGracePeriod: CancelGracePeriod}
 }
 
-func (r *Runner) SetScript(binaryPath string, language string, source []byte) (*scripts.Script, error) {
-	script, err := scripts.NewScript(binaryPath, language, source)
+// Adds interpreters, replacing the defaults for the same language.
+func (r *Runner) AddInterpreters(interpreters scripts.Interpreters) {
+	r.interpreters = r.interpreters.Merge(interpreters)
+}
 
-	rdutil.Logger.Debug().Msgf("Script created. Binary: %s, Command Line: %s", script.BinaryPath, script.CommandLine)
+func (r *Runner) SetScript(binaryPath string, language string, source []byte) (*scripts.Script, error) {
+	script, err := scripts.NewInterpretedScriptIn(r.TempDir, r.interpreters.Resolve(binaryPath, language), source)
 
 	if err != nil {
 		return nil, err
 	}
 
+	rdutil.Logger.Debug().Msgf("Script created. Binary: %s, Command Line: %s", script.BinaryPath, script.CommandLine)
+
 	r.Script = script
 
 	return r.Script, nil
 }
 
+// Removes the temporary files written to run the script.
+func (r *Runner) RemoveScripts() {
+	if r.Script != nil {
+		r.Script.Remove()
+	}
+
+	if r.wrapperScript != nil {
+		r.wrapperScript.Remove()
+	}
+}
+
 func (r *Runner) ImportEnv(env map[string]string) {
 	for k, v := range env {
 		r.env[k] = v
@@ -58,6 +83,7 @@ type Running struct {
 	startedAt    time.Time
 	StderrOutput []byte
 	Stderr       io.ReadCloser
+	signals      *signalForwarder
 }
 
 func (r *Runner) RunReplacingProcess() error {
@@ -94,6 +120,9 @@ func (r *Runner) Prepare() (*Running, error) {
 		return nil, err
 	}
 
+	// Run in a new process group, so signals can be forwarded to everything the script starts.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
 	stdout, err := cmd.StdoutPipe()
 
 	if err != nil {
@@ -137,12 +166,23 @@ func (r *Running) Start() error {
 		return err
 	}
 
+	r.signals = forwardSignals(r.cmd, r.Runner.CancelGracePeriod)
+
 	return nil
 }
 
+// Returns true if the script was interrupted by SIGINT or SIGTERM.
+func (r *Running) Cancelled() bool {
+	return r.signals != nil && r.signals.Cancelled()
+}
+
 func (r *Running) Wait() (int, time.Duration, error) {
 	err := r.cmd.Wait()
 
+	if r.signals != nil {
+		r.signals.Stop()
+	}
+
 	if err != nil {
 		if exitErr, ok := err.(*go_exec.ExitError); ok {
 			rdutil.Logger.Debug().Msgf("Process exited with %d", r.cmd.ProcessState.ExitCode())
@@ -165,7 +205,7 @@ func (r *Runner) prepareCommand() (*go_exec.Cmd, error) {
 		// Replace $SCRIPT_FILE otherwise it appears on the command line twice.
 		wrapperScriptContents = strings.ReplaceAll(wrapperScriptContents, "$SCRIPT_FILE", r.Script.AbsolutePath)
 
-		wrapperScript, err := scripts.NewScript("bash", "bash", []byte(wrapperScriptContents))
+		wrapperScript, err := scripts.NewInterpretedScriptIn(r.TempDir, scripts.DefaultInterpreters().Resolve("bash", "bash"), []byte(wrapperScriptContents))
 		if err != nil {
 			return nil, err
 		}
@@ -173,12 +213,20 @@ func (r *Runner) prepareCommand() (*go_exec.Cmd, error) {
 		wrapperScript.Write()
 		wrapperScript.MakeExecutable()
 
+		r.wrapperScript = wrapperScript
+
 		rdutil.Logger.Debug().Msgf("Wrapper script is %s", wrapperScript.AbsolutePath)
 		rdutil.Logger.Debug().Msgf("Provided script is %s", r.Script.AbsolutePath)
 
 		return go_exec.Command(wrapperScript.BinaryPath, wrapperScript.AbsolutePath), nil
 	} else {
 		rdutil.Logger.Debug().Msgf("Provided script is %s", r.Script.AbsolutePath)
+
+		// Commands with arguments, such as "python3 -u", take the script after their arguments.
+		if command := strings.Fields(r.Script.BinaryPath); len(command) > 1 {
+			return go_exec.Command(command[0], append(command[1:], r.Script.AbsolutePath)...), nil
+		}
+
 		return go_exec.Command(r.Script.BinaryPath, r.Script.AbsolutePath), nil
 	}
 
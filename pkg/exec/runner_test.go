@@ -0,0 +1,31 @@
+package exec
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunnerCommandWithArguments(t *testing.T) {
+	runner := NewRunner()
+
+	// Without $SCRIPT_FILE, the script follows the command's arguments. Here, -e stops at the failure.
+	_, err := runner.SetScript("bash -e", "bash", []byte("echo started\nfalse\necho unreachable"))
+	require.NoError(t, err)
+	defer runner.RemoveScripts()
+
+	running, err := runner.Prepare()
+	require.NoError(t, err)
+	require.NoError(t, running.Start())
+
+	go io.Copy(io.Discard, running.Stderr)
+	stdout, _ := io.ReadAll(running.Stdout)
+
+	exitCode, _, err := running.Wait()
+	require.NoError(t, err)
+
+	require.Equal(t, 1, exitCode)
+	require.Equal(t, "started", strings.TrimSpace(string(stdout)))
+}
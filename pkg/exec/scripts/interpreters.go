@@ -0,0 +1,292 @@
+package scripts
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/elseano/rundown/pkg/util"
+	"gopkg.in/yaml.v3"
+)
+
+// Describes how to run code blocks of a given language.
+type Interpreter struct {
+	// Command to run the script. $SCRIPT_FILE is replaced with the script's path, otherwise the path is appended.
+	Command string `yaml:"command"`
+
+	// Extension for the script's temporary file, i.e. ".go". Some tools refuse to run files without one.
+	Extension string `yaml:"extension"`
+
+	// Added to the start of the script, unless the script already starts with it. For example, "package main".
+	Preamble string `yaml:"preamble"`
+
+	// Wraps the script, with {{script}} replaced by the script contents.
+	Wrapper string `yaml:"wrapper"`
+
+	// Helper code prepended to the script, giving it access to the SDK protocol.
+	SDK string `yaml:"sdk"`
+
+	// The name the SDK's helper goes by, i.e. "rundown". When set, the SDK is only added to scripts which use it.
+	SDKName string `yaml:"sdk-name"`
+
+	// Matches lines which must stay ahead of the SDK when they start the script, such as Python's __future__ imports.
+	SDKAfter string `yaml:"sdk-after"`
+
+	// Appended to the script for each captured environment variable, with {{name}} replaced by the variable name.
+	// Should print the OSC sequence "ESC ] R;SETENV NAME=VALUE BEL".
+	CaptureEnv string `yaml:"capture-env"`
+
+	// How to find where the script failed from its output, i.e. "python". Defaults to shell style errors.
+	ErrorFormat string `yaml:"error-format"`
+}
+
+// Interpreters keyed by the fence language or name used in the with attribute.
+type Interpreters map[string]*Interpreter
+
+// The interpreters rundown knows about without any configuration.
+var BuiltinInterpreters = Interpreters{
+	"bash": {
+		Command:    "bash",
+		Extension:  ".sh",
+		Preamble:   "set -euo pipefail",
+		CaptureEnv: "echo -n -e \"\x1b]R;SETENV {{name}}=${{name}}\x9c\"",
+	},
+	"sh": {
+		Command:   "sh",
+		Extension: ".sh",
+		// pipefail on Ubuntu's SH fails, so don't set it here.
+		Preamble:   "set -eu",
+		CaptureEnv: "echo -n -e \"\x1b]R;SETENV {{name}}=${{name}}\x9c\"",
+	},
+	"fish": {
+		Command:    "fish",
+		Extension:  ".fish",
+		CaptureEnv: "echo -n -e \"\x1b]R;SETENV {{name}}=${{name}}\x9c\"",
+	},
+	"zsh": {
+		Command:    "zsh",
+		Extension:  ".zsh",
+		CaptureEnv: "echo -n -e \"\x1b]R;SETENV {{name}}=${{name}}\x9c\"",
+	},
+	"python": {
+		Command:     "python3",
+		Extension:   ".py",
+		CaptureEnv:  `__import__("sys").stdout.write("\x1b]R;SETENV {{name}}=" + __import__("os").environ.get("{{name}}", "") + "\x07")`,
+		SDK:         pythonSDK,
+		SDKName:     "rundown",
+		SDKAfter:    `^from\s+__future__\s+import\b`,
+		ErrorFormat: "python",
+	},
+	"ruby": {
+		Command:     "ruby",
+		Extension:   ".rb",
+		CaptureEnv:  `$stdout.write("\e]R;SETENV {{name}}=#{ENV["{{name}}"]}\a")`,
+		SDK:         rubySDK,
+		SDKName:     "Rundown",
+		ErrorFormat: "ruby",
+	},
+	"node": {
+		Command:     "node",
+		Extension:   ".js",
+		CaptureEnv:  `process.stdout.write("\x1b]R;SETENV {{name}}=" + (process.env["{{name}}"] || "") + "\x07");`,
+		SDK:         nodeSDK,
+		SDKName:     "rundown",
+		ErrorFormat: "node",
+	},
+	"typescript": {
+		Command:     "npx --yes tsx $SCRIPT_FILE",
+		Extension:   ".ts",
+		CaptureEnv:  `process.stdout.write("\x1b]R;SETENV {{name}}=" + (process.env["{{name}}"] || "") + "\x07");`,
+		ErrorFormat: "node",
+	},
+	"go": {
+		Command:     "go run $SCRIPT_FILE",
+		Extension:   ".go",
+		Preamble:    "package main",
+		ErrorFormat: "go",
+	},
+	"java": {
+		Command:   "java $SCRIPT_FILE",
+		Extension: ".java",
+	},
+	"c": {
+		Command:   "cc -x c -o $SCRIPT_FILE.out $SCRIPT_FILE && $SCRIPT_FILE.out",
+		Extension: ".c",
+	},
+	"php": {
+		Command:     "php",
+		Extension:   ".php",
+		ErrorFormat: "php",
+	},
+	"perl": {
+		Command:     "perl",
+		Extension:   ".pl",
+		CaptureEnv:  `print "\e]R;SETENV {{name}}=$ENV{'{{name}}'}\a";`,
+		ErrorFormat: "perl",
+	},
+}
+
+// Alternative fence languages for the builtin interpreters.
+var interpreterAliases = map[string]string{
+	"python3":    "python",
+	"py":         "python",
+	"rb":         "ruby",
+	"javascript": "node",
+	"js":         "node",
+	"ts":         "typescript",
+	"golang":     "go",
+	"pl":         "perl",
+	"shell":      "sh",
+}
+
+// Returns the interpreter for the name, checking aliases of builtin interpreters.
+func (i Interpreters) Get(name string) *Interpreter {
+	if interpreter, ok := i[name]; ok {
+		return interpreter
+	}
+
+	if alias, ok := interpreterAliases[name]; ok {
+		return i[alias]
+	}
+
+	return nil
+}
+
+// Returns a new set of interpreters, with those in other replacing existing ones.
+func (i Interpreters) Merge(other Interpreters) Interpreters {
+	result := Interpreters{}
+
+	for k, v := range i {
+		result[k] = v
+	}
+
+	for k, v := range other {
+		result[k] = v
+	}
+
+	return result
+}
+
+// Works out how to run a script, given the with attribute and the fence language.
+//
+// When with names a known interpreter, that interpreter is used. Otherwise, with is treated as the
+// command to run, and the rest of the settings come from the language's interpreter if there is one.
+func (i Interpreters) Resolve(with string, language string) *Interpreter {
+	if interpreter := i.Get(with); interpreter != nil {
+		return interpreter
+	}
+
+	result := Interpreter{}
+	if interpreter := i.Get(language); interpreter != nil {
+		result = *interpreter
+	}
+
+	result.Command = with
+
+	return &result
+}
+
+var loadUserInterpreters sync.Once
+var userInterpreters Interpreters
+
+// Returns the builtin interpreters, extended by those in ~/.config/rundown/interpreters.yml.
+func DefaultInterpreters() Interpreters {
+	loadUserInterpreters.Do(func() {
+		configDir := os.Getenv("XDG_CONFIG_HOME")
+		if configDir == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return
+			}
+
+			configDir = filepath.Join(home, ".config")
+		}
+
+		data, err := os.ReadFile(filepath.Join(configDir, "rundown", "interpreters.yml"))
+		if err != nil {
+			return
+		}
+
+		if err := yaml.Unmarshal(data, &userInterpreters); err != nil {
+			util.Logger.Warn().Msgf("Unable to read interpreters.yml: %s", err)
+		}
+	})
+
+	return BuiltinInterpreters.Merge(userInterpreters)
+}
+
+// Returns the script with the wrapper applied.
+func (i *Interpreter) Wrap(contents []byte) []byte {
+	if i.Wrapper != "" {
+		contents = []byte(strings.ReplaceAll(i.Wrapper, "{{script}}", string(contents)))
+	}
+
+	return contents
+}
+
+// Returns the number of lines the wrapper adds before the script.
+func (i *Interpreter) WrapperOffset() int {
+	if before, _, found := strings.Cut(i.Wrapper, "{{script}}"); found {
+		return strings.Count(before, "\n")
+	}
+
+	return 0
+}
+
+// Returns the code needed to capture the environment variable, or an empty string if the language doesn't support it.
+func (i *Interpreter) CaptureEnvCommand(name string) string {
+	return strings.ReplaceAll(i.CaptureEnv, "{{name}}", name)
+}
+
+// Matches a name followed by a dot, such as a call to the SDK's functions.
+var sdkReference = regexp.MustCompile(`\b(\w+)\s*\.`)
+
+// Returns true if the SDK should be added to the script. When the SDK has a name, that's only when the script uses it.
+func (i *Interpreter) UsesSDK(contents []byte) bool {
+	if i.SDK == "" {
+		return false
+	}
+
+	if i.SDKName == "" {
+		return true
+	}
+
+	for _, match := range sdkReference.FindAllSubmatch(contents, -1) {
+		if string(match[1]) == i.SDKName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Takes the lines starting the script which must stay ahead of the SDK, skipping over blank lines and comments.
+// They're blanked out of the script rather than removed, so line numbers in errors still match.
+func (i *Interpreter) SplitSDKAfter(contents []byte) ([]string, []byte) {
+	if i.SDKAfter == "" {
+		return nil, contents
+	}
+
+	matcher := regexp.MustCompile(i.SDKAfter)
+	lines := strings.Split(string(contents), "\n")
+	taken := []string{}
+
+	for n, line := range lines {
+		trimmed := strings.TrimSpace(line)
+
+		if matcher.MatchString(trimmed) {
+			taken = append(taken, trimmed)
+			lines[n] = ""
+		} else if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			break
+		}
+	}
+
+	if len(taken) == 0 {
+		return nil, contents
+	}
+
+	return taken, []byte(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,73 @@
+package scripts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveInterpreter(t *testing.T) {
+	interpreters := BuiltinInterpreters.Merge(Interpreters{
+		"sql": {Command: "sqlite3 db < $SCRIPT_FILE", Extension: ".sql"},
+	})
+
+	// With names a known interpreter.
+	require.Equal(t, "go run $SCRIPT_FILE", interpreters.Resolve("go", "go").Command)
+	require.Equal(t, "python3", interpreters.Resolve("py", "py").Command)
+	require.Equal(t, ".sql", interpreters.Resolve("sql", "sql").Extension)
+
+	// With is a command, so the rest comes from the language.
+	resolved := interpreters.Resolve("python3.11 -u", "python")
+	require.Equal(t, "python3.11 -u", resolved.Command)
+	require.Equal(t, ".py", resolved.Extension)
+
+	// Unknown languages run as-is.
+	resolved = interpreters.Resolve("cobol", "cobol")
+	require.Equal(t, "cobol", resolved.Command)
+	require.Equal(t, "", resolved.Extension)
+}
+
+func TestInterpretedScript(t *testing.T) {
+	script, err := NewInterpretedScript(&Interpreter{Command: "sh", Extension: ".go", Preamble: "package main"}, []byte("func main() {}"))
+	require.NoError(t, err)
+	require.Equal(t, "package main", string(script.Prefix))
+	require.Regexp(t, `\.go$`, script.AbsolutePath)
+
+	script, err = NewInterpretedScript(&Interpreter{Command: "sh", Preamble: "package main"}, []byte("package main\n\nfunc main() {}"))
+	require.NoError(t, err)
+	require.Equal(t, "", string(script.Prefix))
+
+	script, err = NewInterpretedScript(&Interpreter{Command: "sh", Wrapper: "begin\n{{script}}\nend"}, []byte("middle"))
+	require.NoError(t, err)
+	require.Equal(t, "begin\nmiddle\nend", string(script.Contents))
+	require.Equal(t, 1, script.Interpreter.WrapperOffset())
+
+	_, err = NewInterpretedScript(&Interpreter{Command: "this_interpreter_doesnt_exist"}, []byte(""))
+	require.Error(t, err)
+}
+
+func TestInterpretedScriptSDK(t *testing.T) {
+	python := &Interpreter{Command: "sh", SDK: "sdk = 1", SDKName: "rundown", SDKAfter: `^from\s+__future__\s+import\b`}
+
+	// Scripts which don't use the SDK don't get it.
+	script, err := NewInterpretedScript(python, []byte("print('hello')"))
+	require.NoError(t, err)
+	require.Equal(t, "", string(script.Prefix))
+
+	script, err = NewInterpretedScript(python, []byte("rundown.set_env('A', '1')"))
+	require.NoError(t, err)
+	require.Equal(t, "sdk = 1", string(script.Prefix))
+
+	// Future imports stay first, with their lines blanked so the script's line numbers don't change.
+	contents := "# Comment\nfrom __future__ import annotations\n\nrundown.log('hi')"
+	script, err = NewInterpretedScript(python, []byte(contents))
+	require.NoError(t, err)
+	require.Equal(t, "from __future__ import annotations\nsdk = 1", string(script.Prefix))
+	require.Equal(t, "# Comment\n\n\nrundown.log('hi')", string(script.Contents))
+	require.Equal(t, contents, string(script.OriginalContents))
+
+	// Without a name, the SDK is always added.
+	script, err = NewInterpretedScript(&Interpreter{Command: "sh", SDK: "sdk = 1"}, []byte("print('hello')"))
+	require.NoError(t, err)
+	require.Equal(t, "sdk = 1", string(script.Prefix))
+}
@@ -23,16 +23,28 @@ type Script struct {
 	EnvReferenceName string
 	Name             string
 	ShellScript      bool
+	Interpreter      *Interpreter
 }
 
+// Creates a script to be run by the binary, using the default interpreters.
 func NewScript(binary string, language string, contents []byte) (*Script, error) {
-	tempFile, err := ioutil.TempFile("", "rd-*")
+	return NewInterpretedScript(DefaultInterpreters().Resolve(binary, language), contents)
+}
+
+// Creates a script to be run by the interpreter.
+func NewInterpretedScript(interpreter *Interpreter, contents []byte) (*Script, error) {
+	return NewInterpretedScriptIn("", interpreter, contents)
+}
+
+// Creates a script to be run by the interpreter, written into dir. An empty dir uses the system's temporary directory.
+func NewInterpretedScriptIn(dir string, interpreter *Interpreter, contents []byte) (*Script, error) {
+	binaryPath, err := findBinary(interpreter.Command)
+
 	if err != nil {
 		return nil, err
 	}
 
-	binaryPath, prefix, err := buildInvocation(binary, language)
-
+	tempFile, err := ioutil.TempFile(dir, "rd-*"+interpreter.Extension)
 	if err != nil {
 		return nil, err
 	}
@@ -44,7 +56,40 @@ func NewScript(binary string, language string, contents []byte) (*Script, error)
 		commandline = fmt.Sprintf("%s %s", binaryPath, tempFile.Name())
 	}
 
-	return &Script{OriginalContents: contents, CommandLine: commandline, BinaryPath: binaryPath, Contents: contents, tempFile: tempFile, AbsolutePath: tempFile.Name(), Prefix: []byte(prefix)}, nil
+	original := contents
+
+	prefixes := []string{}
+	if binaryPath != "" && interpreter.Preamble != "" && !bytes.HasPrefix(bytes.TrimSpace(contents), []byte(interpreter.Preamble)) {
+		prefixes = append(prefixes, interpreter.Preamble)
+	}
+
+	if interpreter.UsesSDK(contents) {
+		var after []string
+		after, contents = interpreter.SplitSDKAfter(contents)
+
+		prefixes = append(prefixes, after...)
+		prefixes = append(prefixes, strings.TrimSpace(interpreter.SDK))
+	}
+
+	prefix := strings.Join(prefixes, "\n")
+
+	return &Script{
+		OriginalContents: original,
+		CommandLine:      commandline,
+		BinaryPath:       binaryPath,
+		Contents:         interpreter.Wrap(contents),
+		tempFile:         tempFile,
+		AbsolutePath:     tempFile.Name(),
+		Prefix:           []byte(prefix),
+		Interpreter:      interpreter,
+	}, nil
+}
+
+// Removes the script's temporary file.
+func (s *Script) Remove() {
+	if s.AbsolutePath != "" {
+		os.Remove(s.AbsolutePath)
+	}
 }
 
 func (s *Script) MakeExecutable() {
@@ -96,31 +141,27 @@ func isShellLike(via string) bool {
 	}
 }
 
-func buildInvocation(binary string, language string) (string, string, error) {
+func findBinary(binary string) (string, error) {
 	// If no interpreter, just save the file.
 	if binary == "" {
-		return "", "", nil
+		return "", nil
 	}
 
-	abs, err := exec.LookPath(binary)
+	// Interpreters can be a command line, such as "go run $SCRIPT_FILE", so only look up the program itself.
+	fields := strings.Fields(binary)
+
+	if len(fields) > 1 {
+		if _, err := exec.LookPath(fields[0]); err != nil {
+			return "", fmt.Errorf("interpreter %s not available: %w", fields[0], err)
+		}
 
-	// Ignore not found errors
-	if lpErr, ok := err.(*exec.Error); ok && lpErr.Err == exec.ErrNotFound {
-		err = nil
-		abs = binary
+		return binary, nil
 	}
 
+	abs, err := exec.LookPath(binary)
 	if err != nil {
-		return "", "", err
+		return "", fmt.Errorf("interpreter %s not available: %w", binary, err)
 	}
 
-	switch language {
-	case "bash":
-		return abs, "set -euo pipefail", nil
-	case "sh":
-		// pipefail on Ubuntu's SH fails, so don't set it here.
-		return abs, "set -eu", nil
-	default:
-		return abs, "", nil
-	}
+	return abs, nil
 }
@@ -2,8 +2,6 @@ package scripts
 
 import (
 	"errors"
-	"io/ioutil"
-	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
@@ -98,11 +96,9 @@ func (m *ScriptManager) Write() (*Script, error) {
 }
 
 func (m *ScriptManager) RemoveAll() {
-	// for _, script := range m.scripts {
-	// 	if script.AbsolutePath != "" {
-	// 		os.Remove(script.AbsolutePath)
-	// 	}
-	// }
+	for _, script := range m.scripts {
+		script.Remove()
+	}
 }
 
 type InterpreterNotFound struct{}
@@ -134,22 +130,3 @@ func buildShebang(via string) ([]byte, error) {
 
 	return []byte("/usr/bin/env " + path), nil
 }
-
-func prepareScript(shebang []byte, script []byte) (string, error) {
-	tmpFile, err := ioutil.TempFile("", "rundown-exec-*")
-
-	if err != nil {
-		return "", err
-	}
-
-	defer tmpFile.Close()
-	filename := tmpFile.Name()
-
-	tmpFile.Write([]byte("#!"))
-	tmpFile.Write(shebang)
-	tmpFile.Write([]byte("\n\n"))
-	tmpFile.Write(script)
-	os.Chmod(filename, 0700)
-
-	return filename, nil
-}
@@ -0,0 +1,16 @@
+package scripts
+
+import (
+	_ "embed"
+)
+
+// Helpers for the SDK protocol, prepended to scripts which use them so they can talk to rundown. See rpc.SDKHandler.
+
+//go:embed sdk/rundown.py
+var pythonSDK string
+
+//go:embed sdk/rundown.rb
+var rubySDK string
+
+//go:embed sdk/rundown.js
+var nodeSDK string
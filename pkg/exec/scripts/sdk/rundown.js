@@ -0,0 +1,27 @@
+globalThis.rundown = (() => {
+  // Talks to rundown using the protocol in RUNDOWN_SDK. See docs/sdk.md.
+  const fs = require("fs"), dir = (process.env.RUNDOWN_SDK || ":").split(/:(.*)/s)[1];
+  const send = (command, arg = "") => {
+    if (!dir) throw new Error("Not running within rundown");
+    fs.writeFileSync(dir + "/requests", command + " " + String(arg).replace(/\\/g, "\\\\").replace(/\n/g, "\\n") + "\n");
+  };
+  const ask = (command, label) => {
+    send(command, label);
+    const fd = fs.openSync(dir + "/responses", "r"), buf = Buffer.alloc(65536);
+    let line = "";
+    while (!line.endsWith("\n")) { const n = fs.readSync(fd, buf, 0, buf.length, null); if (n === 0) break; line += buf.toString("utf8", 0, n); }
+    fs.closeSync(fd);
+    const [status, ...rest] = line.replace(/\n$/, "").split(" ");
+    const value = rest.join(" ").replace(/\\(.)/g, (_, c) => (c === "n" ? "\n" : c));
+    if (status !== "ok") throw new Error(value);
+    return value;
+  };
+  return {
+    setEnv: (name, value) => send("set-env", `${name}=${value}`),
+    setSpinner: (title) => send("set-spinner", title),
+    progress: (current, total, label = "") => send("progress", `${current}/${total} ${label}`),
+    log: (message) => send("log", message),
+    prompt: (label) => ask("prompt", label),
+    confirm: (label) => ask("confirm", label) === "yes",
+  };
+})();
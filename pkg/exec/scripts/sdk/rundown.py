@@ -0,0 +1,25 @@
+import os as _rd_os, re as _rd_re
+class _RundownSDK:
+    """Talks to rundown using the protocol in RUNDOWN_SDK. See docs/sdk.md."""
+    def __init__(self):
+        self.dir = _rd_os.environ.get("RUNDOWN_SDK", ":").split(":", 1)[1]
+    def _send(self, command, arg=""):
+        if not self.dir:
+            raise RuntimeError("Not running within rundown")
+        with open(self.dir + "/requests", "w") as f:
+            f.write(command + " " + str(arg).replace("\\", "\\\\").replace("\n", "\\n") + "\n")
+    def _ask(self, command, label):
+        self._send(command, label)
+        with open(self.dir + "/responses") as f:
+            status, _, value = f.readline().rstrip("\n").partition(" ")
+        value = _rd_re.sub(r"\\(.)", lambda m: "\n" if m.group(1) == "n" else m.group(1), value)
+        if status != "ok":
+            raise RuntimeError(value)
+        return value
+    def set_env(self, name, value): self._send("set-env", "%s=%s" % (name, value))
+    def set_spinner(self, title): self._send("set-spinner", title)
+    def progress(self, current, total, label=""): self._send("progress", "%d/%d %s" % (current, total, label))
+    def log(self, message): self._send("log", message)
+    def prompt(self, label): return self._ask("prompt", label)
+    def confirm(self, label): return self._ask("confirm", label) == "yes"
+rundown = _RundownSDK()
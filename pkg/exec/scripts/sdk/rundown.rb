@@ -0,0 +1,21 @@
+module Rundown
+  # Talks to rundown using the protocol in RUNDOWN_SDK. See docs/sdk.md.
+  DIR = ENV.fetch("RUNDOWN_SDK", ":").split(":", 2)[1]
+  def self.send_command(command, arg = "")
+    raise "Not running within rundown" if DIR.nil? || DIR.empty?
+    File.open("#{DIR}/requests", "w") { |f| f.write("#{command} #{arg.to_s.gsub("\\") { "\\\\" }.gsub("\n", "\\n")}\n") }
+  end
+  def self.ask(command, label)
+    send_command(command, label)
+    status, value = File.open("#{DIR}/responses") { |f| f.gets.chomp }.split(" ", 2)
+    value = (value || "").gsub(/\\(.)/) { $1 == "n" ? "\n" : $1 }
+    raise value unless status == "ok"
+    value
+  end
+  def self.set_env(name, value); send_command("set-env", "#{name}=#{value}"); end
+  def self.set_spinner(title); send_command("set-spinner", title); end
+  def self.progress(current, total, label = ""); send_command("progress", "#{current}/#{total} #{label}"); end
+  def self.log(message); send_command("log", message); end
+  def self.prompt(label); ask("prompt", label); end
+  def self.confirm(label); ask("confirm", label) == "yes"; end
+end
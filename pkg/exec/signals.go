@@ -0,0 +1,83 @@
+package exec
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	rdutil "github.com/elseano/rundown/pkg/util"
+)
+
+// How long a script has to exit after being interrupted, before it's killed, unless the runner says otherwise.
+var CancelGracePeriod = 5 * time.Second
+
+// Passes SIGINT and SIGTERM on to a running command's process group, so the script and anything
+// it started can shut down cleanly. If they're still running after the grace period, they're killed.
+type signalForwarder struct {
+	pgid        int
+	gracePeriod time.Duration
+	signals     chan os.Signal
+	done        chan struct{}
+	stopped     chan struct{}
+	cancelled   atomic.Bool
+}
+
+// Starts forwarding signals to the command, which must have been started in its own process group.
+func forwardSignals(cmd *exec.Cmd, gracePeriod time.Duration) *signalForwarder {
+	f := &signalForwarder{
+		pgid:        cmd.Process.Pid,
+		gracePeriod: gracePeriod,
+		signals:     make(chan os.Signal, 1),
+		done:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+
+	signal.Notify(f.signals, syscall.SIGINT, syscall.SIGTERM)
+
+	go f.loop()
+
+	return f
+}
+
+func (f *signalForwarder) loop() {
+	defer close(f.stopped)
+
+	var kill <-chan time.Time
+
+	for {
+		select {
+		case sig := <-f.signals:
+			rdutil.Logger.Debug().Msgf("Forwarding %s to process group %d", sig, f.pgid)
+
+			f.cancelled.Store(true)
+			syscall.Kill(-f.pgid, sig.(syscall.Signal))
+
+			if kill == nil {
+				kill = time.After(f.gracePeriod)
+			}
+
+		case <-kill:
+			rdutil.Logger.Debug().Msgf("Process group %d didn't exit in time, killing", f.pgid)
+
+			syscall.Kill(-f.pgid, syscall.SIGKILL)
+
+		case <-f.done:
+			return
+		}
+	}
+}
+
+// Returns true if a signal was forwarded to the command.
+func (f *signalForwarder) Cancelled() bool {
+	return f.cancelled.Load()
+}
+
+// Stops forwarding signals, returning once the forwarder has finished. Call once the command has exited.
+func (f *signalForwarder) Stop() {
+	signal.Stop(f.signals)
+	close(f.done)
+	<-f.stopped
+}
@@ -0,0 +1,49 @@
+package exec
+
+import (
+	"io"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func runCancelled(t *testing.T, script string, gracePeriod time.Duration) (*Running, time.Duration) {
+	runner := NewRunner()
+	runner.CancelGracePeriod = gracePeriod
+
+	_, err := runner.SetScript("bash", "bash", []byte(script))
+	require.NoError(t, err)
+	defer runner.RemoveScripts()
+
+	running, err := runner.Prepare()
+	require.NoError(t, err)
+	require.NoError(t, running.Start())
+
+	go io.Copy(io.Discard, running.Stdout)
+	go io.Copy(io.Discard, running.Stderr)
+
+	time.Sleep(200 * time.Millisecond)
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGINT))
+
+	_, duration, err := running.Wait()
+	require.NoError(t, err)
+
+	return running, duration
+}
+
+func TestSignalsForwardedToScript(t *testing.T) {
+	running, duration := runCancelled(t, "trap 'exit 3' INT\nsleep 10 & wait", CancelGracePeriod)
+
+	require.True(t, running.Cancelled())
+	require.Less(t, duration, 5*time.Second)
+}
+
+func TestScriptKilledAfterGracePeriod(t *testing.T) {
+	running, duration := runCancelled(t, "trap '' INT\nsleep 10", 500*time.Millisecond)
+
+	require.True(t, running.Cancelled())
+	require.Less(t, duration, 5*time.Second)
+}
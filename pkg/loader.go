@@ -2,14 +2,18 @@ package rundown
 
 import (
 	"fmt"
+	"io/fs"
 	"io/ioutil"
 	"path"
+	"path/filepath"
 	"strings"
 
 	"github.com/elseano/rundown/pkg/ast"
+	"github.com/elseano/rundown/pkg/exec/scripts"
 	rundown_parser "github.com/elseano/rundown/pkg/parser"
 	"github.com/elseano/rundown/pkg/renderer"
 	"github.com/elseano/rundown/pkg/transformer"
+	rdutil "github.com/elseano/rundown/pkg/util"
 	emoji "github.com/yuin/goldmark-emoji"
 
 	termrend "github.com/elseano/rundown/pkg/renderer/term"
@@ -20,6 +24,10 @@ import (
 	"github.com/yuin/goldmark/util"
 )
 
+// The running version of rundown, checked against the min-version declared in front matter.
+// Empty for development builds, which skips the check.
+var Version string
+
 type LoadErrors []error
 
 func (le *LoadErrors) Error() string {
@@ -40,21 +48,49 @@ func LoadString(data string, filename string) (*LoadedDocuments, error) {
 		return nil, err
 	}
 
-	return cascadeLoad(parentDocument)
+	return cascadeLoad(parentDocument, ioutil.ReadFile)
 }
 
 func Load(filename string) (*LoadedDocuments, error) {
+	return load(filename, ioutil.ReadFile)
+}
+
+// Loads the document and its imports from fsys, such as a bundle, rather than the disk. The documents are
+// named as if they were in dir, which is where their code runs.
+func LoadFS(fsys fs.FS, name string, dir string) (*LoadedDocuments, error) {
+	loaded, err := load(filepath.Join(dir, name), func(filename string) ([]byte, error) {
+		rel, err := filepath.Rel(dir, filename)
+		if err != nil {
+			return nil, err
+		}
+
+		return fs.ReadFile(fsys, filepath.ToSlash(rel))
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// The directories imports came from don't exist outside fsys, so they run in dir too.
+	for _, document := range loaded.ImportedDocuments {
+		document.Filename = filepath.Join(dir, filepath.Base(document.Filename))
+	}
+
+	return loaded, nil
+}
+
+func load(filename string, readFile func(string) ([]byte, error)) (*LoadedDocuments, error) {
 	context := renderer.NewContext(filename)
-	parentDocument, err := loadFile(filename, context)
+	parentDocument, err := loadFile(filename, context, readFile)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return cascadeLoad(parentDocument)
+	return cascadeLoad(parentDocument, readFile)
 }
 
-func cascadeLoad(parentDocument *LoadedDocument) (*LoadedDocuments, error) {
+func cascadeLoad(parentDocument *LoadedDocument, readFile func(string) ([]byte, error)) (*LoadedDocuments, error) {
 	collection := &LoadedDocuments{
 		MasterDocument:    parentDocument,
 		ImportedDocuments: []*LoadedDocument{},
@@ -62,24 +98,26 @@ func cascadeLoad(parentDocument *LoadedDocument) (*LoadedDocuments, error) {
 	}
 
 	currentPath := path.Dir(parentDocument.Filename)
-	importDirectives := ast.ProcessImportBlocks(parentDocument.Document)
+	imports := parentDocument.FrontMatter.Imports
 
-	for _, directive := range importDirectives {
-		filename := directive.GetFilename()
+	for _, directive := range ast.ProcessImportBlocks(parentDocument.Document) {
+		imports = append(imports, ast.FrontMatterImport{Prefix: directive.ImportPrefix, File: directive.GetFilename()})
+	}
 
-		importedDoc, err := loadFile(path.Join(currentPath, filename), parentDocument.Context)
+	for _, directive := range imports {
+		importedDoc, err := loadFile(path.Join(currentPath, directive.File), parentDocument.Context, readFile)
 		if err != nil {
 			return nil, err
 		}
 
 		// If we have an import prefix, prepend it to the section names
-		if directive.ImportPrefix != "" {
+		if directive.Prefix != "" {
 			for _, section := range importedDoc.GetSections() {
-				section.SectionName = fmt.Sprintf("%s:%s", directive.ImportPrefix, section.SectionName)
+				section.SectionName = fmt.Sprintf("%s:%s", directive.Prefix, section.SectionName)
 			}
 
 			for _, invoke := range importedDoc.GetInvokes() {
-				invoke.Invoke = fmt.Sprintf("%s:%s", directive.ImportPrefix, invoke.Invoke)
+				invoke.Invoke = fmt.Sprintf("%s:%s", directive.Prefix, invoke.Invoke)
 			}
 		}
 
@@ -90,8 +128,8 @@ func cascadeLoad(parentDocument *LoadedDocument) (*LoadedDocuments, error) {
 
 }
 
-func loadFile(filename string, context *renderer.Context) (*LoadedDocument, error) {
-	source, err := ioutil.ReadFile(filename)
+func loadFile(filename string, context *renderer.Context, readFile func(string) ([]byte, error)) (*LoadedDocument, error) {
+	source, err := readFile(filename)
 
 	if err != nil {
 		return nil, &LoadErrors{err}
@@ -101,12 +139,31 @@ func loadFile(filename string, context *renderer.Context) (*LoadedDocument, erro
 }
 
 func loadBytes(source []byte, filename string, context *renderer.Context) (*LoadedDocument, error) {
+	frontMatter, source, err := ast.ParseFrontMatter(source)
+	if err != nil {
+		return nil, &LoadErrors{fmt.Errorf("%s: %w", filename, err)}
+	}
+
+	if frontMatter.MinVersion != "" && Version != "" && rdutil.CompareVersions(Version, frontMatter.MinVersion) < 0 {
+		return nil, &LoadErrors{fmt.Errorf("%s requires rundown %s or later, but this is %s", filename, frontMatter.MinVersion, Version)}
+	}
+
+	interpreters := scripts.Interpreters{}
+	if err := frontMatter.Interpreters.Decode(&interpreters); err != nil {
+		return nil, &LoadErrors{fmt.Errorf("%s: interpreters: %w", filename, err)}
+	}
+
+	context.ImportEnvDefaults(frontMatter.Env)
+	context.ImportInterpreters(interpreters)
+
 	consoleNodeRenderer := termrend.NewRenderer(context)
 	renderer := goldrenderer.WithNodeRenderers(
 		util.Prioritized(consoleNodeRenderer, 0),
 	)
 
 	rdtransform := transformer.NewRundownASTTransformer()
+	rdtransform.FrontMatter = frontMatter
+	rdtransform.Filename = filename
 
 	gm := goldmark.New(
 		goldmark.WithParserOptions(
@@ -127,10 +184,11 @@ func loadBytes(source []byte, filename string, context *renderer.Context) (*Load
 	}
 
 	return &LoadedDocument{
-		Filename: filename,
-		Document: doc,
-		Source:   source,
-		Goldmark: gm,
-		Context:  context,
+		Filename:    filename,
+		Document:    doc,
+		Source:      source,
+		Goldmark:    gm,
+		Context:     context,
+		FrontMatter: frontMatter,
 	}, nil
 }
@@ -0,0 +1,122 @@
+package ports
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	rundown "github.com/elseano/rundown/pkg"
+	"github.com/elseano/rundown/pkg/renderer/term"
+	"github.com/logrusorgru/aurora"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const cleanupSource = `
+# Stopped <r section="stopped"/>
+
+<r stop-ok if="$OPT_STOP == true">Nothing to do.</r>
+
+<r opt="stop" type="bool"/>
+
+<r spinner="Setting up"/>
+
+` + "``` bash" + `
+echo "SETUP RAN" >> runs.log
+` + "```" + `
+
+<r finally>
+
+<r spinner="Tearing down"/>
+
+` + "``` bash" + `
+echo "TEARDOWN RAN" >> runs.log
+` + "```" + `
+
+</r>
+
+# Conditional <r section="conditional"/>
+
+<r opt="prod" type="bool"/>
+
+<r finally>
+
+<r spinner="Always"/>
+
+` + "``` bash" + `
+echo "ALWAYS RAN" >> runs.log
+` + "```" + `
+
+</r>
+
+## Production <r if="$OPT_PROD == true"/>
+
+<r finally>
+
+<r spinner="Production"/>
+
+` + "``` bash" + `
+echo "PRODUCTION RAN" >> runs.log
+` + "```" + `
+
+</r>
+`
+
+// Runs the section, returning what its scripts logged.
+func runCleanup(t *testing.T, name string, options map[string]string) string {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "tool.md")
+	require.NoError(t, os.WriteFile(filename, []byte(cleanupSource), 0644))
+
+	loaded, err := rundown.Load(filename)
+	require.NoError(t, err)
+
+	section := findSection(loaded, name)
+	require.NotNil(t, section)
+
+	out := &bytes.Buffer{}
+	_, err = runSection(out, section, options, nil, false)
+	require.NoError(t, err, out.String())
+
+	log, _ := os.ReadFile(filepath.Join(dir, "runs.log"))
+	return string(log)
+}
+
+func TestCleanupRunsWhenReached(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash isn't installed")
+	}
+
+	term.Aurora = aurora.NewAurora(false)
+	term.ColorsEnabled = false
+
+	t.Run("after the setup", func(t *testing.T) {
+		out := runCleanup(t, "stopped", map[string]string{})
+
+		assert.Contains(t, out, "SETUP RAN")
+		assert.Contains(t, out, "TEARDOWN RAN")
+	})
+
+	t.Run("not after stop-ok", func(t *testing.T) {
+		out := runCleanup(t, "stopped", map[string]string{"OPT_STOP": "true"})
+
+		assert.NotContains(t, out, "SETUP RAN")
+		assert.NotContains(t, out, "TEARDOWN RAN")
+	})
+
+	t.Run("not under a false conditional", func(t *testing.T) {
+		out := runCleanup(t, "conditional", map[string]string{})
+
+		assert.Contains(t, out, "ALWAYS RAN")
+		assert.NotContains(t, out, "PRODUCTION RAN")
+	})
+
+	t.Run("under a true conditional", func(t *testing.T) {
+		out := runCleanup(t, "conditional", map[string]string{"OPT_PROD": "true"})
+
+		assert.Contains(t, out, "ALWAYS RAN")
+		assert.Contains(t, out, "PRODUCTION RAN")
+	})
+}
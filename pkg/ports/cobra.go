@@ -1,16 +1,13 @@
 package ports
 
 import (
-	"errors"
 	"fmt"
 	"os"
-	"path"
 	"strconv"
 	"strings"
 
 	rundown "github.com/elseano/rundown/pkg"
 	"github.com/elseano/rundown/pkg/ast"
-	"github.com/elseano/rundown/pkg/errs"
 	"github.com/elseano/rundown/pkg/renderer/term"
 	"github.com/muesli/reflow/indent"
 	"golang.org/x/exp/maps"
@@ -20,40 +17,12 @@ import (
 
 	rdutil "github.com/elseano/rundown/pkg/util"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"golang.org/x/exp/slices"
 )
 
-type optVal struct {
-	Str    *string
-	Int    *int
-	Bool   *bool
-	Option *ast.SectionOption
-}
-
-func (o optVal) String() string {
-	if o.Str != nil {
-		return *o.Str
-	}
-
-	if o.Int != nil {
-		return fmt.Sprintf("%d", *o.Int)
-	}
-
-	if o.Bool != nil {
-		if *o.Bool {
-			return "true"
-		} else {
-			return "false"
-		}
-	}
-
-	return "<err>"
-}
-
 func BuildCobraCommand(filename string, section *rundown.Section, writeLog bool) *cobra.Command {
-	optionEnv := map[string]optVal{}
-
 	sectionPointer := section.Pointer
-	doc := section.Document.Document
 	source := section.Document.Source
 	gm := section.Document.Goldmark
 
@@ -78,52 +47,11 @@ func BuildCobraCommand(filename string, section *rundown.Section, writeLog bool)
 				rdutil.RedirectLogger(devNull)
 			}
 
-			executionContext := section.Document.Context
-			executionContext.ImportRawEnv(os.Environ())
-			executionContext.RundownFile = section.Document.Filename
-
-			optionEnvStr := map[string]string{}
-			for k, v := range optionEnv {
-				optionEnvStr[k] = v.String()
-			}
-
-			parsed, err := sectionPointer.ParseOptions(optionEnvStr)
-
-			if err != nil {
-				return err
-			}
-
-			executionContext.ImportEnv(parsed)
-
-			if err := ast.FillInvokeBlocks(doc, 10); err != nil {
-				return err
-			}
+			dumpAst, _ := cmd.Flags().GetBool("dump")
+			section.Document.Context.AssumeYes, _ = cmd.Flags().GetBool("yes")
+			section.Document.Context.KeepWorkspace, _ = cmd.Flags().GetBool("keep-workspace")
 
-			doc = ast.PruneDocumentToSection(doc, sectionPointer.SectionName)
-			sectionPointer.SetIfScript("") // Ensure the requested section runs.
-
-			if val, err := cmd.Flags().GetBool("dump"); err == nil && val {
-				doc.Dump(source, 1)
-			}
-
-			out := rdutil.CaptureStdout(func() {
-				doc.Dump(source, 0)
-			})
-
-			rdutil.Logger.Debug().Msg(out)
-
-			rdutil.Logger.Info().Msgf("Running %s in %s...\n\n", sectionPointer.SectionName, section.Document.Filename)
-
-			executionContext.ImportEnv(map[string]string{"PWD": path.Dir(executionContext.RundownFile)})
-
-			err = gm.Renderer().Render(os.Stdout, source, doc)
-
-			switch {
-			case errors.Is(err, errs.ErrStopOk):
-				return nil
-			default:
-				return err
-			}
+			return RunSection(section, ChangedOptions(cmd, section), dumpAst)
 		},
 	}
 
@@ -139,29 +67,33 @@ func BuildCobraCommand(filename string, section *rundown.Section, writeLog bool)
 
 		switch topt := opt.OptionType.(type) {
 		case *ast.TypeString:
-			optionEnv[opt.OptionAs] = optVal{Str: command.Flags().String(opt.OptionName, opt.OptionDefault.String, opt.OptionDescription), Option: opt}
+			command.Flags().String(opt.OptionName, opt.OptionDefault.String, opt.OptionDescription)
 			command.RegisterFlagCompletionFunc(opt.OptionName, stringCompletionFunction(opt, topt))
-			command.Flags()
 		case *ast.TypeInt:
 			defaultVal, _ := strconv.Atoi(opt.OptionDefault.String)
-			optionEnv[opt.OptionAs] = optVal{Int: command.Flags().Int(opt.OptionName, defaultVal, opt.OptionDescription), Option: opt}
-			command.Flags()
+			command.Flags().Int(opt.OptionName, defaultVal, opt.OptionDescription)
 		case *ast.TypeBoolean:
-			optionEnv[opt.OptionAs] = optVal{Bool: command.Flags().Bool(opt.OptionName, topt.Normalise(opt.OptionDefault.String) == "true", opt.OptionDescription), Option: opt}
+			command.Flags().Bool(opt.OptionName, topt.Normalise(opt.OptionDefault.String) == "true", opt.OptionDescription)
 			command.RegisterFlagCompletionFunc(opt.OptionName, boolCompletionFunction(opt, topt))
 		case *ast.TypeEnum:
-			optionEnv[opt.OptionAs] = optVal{Str: command.Flags().String(opt.OptionName, opt.OptionDefault.String, opt.OptionDescription), Option: opt}
+			command.Flags().String(opt.OptionName, opt.OptionDefault.String, opt.OptionDescription)
 			command.RegisterFlagCompletionFunc(opt.OptionName, enumCompletionFunction(topt))
 		case *ast.TypeFilename:
-			optionEnv[opt.OptionAs] = optVal{Str: command.Flags().String(opt.OptionName, opt.OptionDefault.String, opt.OptionDescription), Option: opt}
+			command.Flags().String(opt.OptionName, opt.OptionDefault.String, opt.OptionDescription)
 			command.RegisterFlagCompletionFunc(opt.OptionName, filenameCompletionFunction(topt))
 		case *ast.TypePath:
-			optionEnv[opt.OptionAs] = optVal{Str: command.Flags().String(opt.OptionName, opt.OptionDefault.String, opt.OptionDescription), Option: opt}
+			command.Flags().String(opt.OptionName, opt.OptionDefault.String, opt.OptionDescription)
 			command.RegisterFlagCompletionFunc(opt.OptionName, pathCompletionFunction(topt))
 		case *ast.TypeKV:
-			optionEnv[opt.OptionAs] = optVal{Str: command.Flags().String(opt.OptionName, opt.OptionDefault.String, opt.OptionDescription), Option: opt}
+			command.Flags().String(opt.OptionName, opt.OptionDefault.String, opt.OptionDescription)
 			command.RegisterFlagCompletionFunc(opt.OptionName, kvCompletionFunction(topt))
-
+		case *ast.TypeEnumFrom:
+			command.Flags().String(opt.OptionName, opt.OptionDefault.String, opt.OptionDescription)
+			command.RegisterFlagCompletionFunc(opt.OptionName, enumFromCompletionFunction(topt))
+		default:
+			// Other types are given as strings, and checked when the section runs.
+			command.Flags().String(opt.OptionName, opt.OptionDefault.String, opt.OptionDescription)
+			command.RegisterFlagCompletionFunc(opt.OptionName, noCompletionFunction())
 		}
 
 		if opt.OptionRequired && !opt.OptionDefault.Valid {
@@ -173,6 +105,28 @@ func BuildCobraCommand(filename string, section *rundown.Section, writeLog bool)
 
 	}
 
+	groups := []string{}
+
+	for _, opt := range sectionPointer.Options {
+		for _, name := range opt.Conflicts {
+			if command.Flags().Lookup(name) != nil {
+				command.MarkFlagsMutuallyExclusive(opt.OptionName, name)
+			}
+		}
+
+		if opt.Group != "" {
+			if !slices.Contains(groups, opt.Group) {
+				groups = append(groups, opt.Group)
+			}
+
+			command.Flags().SetAnnotation(opt.OptionName, flagGroupAnnotation, []string{opt.Group, strconv.Itoa(slices.Index(groups, opt.Group))})
+		}
+	}
+
+	if len(groups) > 0 {
+		command.SetUsageTemplate(strings.Replace(command.UsageTemplate(), "{{.LocalFlags.FlagUsages | trimTrailingWhitespaces}}", "{{groupedFlagUsages .LocalFlags | trimTrailingWhitespaces}}", 1))
+	}
+
 	command.SetFlagErrorFunc(func(errCmd *cobra.Command, err error) error {
 		fmt.Println(command.Help())
 		fmt.Println()
@@ -183,12 +137,83 @@ func BuildCobraCommand(filename string, section *rundown.Section, writeLog bool)
 	return &command
 }
 
+// Returns the values of the section's options which were given to the command, keyed by their environment
+// name (i.e. OPT_NAME). Only flags which were passed are given, so defaults, requires and conflicts are applied
+// when parsing.
+func ChangedOptions(cmd *cobra.Command, section *rundown.Section) map[string]string {
+	options := map[string]string{}
+
+	for _, opt := range section.Pointer.Options {
+		if flag := cmd.Flags().Lookup(opt.OptionName); flag != nil && flag.Changed {
+			options[opt.OptionAs] = flag.Value.String()
+		}
+	}
+
+	return options
+}
+
+// The annotation holding a flag's group name and position, for listing it under its own heading in help.
+const flagGroupAnnotation = "rundown_group"
+
+func init() {
+	cobra.AddTemplateFunc("groupedFlagUsages", groupedFlagUsages)
+}
+
+// Lists the flags without a group, followed by each group's flags under a heading of their own.
+func groupedFlagUsages(flags *pflag.FlagSet) string {
+	ungrouped := pflag.NewFlagSet("", pflag.ContinueOnError)
+	grouped := map[int]*pflag.FlagSet{}
+	names := map[int]string{}
+
+	flags.VisitAll(func(flag *pflag.Flag) {
+		group, ok := flag.Annotations[flagGroupAnnotation]
+		if !ok {
+			ungrouped.AddFlag(flag)
+			return
+		}
+
+		index, _ := strconv.Atoi(group[1])
+		if grouped[index] == nil {
+			grouped[index] = pflag.NewFlagSet(group[0], pflag.ContinueOnError)
+			names[index] = group[0]
+		}
+
+		grouped[index].AddFlag(flag)
+	})
+
+	usages := strings.Builder{}
+	usages.WriteString(ungrouped.FlagUsages())
+
+	indexes := maps.Keys(grouped)
+	slices.Sort(indexes)
+
+	for _, index := range indexes {
+		usages.WriteString("\n" + names[index] + " Flags:\n")
+		usages.WriteString(grouped[index].FlagUsages())
+	}
+
+	return usages.String()
+}
+
 func enumCompletionFunction(opt *ast.TypeEnum) func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		return opt.ValidValues, cobra.ShellCompDirectiveNoFileComp
 	}
 }
 
+// Completes with the values listed by the option's command. The command only runs when completing this flag.
+func enumFromCompletionFunction(opt *ast.TypeEnumFrom) func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		values, err := opt.Values()
+		if err != nil {
+			cobra.CompDebugln(err.Error(), true)
+			return nil, cobra.ShellCompDirectiveError
+		}
+
+		return values, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
 func kvCompletionFunction(opt *ast.TypeKV) func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		return maps.Keys(opt.Pairs), cobra.ShellCompDirectiveNoFileComp
@@ -210,7 +235,13 @@ func boolCompletionFunction(opt *ast.SectionOption, topt *ast.TypeBoolean) func(
 }
 
 func filenameCompletionFunction(opt *ast.TypeFilename) func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-	if opt.MustExist {
+	if opt.Directory {
+		return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return nil, cobra.ShellCompDirectiveFilterDirs
+		}
+	}
+
+	if opt.MustExist || opt.Readable || opt.Writable || opt.Executable {
 		return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 			// return cobra.AppendActiveHelp([]string{""}, "Requires a file which exists"), cobra.ShellCompDirectiveDefault
 			return nil, cobra.ShellCompDirectiveDefault
@@ -223,6 +254,12 @@ func filenameCompletionFunction(opt *ast.TypeFilename) func(cmd *cobra.Command,
 	}
 }
 
+func noCompletionFunction() func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
 func pathCompletionFunction(opt *ast.TypePath) func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		// return cobra.AppendActiveHelp([]string{""}, "Requires a file which exists"), cobra.ShellCompDirectiveDefault
@@ -0,0 +1,488 @@
+package ports
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	rundown "github.com/elseano/rundown/pkg"
+	"github.com/elseano/rundown/pkg/ast"
+	"github.com/yuin/goldmark"
+	goldast "github.com/yuin/goldmark/ast"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+// The formats WriteDocs supports.
+var DocsFormats = []string{"man", "markdown", "html"}
+
+// Writes a reference of every command the loaded documents provide, so it can be shipped alongside them.
+// The name is what the commands are run with, i.e. rundown.
+func WriteDocs(w io.Writer, loaded *rundown.LoadedDocuments, name string, format string) error {
+	reference := buildReference(loaded, name)
+
+	switch format {
+	case "man":
+		return writeManDocs(w, reference)
+	case "markdown", "md":
+		return writeMarkdownDocs(w, reference)
+	case "html":
+		return writeHTMLDocs(w, reference)
+	}
+
+	return fmt.Errorf("unknown docs format %q, expected one of: %s", format, strings.Join(DocsFormats, ", "))
+}
+
+type docsReference struct {
+	Name        string
+	Description string // Markdown, from the document's root help.
+	Commands    []docsCommand
+}
+
+type docsCommand struct {
+	Name         string
+	Short        string
+	Long         string // Markdown, from the section's description.
+	Usage        string
+	Example      string
+	Options      []docsOption
+	Dependencies []string
+}
+
+type docsOption struct {
+	Flag        string
+	Type        string
+	Description string
+	Default     string
+	Env         string
+	Required    bool
+	Notes       []string // Requires, conflicts and required-if, as sentences.
+	Group       string
+}
+
+func buildReference(loaded *rundown.LoadedDocuments, name string) docsReference {
+	reference := docsReference{Name: name}
+
+	if help := ast.GetRootHelp(loaded.MasterDocument.Document); help != nil {
+		reference.Description = markdownSource(help, loaded.MasterDocument.Source)
+	}
+
+	for _, section := range loaded.GetSections() {
+		if section.Pointer.Silent {
+			continue
+		}
+
+		reference.Commands = append(reference.Commands, buildCommandDocs(section, name))
+	}
+
+	return reference
+}
+
+func buildCommandDocs(section *rundown.Section, name string) docsCommand {
+	pointer := section.Pointer
+
+	command := docsCommand{
+		Name:  pointer.SectionName,
+		Short: pointer.DescriptionShort,
+	}
+
+	if pointer.DescriptionLong != nil {
+		command.Long = markdownSource(pointer.DescriptionLong, section.Document.Source)
+	}
+
+	usage := []string{name, pointer.SectionName}
+	example := []string{name, pointer.SectionName}
+
+	for _, opt := range pointer.Options {
+		option := docsOption{
+			Flag:        "--" + opt.OptionName,
+			Type:        opt.OptionType.InputType(),
+			Description: opt.OptionDescription,
+			Default:     opt.OptionDefault.String,
+			Env:         opt.OptionAs,
+			Required:    opt.OptionRequired && !opt.OptionDefault.Valid,
+			Group:       opt.Group,
+		}
+
+		for _, other := range opt.Requires {
+			option.Notes = append(option.Notes, fmt.Sprintf("Requires --%s.", other))
+		}
+
+		for _, other := range opt.Conflicts {
+			option.Notes = append(option.Notes, fmt.Sprintf("Can't be used with --%s.", other))
+		}
+
+		if opt.RequiredIf != "" {
+			option.Notes = append(option.Notes, fmt.Sprintf("Required when %s.", opt.RequiredIf))
+		}
+
+		if option.Required {
+			usage = append(usage, fmt.Sprintf("%s <%s>", option.Flag, option.Type))
+			example = append(example, fmt.Sprintf("%s %s", option.Flag, exampleValue(opt)))
+		}
+
+		command.Options = append(command.Options, option)
+	}
+
+	if len(pointer.Options) > 0 {
+		usage = append(usage, "[flags]")
+	}
+
+	command.Usage = strings.Join(usage, " ")
+	command.Example = strings.Join(example, " ")
+
+	for _, dependency := range pointer.Dependencies {
+		if dependency != nil && !slices.Contains(command.Dependencies, dependency.SectionName) {
+			command.Dependencies = append(command.Dependencies, dependency.SectionName)
+		}
+	}
+
+	return command
+}
+
+// A value for the option which would be accepted, or a placeholder naming its type.
+func exampleValue(opt *ast.SectionOption) string {
+	switch t := opt.OptionType.(type) {
+	case *ast.TypeEnum:
+		return t.ValidValues[0]
+	case *ast.TypeKV:
+		keys := maps.Keys(t.Pairs)
+		sort.Strings(keys)
+		return keys[0]
+	}
+
+	return "<" + opt.OptionType.InputType() + ">"
+}
+
+// Returns the markdown a description was written with. Descriptions given as attributes have no source, so their text is used.
+func markdownSource(node goldast.Node, source []byte) string {
+	start, stop := -1, -1
+	text := strings.Builder{}
+
+	goldast.Walk(node, func(n goldast.Node, entering bool) (goldast.WalkStatus, error) {
+		if !entering {
+			return goldast.WalkContinue, nil
+		}
+
+		extend := func(segmentStart int, segmentStop int) {
+			if start == -1 || segmentStart < start {
+				start = segmentStart
+			}
+
+			if segmentStop > stop {
+				stop = segmentStop
+			}
+		}
+
+		switch n := n.(type) {
+		case *goldast.String:
+			text.Write(n.Value)
+		case *goldast.Text:
+			extend(n.Segment.Start, n.Segment.Stop)
+		default:
+			if n.Type() == goldast.TypeBlock {
+				for i := 0; i < n.Lines().Len(); i++ {
+					line := n.Lines().At(i)
+					extend(line.Start, line.Stop)
+				}
+			}
+		}
+
+		return goldast.WalkContinue, nil
+	})
+
+	if start == -1 {
+		return strings.TrimSpace(text.String())
+	}
+
+	return strings.TrimSpace(string(source[start:stop]))
+}
+
+// Splits options into those without a group, followed by each group in the order they appear.
+func groupOptions(options []docsOption) ([]string, map[string][]docsOption) {
+	groups := []string{""}
+	grouped := map[string][]docsOption{}
+
+	for _, option := range options {
+		if !slices.Contains(groups, option.Group) {
+			groups = append(groups, option.Group)
+		}
+
+		grouped[option.Group] = append(grouped[option.Group], option)
+	}
+
+	return groups, grouped
+}
+
+func groupHeading(group string) string {
+	if group == "" {
+		return "Options"
+	}
+
+	return group + " options"
+}
+
+// Describes an option, followed by whether it's required, its default, notes and environment variable.
+func optionText(option docsOption) string {
+	details := []string{}
+
+	if description := strings.TrimSpace(option.Description); description != "" {
+		if !strings.HasSuffix(description, ".") {
+			description += "."
+		}
+
+		details = append(details, description)
+	}
+
+	if option.Required {
+		details = append(details, "Required.")
+	}
+
+	if option.Default != "" {
+		details = append(details, fmt.Sprintf("Defaults to %s.", option.Default))
+	}
+
+	details = append(details, option.Notes...)
+	details = append(details, fmt.Sprintf("Sets $%s.", option.Env))
+
+	return strings.Join(details, " ")
+}
+
+func writeMarkdownDocs(w io.Writer, reference docsReference) error {
+	out := &strings.Builder{}
+
+	fmt.Fprintf(out, "# %s\n\n", reference.Name)
+
+	if reference.Description != "" {
+		fmt.Fprintf(out, "%s\n\n", reference.Description)
+	}
+
+	fmt.Fprintf(out, "## Commands\n\n")
+
+	for _, command := range reference.Commands {
+		fmt.Fprintf(out, "* [`%s`](#%s) - %s\n", command.Name, markdownAnchor(command.Name), command.Short)
+	}
+
+	for _, command := range reference.Commands {
+		fmt.Fprintf(out, "\n## %s\n\n", command.Name)
+
+		if command.Short != "" {
+			fmt.Fprintf(out, "%s\n\n", command.Short)
+		}
+
+		if command.Long != "" {
+			fmt.Fprintf(out, "%s\n\n", command.Long)
+		}
+
+		fmt.Fprintf(out, "```\n%s\n```\n", command.Usage)
+
+		groups, grouped := groupOptions(command.Options)
+
+		for _, group := range groups {
+			if len(grouped[group]) == 0 {
+				continue
+			}
+
+			fmt.Fprintf(out, "\n### %s\n\n", groupHeading(group))
+
+			for _, option := range grouped[group] {
+				fmt.Fprintf(out, "* `%s` (%s) - %s\n", option.Flag, option.Type, optionText(option))
+			}
+		}
+
+		if len(command.Dependencies) > 0 {
+			fmt.Fprintf(out, "\nRuns `%s` first.\n", strings.Join(command.Dependencies, "`, `"))
+		}
+
+		if command.Example != command.Usage {
+			fmt.Fprintf(out, "\n### Example\n\n```\n%s\n```\n", command.Example)
+		}
+	}
+
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+var nonAnchor = regexp.MustCompile(`[^a-z0-9_ -]`)
+
+// The anchor GitHub gives a heading.
+func markdownAnchor(heading string) string {
+	return strings.ReplaceAll(nonAnchor.ReplaceAllString(strings.ToLower(heading), ""), " ", "-")
+}
+
+func writeManDocs(w io.Writer, reference docsReference) error {
+	out := &strings.Builder{}
+
+	fmt.Fprintf(out, ".TH \"%s\" \"1\" \"\" \"\" \"\"\n", manQuote(strings.ToUpper(reference.Name)))
+	fmt.Fprintf(out, ".SH \"NAME\"\n\\fB%s\\fR\n", manEscape(reference.Name))
+	fmt.Fprintf(out, ".SH \"SYNOPSIS\"\n\\fB%s\\fR [\\fIcommand\\fR] [\\fIflags\\fR\\.\\.\\.]\n", manEscape(reference.Name))
+
+	if reference.Description != "" {
+		fmt.Fprintf(out, ".SH \"DESCRIPTION\"\n")
+		writeManParagraphs(out, reference.Description)
+	}
+
+	fmt.Fprintf(out, ".SH \"COMMANDS\"\n")
+
+	for _, command := range reference.Commands {
+		fmt.Fprintf(out, ".SS \"%s\"\n", manQuote(command.Name))
+
+		if command.Short != "" {
+			writeManParagraphs(out, command.Short)
+		}
+
+		if command.Long != "" {
+			writeManParagraphs(out, command.Long)
+		}
+
+		fmt.Fprintf(out, ".P\n\\fB%s\\fR\n", manEscape(command.Usage))
+
+		groups, grouped := groupOptions(command.Options)
+
+		for _, group := range groups {
+			if len(grouped[group]) == 0 {
+				continue
+			}
+
+			fmt.Fprintf(out, ".P\n\\fI%s\\fR\n", manEscape(groupHeading(group)))
+
+			for _, option := range grouped[group] {
+				fmt.Fprintf(out, ".TP\n\\fB%s\\fR \\fI%s\\fR\n", manEscape(option.Flag), manEscape(option.Type))
+				fmt.Fprintf(out, "%s\n", manEscape(optionText(option)))
+			}
+		}
+
+		if len(command.Dependencies) > 0 {
+			fmt.Fprintf(out, ".P\nRuns %s first\\.\n", manEscape(strings.Join(command.Dependencies, ", ")))
+		}
+
+		if command.Example != command.Usage {
+			fmt.Fprintf(out, ".P\nExample:\n.IP \"\" 4\n.nf\n%s\n.fi\n", manEscape(command.Example))
+		}
+	}
+
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+func writeManParagraphs(out *strings.Builder, text string) {
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+			fmt.Fprintf(out, ".P\n%s\n", manEscape(paragraph))
+		}
+	}
+}
+
+// Escapes text for roff. Apostrophes are written as \(aq, as roff shows \' as an acute accent, which also
+// stops them being read as a control character. Lines starting with a period are protected with \&.
+func manEscape(text string) string {
+	text = strings.NewReplacer(`\`, `\e`, "-", `\-`, "'", `\(aq`).Replace(text)
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+
+		if strings.HasPrefix(lines[i], ".") {
+			lines[i] = `\&` + lines[i]
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Escapes text for a quoted macro argument, such as the title given to .TH or .SS.
+func manQuote(text string) string {
+	return strings.ReplaceAll(manEscape(text), `"`, `\(dq`)
+}
+
+func writeHTMLDocs(w io.Writer, reference docsReference) error {
+	out := &strings.Builder{}
+
+	fmt.Fprintf(out, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n</head>\n<body>\n", html.EscapeString(reference.Name))
+	fmt.Fprintf(out, "<h1>%s</h1>\n", html.EscapeString(reference.Name))
+
+	if err := writeHTMLMarkdown(out, reference.Description); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "<h2>Commands</h2>\n<ul>\n")
+
+	for _, command := range reference.Commands {
+		fmt.Fprintf(out, "<li><a href=\"#%s\"><code>%s</code></a> - %s</li>\n", html.EscapeString(command.Name), html.EscapeString(command.Name), html.EscapeString(command.Short))
+	}
+
+	fmt.Fprintf(out, "</ul>\n")
+
+	for _, command := range reference.Commands {
+		fmt.Fprintf(out, "<h2 id=\"%s\">%s</h2>\n", html.EscapeString(command.Name), html.EscapeString(command.Name))
+
+		if command.Short != "" {
+			fmt.Fprintf(out, "<p>%s</p>\n", html.EscapeString(command.Short))
+		}
+
+		if err := writeHTMLMarkdown(out, command.Long); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "<pre><code>%s</code></pre>\n", html.EscapeString(command.Usage))
+
+		groups, grouped := groupOptions(command.Options)
+
+		for _, group := range groups {
+			if len(grouped[group]) == 0 {
+				continue
+			}
+
+			fmt.Fprintf(out, "<h3>%s</h3>\n<dl>\n", html.EscapeString(groupHeading(group)))
+
+			for _, option := range grouped[group] {
+				fmt.Fprintf(out, "<dt><code>%s</code> <em>%s</em></dt>\n", html.EscapeString(option.Flag), html.EscapeString(option.Type))
+				fmt.Fprintf(out, "<dd>%s</dd>\n", html.EscapeString(optionText(option)))
+			}
+
+			fmt.Fprintf(out, "</dl>\n")
+		}
+
+		if len(command.Dependencies) > 0 {
+			fmt.Fprintf(out, "<p>Runs <code>%s</code> first.</p>\n", strings.Join(htmlEscapeAll(command.Dependencies), "</code>, <code>"))
+		}
+
+		if command.Example != command.Usage {
+			fmt.Fprintf(out, "<h3>Example</h3>\n<pre><code>%s</code></pre>\n", html.EscapeString(command.Example))
+		}
+	}
+
+	fmt.Fprintf(out, "</body>\n</html>\n")
+
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+// Converts a description's markdown to HTML. Raw HTML in the description is left out.
+func writeHTMLMarkdown(out *strings.Builder, markdown string) error {
+	if markdown == "" {
+		return nil
+	}
+
+	converted := bytes.Buffer{}
+	if err := goldmark.New().Convert([]byte(markdown), &converted); err != nil {
+		return err
+	}
+
+	out.Write(converted.Bytes())
+	return nil
+}
+
+func htmlEscapeAll(values []string) []string {
+	escaped := []string{}
+
+	for _, value := range values {
+		escaped = append(escaped, html.EscapeString(value))
+	}
+
+	return escaped
+}
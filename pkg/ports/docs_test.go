@@ -0,0 +1,100 @@
+package ports
+
+import (
+	"strings"
+	"testing"
+
+	rundown "github.com/elseano/rundown/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const docsSource = `
+<r help>
+
+The deploy tool.
+
+</r>
+
+# Build <r section="build"/>
+
+# Deploy <r section="deploy"/>
+
+<r desc>Ships the **build**.</r>
+
+<r opt="env" type="enum:staging|production" required desc="Where to deploy"/>
+<r opt="tag" type="string" default="latest" desc="The image tag" group="Image"/>
+<r opt="dry-run" type="bool" conflicts="tag" desc="Don't deploy"/>
+
+<r dep="build"/>
+
+# Hidden <r section="hidden" silent/>
+`
+
+func TestWriteDocs(t *testing.T) {
+	loaded, err := rundown.LoadString(docsSource, "deploy.md")
+	require.NoError(t, err)
+
+	t.Run("markdown", func(t *testing.T) {
+		out := strings.Builder{}
+		require.NoError(t, WriteDocs(&out, loaded, "deploy", "markdown"))
+
+		docs := out.String()
+		assert.Contains(t, docs, "The deploy tool.")
+		assert.Contains(t, docs, "## deploy\n")
+		assert.Contains(t, docs, "Ships the **build**.")
+		assert.Contains(t, docs, "deploy deploy --env <string> [flags]")
+		assert.Contains(t, docs, "* `--env` (string) - Where to deploy (staging, production). Required. Sets $OPT_ENV.")
+		assert.Contains(t, docs, "### Image options\n\n* `--tag` (string) - The image tag (any value). Defaults to latest. Sets $OPT_TAG.")
+		assert.Contains(t, docs, "Can't be used with --tag.")
+		assert.Contains(t, docs, "Runs `build` first.")
+		assert.Contains(t, docs, "deploy deploy --env staging\n")
+		assert.NotContains(t, docs, "hidden")
+	})
+
+	t.Run("man", func(t *testing.T) {
+		out := strings.Builder{}
+		require.NoError(t, WriteDocs(&out, loaded, "deploy", "man"))
+
+		docs := out.String()
+		assert.True(t, strings.HasPrefix(docs, ".TH \"DEPLOY\" \"1\""))
+		assert.Contains(t, docs, ".SS \"deploy\"\n")
+		assert.Contains(t, docs, ".TP\n\\fB\\-\\-env\\fR \\fIstring\\fR\n")
+		assert.Contains(t, docs, "\\fIImage options\\fR")
+	})
+
+	t.Run("html", func(t *testing.T) {
+		out := strings.Builder{}
+		require.NoError(t, WriteDocs(&out, loaded, "deploy", "html"))
+
+		docs := out.String()
+		assert.Contains(t, docs, "<h2 id=\"deploy\">deploy</h2>")
+		assert.Contains(t, docs, "<p>Ships the <strong>build</strong>.</p>")
+		assert.Contains(t, docs, "deploy deploy --env &lt;string&gt; [flags]")
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		err := WriteDocs(&strings.Builder{}, loaded, "deploy", "pdf")
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "unknown docs format \"pdf\"")
+	})
+}
+
+func TestWriteManDocsEscaping(t *testing.T) {
+	reference := docsReference{
+		Name:        `bob's "tool"`,
+		Description: "Don't \"ship\" it.\n.TH looks like a macro",
+		Commands: []docsCommand{
+			{Name: `it's "live"`, Usage: "deploy it"},
+		},
+	}
+
+	out := strings.Builder{}
+	require.NoError(t, writeManDocs(&out, reference))
+
+	docs := out.String()
+	assert.True(t, strings.HasPrefix(docs, ".TH \"BOB\\(aqS \\(dqTOOL\\(dq\" \"1\""))
+	assert.Contains(t, docs, ".SS \"it\\(aqs \\(dqlive\\(dq\"\n")
+	assert.Contains(t, docs, ".P\nDon\\(aqt \"ship\" it.\n\\&.TH looks like a macro\n")
+	assert.NotContains(t, docs, "\\'")
+}
@@ -0,0 +1,1071 @@
+package ports
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	rundown "github.com/elseano/rundown/pkg"
+	"github.com/elseano/rundown/pkg/ast"
+	"github.com/elseano/rundown/pkg/condition"
+	"github.com/elseano/rundown/pkg/exec/scripts"
+	"github.com/elseano/rundown/pkg/renderer"
+	"github.com/elseano/rundown/pkg/text"
+	"github.com/elseano/rundown/pkg/util"
+	goldast "github.com/yuin/goldmark/ast"
+	"golang.org/x/exp/maps"
+)
+
+// The formats ExportSection supports.
+var ExportFormats = []string{"bash"}
+
+// The exit status an invoked section's subshell uses when it stops successfully, so the whole script stops too.
+const stoppedStatus = 100
+
+// Writes the section as a standalone script, for running where rundown can't be installed.
+// Content the script can't express, such as on-failure handlers, is an error rather than being left out.
+func ExportSection(w io.Writer, section *rundown.Section, format string) error {
+	if format != "bash" {
+		return fmt.Errorf("unknown export format %q, expected one of: %s", format, strings.Join(ExportFormats, ", "))
+	}
+
+	if err := ast.FillInvokeBlocks(section.Document.Document, 10); err != nil {
+		return err
+	}
+
+	doc := ast.PruneDocumentToSection(section.Document.Document, section.Pointer.SectionName)
+	section.Pointer.SetIfScript("") // The exported section always runs.
+
+	exporter := &bashExporter{
+		document:     section.Document,
+		interpreters: scripts.DefaultInterpreters().Merge(section.Document.Context.Interpreters),
+		branches:     map[*ast.ConditionalStart]string{},
+		hasElse:      map[*ast.ConditionalStart]bool{},
+		helpers:      map[string]bool{},
+	}
+
+	script, err := exporter.export(section.Pointer, doc)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, script)
+	return err
+}
+
+type bashExporter struct {
+	document     *rundown.LoadedDocument
+	interpreters scripts.Interpreters
+
+	out       *strings.Builder
+	indent    int
+	subshells int // How many invoked sections deep the output is, as each runs in a subshell.
+	files     int // Numbers the files the script writes into its workspace.
+
+	branches map[*ast.ConditionalStart]string // The variable recording whether a branch in the chain has run.
+	open     []openBranch                     // Branches whose if statement is still open.
+	hasElse  map[*ast.ConditionalStart]bool
+	helpers  map[string]bool // The bashHelpers the script uses.
+}
+
+type openBranch struct {
+	start *ast.ConditionalStart
+	body  int // Where the branch's body starts in the output.
+}
+
+// Functions the script defines when it uses them, in the order they're written.
+var bashHelpers = []struct{ name, code string }{
+	{"conditions", condition.ShellFunctions},
+	{"capture", `# Prints the variables as assignments, so a subshell can pass them back.
+rd_capture() {
+  local name
+  for name in "$@"; do
+    printf 'export %s=%q\n' "$name" "${!name-}"
+  done
+}
+`},
+	{"replace", `# Replaces every occurrence of a string in the file.
+rd_replace() {
+  local content
+  content="$(cat "$1"; printf x)"
+  content="${content%x}"
+  printf '%s' "${content//"$2"/"$3"}" > "$1"
+}
+`},
+	{"confirm", `# Asks before continuing. Answering no, or running without a terminal, stops the script.
+rd_confirm() {
+  local answer
+  if [ "$RUNDOWN_ASSUME_YES" = true ]; then
+    echo "$1 (--yes)"
+    return
+  fi
+  if [ ! -t 0 ]; then
+    echo "$1" >&2
+    echo "Confirmation required, re-run with --yes to continue." >&2
+    exit 1
+  fi
+  if [ -n "$2" ]; then
+    read -r -p "$1 Type $2 to continue: " answer
+    [ "$answer" = "$2" ] && return
+  else
+    read -r -p "$1 [y/N] " answer
+    case "$answer" in [yY]|[yY][eE][sS]) return ;; esac
+  fi
+  echo "Aborted." >&2
+  exit 1
+}
+`},
+	{"require", `rd_require() {
+  type -P "$1" >/dev/null 2>&1 || rd_fail "$1 not found"
+}
+`},
+	{"values", `# Splits a list of values separated by commas or whitespace.
+rd_values() {
+  printf '%s\n' "$1" | tr ',' ' '
+}
+`},
+	{"list", `# Trims the list's items, dropping empty ones, and sets NAME_COUNT and NAME_0, NAME_1... for each item.
+rd_expand_list() {
+  local name="$1" separator="$2" rest="${!1}" item list="" count=0
+  while :; do
+    item="${rest%%"$separator"*}"
+    item="$(printf '%s' "$item" | sed -e 's/^[[:space:]]*//' -e 's/[[:space:]]*$//')"
+    if [ -n "$item" ]; then
+      export "${name}_${count}=$item"
+      list="${list:+$list$separator}$item"
+      count=$((count + 1))
+    fi
+    [ "$rest" != "${rest#*"$separator"}" ] || break
+    rest="${rest#*"$separator"}"
+  done
+  export "${name}_COUNT=$count"
+  printf -v "$name" '%s' "$list"
+  [ "$count" -gt 0 ]
+}
+`},
+	{"duration", `# Converts a duration such as 1h30m to whole seconds.
+rd_seconds() {
+  awk -v d="$1" 'BEGIN {
+    if (d !~ /^([0-9]*\.?[0-9]+(h|m|s|ms|us|ns))+$/) exit 1
+    while (match(d, /^[0-9]*\.?[0-9]+(h|ms|m|s|us|ns)/)) {
+      part = substr(d, 1, RLENGTH)
+      d = substr(d, RLENGTH + 1)
+      unit = part
+      sub(/^[0-9]*\.?[0-9]+/, "", unit)
+      total += (part + 0) * (unit == "h" ? 3600 : unit == "m" ? 60 : unit == "s" ? 1 : unit == "ms" ? 0.001 : unit == "us" ? 0.000001 : 0.000000001)
+    }
+    printf "%d\n", total
+  }'
+}
+`},
+	{"one-of", `rd_one_of() {
+  if ! printf '%s\n' "$3" | grep -Fxq -- "$2"; then
+    rd_fail "$1: \"$2\" must be one of: $(printf '%s' "$3" | tr '\n' ',' | sed 's/,/, /g')"
+  fi
+}
+`},
+}
+
+func (e *bashExporter) export(section *ast.SectionPointer, doc goldast.Node) (string, error) {
+	goldast.Walk(doc, func(n goldast.Node, entering bool) (goldast.WalkStatus, error) {
+		if start, ok := n.(*ast.ConditionalStart); ok && entering && start.Else != nil {
+			e.hasElse[start.Else] = true
+		}
+
+		return goldast.WalkContinue, nil
+	})
+
+	// The body is written first, so the helpers it uses are known.
+	body := &strings.Builder{}
+	e.out = body
+
+	if requirements := ast.GetRequirements(doc); len(requirements) > 0 {
+		e.use("require")
+
+		for _, requirement := range requirements {
+			e.line("rd_require %s", util.ShellQuote(requirement.Name))
+		}
+	}
+
+	if err := e.exportChildren(doc); err != nil {
+		return "", err
+	}
+
+	script := &strings.Builder{}
+	e.out = script
+	e.indent = 0
+
+	e.line("#!/usr/bin/env bash")
+	e.line("#")
+	e.line("# %s", section.DescriptionShort)
+	e.line("#")
+	e.line("# Exported from %s by rundown. Change the markdown and export it again, rather than editing this script.", filepath.Base(e.document.Filename))
+	e.line("set -euo pipefail")
+	e.line("shopt -s inherit_errexit 2>/dev/null || true")
+	e.line("")
+
+	e.writeUsage(section)
+
+	e.line("rd_fail() {")
+	e.line(`  echo "Error: $1" >&2`)
+	e.line("  exit 1")
+	e.line("}")
+	e.line("")
+
+	if err := e.writeOptions(section); err != nil {
+		return "", err
+	}
+
+	e.indent = 0
+
+	helpers := &strings.Builder{}
+	for _, helper := range bashHelpers {
+		if e.helpers[helper.name] {
+			helpers.WriteString(helper.code)
+			helpers.WriteString("\n")
+		}
+	}
+
+	options := script.String()
+	script.Reset()
+
+	script.WriteString(strings.Replace(options, "rd_fail() {", helpers.String()+"rd_fail() {", 1))
+
+	e.line("")
+	e.line(`RUNDOWN_WORKSPACE="$(mktemp -d)"`)
+	e.line("export RUNDOWN_WORKSPACE")
+	e.line(`trap 'rm -rf "$RUNDOWN_WORKSPACE"' EXIT`)
+	e.line(`mkdir -p "$RUNDOWN_WORKSPACE/files" "$RUNDOWN_WORKSPACE/scripts"`)
+	e.line("")
+	e.line(`cd "$RUNDOWN_DIR"`)
+
+	script.WriteString(body.String())
+
+	return script.String(), nil
+}
+
+func (e *bashExporter) use(helper string) {
+	e.helpers[helper] = true
+}
+
+// Writes an indented line. Lines after the first aren't indented, as they may belong to a heredoc.
+func (e *bashExporter) line(format string, args ...interface{}) {
+	line := fmt.Sprintf(format, args...)
+
+	if line != "" {
+		e.out.WriteString(strings.Repeat("  ", e.indent))
+	}
+
+	e.out.WriteString(line)
+	e.out.WriteString("\n")
+}
+
+// Writes text exactly as it is, such as a script's contents.
+func (e *bashExporter) raw(text string) {
+	e.out.WriteString(text)
+
+	if !strings.HasSuffix(text, "\n") {
+		e.out.WriteString("\n")
+	}
+}
+
+// Writes a heredoc saving the contents to the file, or printing them when there's no file, using a delimiter
+// the contents don't contain.
+func (e *bashExporter) heredoc(file string, contents string) {
+	delimiter := "RUNDOWN_EOF"
+	for regexp.MustCompile(`(?m)^` + delimiter + `$`).MatchString(contents) {
+		delimiter += "_"
+	}
+
+	if file != "" {
+		file = " > " + file
+	}
+
+	e.line("cat%s <<'%s'", file, delimiter)
+	e.raw(contents)
+	e.raw(delimiter)
+}
+
+func (e *bashExporter) writeUsage(section *ast.SectionPointer) {
+	flags := [][2]string{}
+
+	for _, opt := range section.Options {
+		flag := "--" + opt.OptionName
+		if opt.OptionType.InputType() != "bool" {
+			flag += " " + opt.OptionType.InputType()
+		}
+
+		description := opt.OptionDescription
+		if opt.OptionDefault.Valid && opt.OptionDefault.String != "" {
+			description += fmt.Sprintf(" (default %q)", opt.OptionDefault.String)
+		}
+
+		flags = append(flags, [2]string{flag, description})
+	}
+
+	if e.helpers["confirm"] {
+		flags = append(flags, [2]string{"--yes", "Answer yes to any confirmations"})
+	}
+
+	flags = append(flags, [2]string{"-h, --help", "Show this help"})
+
+	width := 0
+	for _, flag := range flags {
+		if len(flag[0]) > width {
+			width = len(flag[0])
+		}
+	}
+
+	usage := strings.Builder{}
+	fmt.Fprintf(&usage, "%s\n\nFlags:\n", section.DescriptionShort)
+
+	for _, flag := range flags {
+		fmt.Fprintf(&usage, "  %-*s   %s\n", width, flag[0], flag[1])
+	}
+
+	e.line("usage() {")
+	e.indent++
+	e.line(`echo "Usage: $(basename "$0") [flags]"`)
+	e.line("echo")
+	e.heredoc("", usage.String())
+	e.indent--
+	e.line("}")
+	e.line("")
+}
+
+// Writes the flag parsing, followed by each option's default, checks and relationships, in the same order rundown applies them.
+func (e *bashExporter) writeOptions(section *ast.SectionPointer) error {
+	options := section.Options
+
+	e.line(`INVOCATION_DIR="$PWD"`)
+	e.line(`RUNDOWN_DIR="$(cd "$(dirname "${BASH_SOURCE[0]:-$0}")" && pwd)"`)
+	e.line("export INVOCATION_DIR")
+	e.line("")
+
+	for _, name := range sortedKeys(e.document.Context.EnvDefaults) {
+		e.line(`if [ -z "${%s+x}" ]; then export %s=%s; fi`, name, name, util.ShellQuote(e.document.Context.EnvDefaults[name]))
+	}
+
+	for _, opt := range options {
+		e.line("%s=''", opt.OptionAs)
+	}
+
+	if e.helpers["confirm"] {
+		e.line("RUNDOWN_ASSUME_YES=false")
+	}
+
+	e.line("")
+	e.line(`while [ "$#" -gt 0 ]; do`)
+	e.indent++
+	e.line(`case "$1" in`)
+	e.indent++
+	e.line("-h|--help) usage; exit 0 ;;")
+
+	if e.helpers["confirm"] {
+		e.line("--yes) RUNDOWN_ASSUME_YES=true ;;")
+	}
+
+	for _, opt := range options {
+		if opt.OptionType.InputType() == "bool" {
+			e.line("--%s) %s=true ;;", opt.OptionName, opt.OptionAs)
+		} else {
+			e.line(`--%s) [ "$#" -gt 1 ] || rd_fail "flag needs an argument: $1"; %s="$2"; shift ;;`, opt.OptionName, opt.OptionAs)
+		}
+
+		e.line(`--%s=*) %s="${1#*=}" ;;`, opt.OptionName, opt.OptionAs)
+	}
+
+	e.line(`*) rd_fail "unknown flag: $1" ;;`)
+	e.indent--
+	e.line("esac")
+	e.line("shift")
+	e.indent--
+	e.line("done")
+
+	// Whether each option was passed is only tracked when something depends on it.
+	tracked := false
+	for _, opt := range options {
+		if (opt.OptionRequired && !opt.OptionDefault.Valid) || opt.RequiredIf != "" || len(opt.Requires) > 0 || len(opt.Conflicts) > 0 {
+			tracked = true
+		}
+	}
+
+	for _, opt := range options {
+		e.line("")
+
+		defaultValue := ""
+		switch {
+		case opt.OptionDefault.Valid && opt.OptionDefault.String != "":
+			defaultValue = util.ShellQuote(opt.OptionDefault.String)
+		case opt.OptionType.InputType() == "bool":
+			defaultValue = "false"
+		}
+
+		switch {
+		case tracked && defaultValue != "":
+			e.line(`if [ -n "$%s" ]; then rd_passed_%s=true; else rd_passed_%s=false; %s=%s; fi`, opt.OptionAs, opt.OptionAs, opt.OptionAs, opt.OptionAs, defaultValue)
+		case tracked:
+			e.line(`if [ -n "$%s" ]; then rd_passed_%s=true; else rd_passed_%s=false; fi`, opt.OptionAs, opt.OptionAs, opt.OptionAs)
+		case defaultValue != "":
+			e.line(`if [ -z "$%s" ]; then %s=%s; fi`, opt.OptionAs, opt.OptionAs, defaultValue)
+		}
+
+		if err := e.writeOptionChecks(opt); err != nil {
+			return err
+		}
+	}
+
+	if tracked {
+		e.line("")
+
+		if err := e.writeOptionRelationships(section); err != nil {
+			return err
+		}
+	}
+
+	if len(options) > 0 {
+		names := []string{}
+		for _, opt := range options {
+			names = append(names, opt.OptionAs)
+		}
+
+		e.line("")
+		e.line("export %s", strings.Join(names, " "))
+	}
+
+	return nil
+}
+
+// Returns a shell word for the option's error message. The format's %s is the option's value.
+func optionMessage(opt *ast.SectionOption, format string) string {
+	before, after, _ := strings.Cut(opt.OptionName+": "+format, "%s")
+	return util.ShellQuote(before) + `"$` + opt.OptionAs + `"` + util.ShellQuote(after)
+}
+
+// Writes the checks rundown makes on the option's value. Types without checks here, such as urls, are passed through as given.
+func (e *bashExporter) writeOptionChecks(opt *ast.SectionOption) error {
+	name := opt.OptionAs
+	lines := []string{}
+
+	switch t := opt.OptionType.(type) {
+	case *ast.TypeBoolean:
+		e.line(`case "$(printf '%%s' "$%s" | tr '[:upper:]' '[:lower:]')" in true) %s=true ;; *) %s=false ;; esac`, name, name, name)
+		return nil
+
+	case *ast.TypeEnum:
+		quoted := []string{}
+		for _, value := range t.ValidValues {
+			quoted = append(quoted, util.ShellQuote(value))
+		}
+
+		lines = append(lines, fmt.Sprintf(`case "$%s" in %s) ;; *) rd_fail %s ;; esac`, name, strings.Join(quoted, "|"), optionMessage(opt, `"%s" must be one of: `+strings.Join(t.ValidValues, ", "))))
+
+	case *ast.TypeKV:
+		keys := sortedKeys(t.Pairs)
+
+		cases := []string{}
+		for _, key := range keys {
+			cases = append(cases, fmt.Sprintf("%s) %s=%s ;;", util.ShellQuote(key), name, util.ShellQuote(t.Pairs[key])))
+		}
+
+		lines = append(lines, fmt.Sprintf(`case "$%s" in %s *) rd_fail %s ;; esac`, name, strings.Join(cases, " "), optionMessage(opt, `"%s" must be one of: `+strings.Join(keys, ", "))))
+
+	case *ast.TypeString:
+		if t.Pattern != nil {
+			lines = append(lines,
+				fmt.Sprintf("rd_pattern=%s", util.ShellQuote(condition.ShellRegexp(t.Pattern.String()))),
+				fmt.Sprintf(`[[ $%s =~ $rd_pattern ]] || rd_fail %s`, name, optionMessage(opt, `"%s" must match `+t.Pattern.String())))
+		}
+
+	case *ast.TypeInt:
+		lines = append(lines,
+			"rd_pattern='^[-+]?[0-9]+$'",
+			fmt.Sprintf(`[[ $%s =~ $rd_pattern ]] || rd_fail %s`, name, optionMessage(opt, `"%s" must be a whole number`)))
+
+		if t.Min.Valid {
+			lines = append(lines, fmt.Sprintf(`[ "$%s" -ge %d ] || rd_fail %s`, name, t.Min.Int64, optionMessage(opt, `"%s" must be `+t.Describe())))
+		}
+
+		if t.Max.Valid {
+			lines = append(lines, fmt.Sprintf(`[ "$%s" -le %d ] || rd_fail %s`, name, t.Max.Int64, optionMessage(opt, `"%s" must be `+t.Describe())))
+		}
+
+	case *ast.TypeFloat:
+		lines = append(lines,
+			"rd_pattern='^[-+]?([0-9]+\\.?[0-9]*|\\.[0-9]+)([eE][-+]?[0-9]+)?$'",
+			fmt.Sprintf(`[[ $%s =~ $rd_pattern ]] || rd_fail %s`, name, optionMessage(opt, `"%s" must be a number`)))
+
+		bounds := []string{}
+		if t.Min.Valid {
+			bounds = append(bounds, fmt.Sprintf("v >= %v", t.Min.Float64))
+		}
+
+		if t.Max.Valid {
+			bounds = append(bounds, fmt.Sprintf("v <= %v", t.Max.Float64))
+		}
+
+		if len(bounds) > 0 {
+			lines = append(lines, fmt.Sprintf(`awk -v v="$%s" 'BEGIN { exit !(%s) }' || rd_fail %s`, name, strings.Join(bounds, " && "), optionMessage(opt, `"%s" must be `+t.Describe())))
+		}
+
+	case *ast.TypeList:
+		e.use("list")
+		lines = append(lines, fmt.Sprintf(`rd_expand_list %s %s || rd_fail %s`, name, util.ShellQuote(t.Separator), optionMessage(opt, `"%s" must have at least one item`)))
+
+	case *ast.TypeDuration:
+		e.use("duration")
+		lines = append(lines,
+			fmt.Sprintf(`%s_SECONDS="$(rd_seconds "$%s")" || rd_fail %s`, name, name, optionMessage(opt, `"%s" must be a duration, such as 30s or 1h30m`)),
+			fmt.Sprintf("export %s_SECONDS", name))
+
+	case *ast.TypeEnumFrom:
+		e.use("one-of")
+		lines = append(lines, fmt.Sprintf(`rd_one_of %s "$%s" "$(cd "$RUNDOWN_DIR" && %s)"`, util.ShellQuote(opt.OptionName), name, t.Command))
+
+	case *ast.TypeFilename, *ast.TypePath:
+		lines = append(lines, fmt.Sprintf(`case "$%s" in /*) ;; *) %s="$INVOCATION_DIR/$%s" ;; esac`, name, name, name))
+	}
+
+	if len(lines) == 0 {
+		return nil
+	}
+
+	e.line(`if [ -n "$%s" ]; then`, name)
+	e.indent++
+	for _, line := range lines {
+		e.line("%s", line)
+	}
+	e.indent--
+	e.line("fi")
+
+	return nil
+}
+
+func (e *bashExporter) writeOptionRelationships(section *ast.SectionPointer) error {
+	given := func(opt *ast.SectionOption) string {
+		if opt.OptionType.InputType() == "bool" {
+			return fmt.Sprintf(`[ "$rd_passed_%s" = true ] && [ "$%s" = true ]`, opt.OptionAs, opt.OptionAs)
+		}
+
+		return fmt.Sprintf(`[ "$rd_passed_%s" = true ]`, opt.OptionAs)
+	}
+
+	for _, opt := range section.Options {
+		if opt.OptionRequired && !opt.OptionDefault.Valid {
+			e.line(`[ "$rd_passed_%s" = true ] || rd_fail %s`, opt.OptionAs, util.ShellQuote(opt.OptionName+": a value is required"))
+		}
+
+		if opt.RequiredIf != "" {
+			test, err := e.condition(opt.RequiredIf)
+			if err != nil {
+				return fmt.Errorf("%s: %w", opt.OptionName, err)
+			}
+
+			e.line(`if [ "$rd_passed_%s" = false ] && { %s; }; then rd_fail %s; fi`, opt.OptionAs, test, util.ShellQuote(opt.OptionName+": a value is required when "+opt.RequiredIf))
+		}
+
+		for _, name := range opt.Requires {
+			if other := section.GetOption(name); other != nil {
+				e.line("if { %s; } && ! { %s; }; then rd_fail %s; fi", given(opt), given(other), util.ShellQuote(opt.OptionName+": requires "+name))
+			}
+		}
+
+		for _, name := range opt.Conflicts {
+			if other := section.GetOption(name); other != nil {
+				e.line("if { %s; } && { %s; }; then rd_fail %s; fi", given(opt), given(other), util.ShellQuote(opt.OptionName+": can't be used with "+name))
+			}
+		}
+	}
+
+	return nil
+}
+
+// Returns a command which succeeds when the if= condition is true. Expressions are converted to bash, and scripts run as they are.
+func (e *bashExporter) condition(script string) (string, error) {
+	expression, err := condition.Parse(script)
+
+	switch {
+	case err == nil:
+		e.use("conditions")
+		return expression.Shell(), nil
+	case errors.Is(err, condition.ErrNotExpression):
+		return "( set +u\n" + strings.TrimSpace(script) + "\n) >/dev/null 2>&1", nil
+	}
+
+	return "", fmt.Errorf("invalid condition %q: %w", script, err)
+}
+
+func (e *bashExporter) exportChildren(node goldast.Node) error {
+	open := len(e.open)
+
+	for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+		if err := e.exportNode(child); err != nil {
+			return err
+		}
+	}
+
+	// A conditional heading ending the section has no end in the pruned document, so its branch is closed here.
+	for len(e.open) > open {
+		e.closeBranch(e.open[len(e.open)-1].start)
+	}
+
+	return nil
+}
+
+func (e *bashExporter) exportNode(node goldast.Node) error {
+	// Branches open and close their own if statements.
+	if conditional, ok := node.(ast.Conditional); ok && conditional.HasIfScript() && node.Kind() != ast.KindConditionalStart {
+		test, err := e.condition(conditional.GetIfScript())
+		if err != nil {
+			return err
+		}
+
+		e.line("if %s; then", test)
+		e.indent++
+		defer func() {
+			e.indent--
+			e.line("fi")
+		}()
+	}
+
+	source := e.document.Source
+
+	switch node := node.(type) {
+	case *ast.ExecutionBlock:
+		return e.exportExecution(node)
+
+	case *ast.SaveCodeBlock:
+		return e.exportSave(node)
+
+	case *ast.InvokeBlock:
+		return e.exportInvoke(node)
+
+	case *ast.ConditionalStart:
+		return e.exportBranch(node)
+
+	case *ast.ConditionalEnd:
+		e.closeBranch(node.Start)
+
+	case *ast.Foreach:
+		return e.exportForeach(node)
+
+	case *ast.StopOk:
+		e.exit()
+
+	case *ast.StopFail:
+		if message := strings.TrimSpace(string(node.Text(source))); message != "" {
+			e.line("echo %s >&2", util.ShellQuote(message))
+		}
+
+		e.line("exit 1")
+
+	case *ast.Confirm:
+		e.use("confirm")
+		e.line("rd_confirm %s %s", util.SubEnvShellWord(node.Prompt), util.SubEnvShellWord(node.Expect))
+
+	case *goldast.Heading:
+		e.line("")
+		e.line("# %s", strings.TrimSpace(string(node.Text(source))))
+
+	case *ast.OnFailure:
+		return errors.New("on-failure handlers can't be exported, as the script stops at the first failure")
+
+	case *ast.Cleanup:
+		return errors.New("cleanup blocks can't be exported")
+
+	case *ast.SkipBlock:
+		return errors.New("skip blocks can't be exported")
+
+	case *ast.IgnoreBlock, *ast.DescriptionBlock, *ast.SectionOption, *ast.Requires, *goldast.FencedCodeBlock:
+		// Nothing to run.
+
+	default:
+		return e.exportChildren(node)
+	}
+
+	return nil
+}
+
+// Stops the script successfully. Inside an invoked section's subshell, the parent is told to stop too.
+func (e *bashExporter) exit() {
+	if e.subshells > 0 {
+		e.line("exit %d", stoppedStatus)
+	} else {
+		e.line("exit 0")
+	}
+}
+
+func (e *bashExporter) exportExecution(block *ast.ExecutionBlock) error {
+	if !block.Execute {
+		return nil
+	}
+
+	if block.SkipOnSuccess || block.SkipOnFailure {
+		return errors.New("skip-on-success and skip-on-failure can't be exported")
+	}
+
+	contents, err := ioutil.ReadAll(text.NewNodeReaderFromSource(block.CodeBlock, e.document.Source))
+	if err != nil {
+		return err
+	}
+
+	interpreter := e.interpreters.Resolve(block.With, block.Language)
+	inline := interpreter.Command == "bash" || interpreter.Command == "sh"
+
+	if len(block.CaptureEnvironment) > 0 && !inline {
+		return fmt.Errorf("capture-env can only be exported from bash and sh blocks, not %s", block.With)
+	}
+
+	e.line("")
+
+	if block.SpinnerMode != ast.SpinnerModeHidden {
+		e.line("echo %s", util.SubEnvShellWord("==> "+block.SpinnerName))
+	}
+
+	e.files++
+	envFile := fmt.Sprintf(`"$RUNDOWN_WORKSPACE/env-%d"`, e.files)
+	stderrFile := fmt.Sprintf(`"$RUNDOWN_WORKSPACE/stderr-%d"`, e.files)
+
+	// Other interpreters get their script written out first, then run it.
+	command := ""
+	if !inline {
+		scriptFile := fmt.Sprintf(`"$RUNDOWN_WORKSPACE/scripts/script-%d%s"`, e.files, interpreter.Extension)
+
+		script := string(interpreter.Wrap(contents))
+		if interpreter.Preamble != "" && !strings.HasPrefix(strings.TrimSpace(string(contents)), interpreter.Preamble) {
+			script = interpreter.Preamble + "\n" + script
+		}
+
+		e.heredoc(scriptFile, script)
+
+		switch {
+		case interpreter.Command == "":
+			e.line("chmod +x %s", scriptFile)
+			command = scriptFile
+		case strings.Contains(interpreter.Command, "$SCRIPT_FILE"):
+			command = strings.ReplaceAll(interpreter.Command, "$SCRIPT_FILE", scriptFile)
+		default:
+			command = interpreter.Command + " " + scriptFile
+		}
+	}
+
+	open, close := "(", ")"
+	if block.CaptureStdoutInto != "" {
+		open, close = block.CaptureStdoutInto+`="$(`, `)"`
+	}
+
+	if block.CaptureStderrInto != "" {
+		open, close = open+" (", ") 2> "+stderrFile+close
+	}
+
+	e.line("%s", open)
+	e.indent++
+
+	if block.Cwd != "" {
+		e.line("cd %s", util.SubEnvShellWord(block.Cwd))
+	}
+
+	if inline {
+		e.raw(string(contents))
+	} else {
+		e.line("%s", command)
+	}
+
+	if len(block.CaptureEnvironment) > 0 {
+		e.use("capture")
+		e.line("rd_capture %s > %s", strings.Join(block.CaptureEnvironment, " "), envFile)
+	}
+
+	e.indent--
+	e.line("%s", close)
+
+	if block.CaptureStdoutInto != "" {
+		e.line("export %s", block.CaptureStdoutInto)
+	}
+
+	if block.CaptureStderrInto != "" {
+		e.line(`%s="$(cat %s)"`, block.CaptureStderrInto, stderrFile)
+		e.line("export %s", block.CaptureStderrInto)
+	}
+
+	if len(block.CaptureEnvironment) > 0 {
+		e.line("if [ -f %s ]; then . %s; fi", envFile, envFile)
+	}
+
+	if block.ReplaceProcess {
+		e.exit()
+	}
+
+	return nil
+}
+
+func (e *bashExporter) exportSave(save *ast.SaveCodeBlock) error {
+	contents, err := ioutil.ReadAll(text.NewNodeReaderFromSource(save.CodeBlock, e.document.Source))
+	if err != nil {
+		return err
+	}
+
+	name := renderer.TempFileEnvName(save.SaveToVariable)
+
+	e.line("")
+	e.line(`%s="$RUNDOWN_WORKSPACE/files/"%s`, name, util.ShellQuote(filepath.Base(save.SaveToVariable)))
+	e.heredoc(`"$`+name+`"`, string(contents))
+
+	for _, key := range sortedKeys(save.Replacements) {
+		value := util.ShellQuote(save.Replacements[key])
+		if strings.HasPrefix(save.Replacements[key], "$") {
+			value = `"${` + save.Replacements[key][1:] + `-}"`
+		}
+
+		e.use("replace")
+		e.line(`rd_replace "$%s" %s %s`, name, util.ShellQuote(key), value)
+	}
+
+	e.line("export %s", name)
+
+	return nil
+}
+
+var nonVariable = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// Invoked sections run in a subshell, so their options and captured variables don't leak into the caller, as with rundown.
+func (e *bashExporter) exportInvoke(invoke *ast.InvokeBlock) error {
+	if invoke.Target == nil {
+		return fmt.Errorf("cannot find section \"%s\"", invoke.Invoke)
+	}
+
+	options, err := invokeOptions(invoke)
+	if err != nil {
+		return fmt.Errorf("invoking %s: %w", invoke.Invoke, err)
+	}
+
+	// Dependencies only run once.
+	ran := "rd_ran_" + nonVariable.ReplaceAllString(invoke.Invoke, "_")
+	if invoke.AsDependency {
+		e.line("")
+		e.line(`if [ -z "${%s-}" ]; then`, ran)
+		e.indent++
+	}
+
+	stops := ast.FindNode(invoke, func(n goldast.Node) bool {
+		_, ok := n.(*ast.StopOk)
+		return ok
+	}) != nil
+
+	// Stopping inside the subshell has to stop the script too, so errexit is handled by the subshell itself.
+	if stops {
+		e.line("set +e")
+	}
+
+	e.line("(")
+	e.indent++
+	e.subshells++
+
+	if stops {
+		e.line("set -e")
+	}
+
+	for _, name := range sortedKeys(options) {
+		e.line("export %s=%s", name, options[name])
+	}
+
+	if err := e.exportChildren(invoke); err != nil {
+		return err
+	}
+
+	e.subshells--
+	e.indent--
+	e.line(")")
+
+	if stops {
+		e.line("rd_status=$?")
+		e.line("set -e")
+		e.line(`if [ "$rd_status" -eq %d ]; then`, stoppedStatus)
+		e.indent++
+		e.exit()
+		e.indent--
+		e.line(`elif [ "$rd_status" -ne 0 ]; then`)
+		e.line(`  exit "$rd_status"`)
+		e.line("fi")
+	}
+
+	if invoke.AsDependency {
+		e.line("%s=true", ran)
+		e.indent--
+		e.line("fi")
+	}
+
+	return nil
+}
+
+// Returns the shell words for the invoked section's options. Options given as literals are checked now, while those
+// using variables, or which depend on the machine, such as files, are passed through as they are.
+func invokeOptions(invoke *ast.InvokeBlock) (map[string]string, error) {
+	result := map[string]string{}
+	target := invoke.Target
+
+	static := true
+	for _, value := range invoke.Args {
+		if strings.Contains(value, "$") {
+			static = false
+		}
+	}
+
+	for _, opt := range target.Options {
+		switch opt.OptionType.(type) {
+		case ast.OptionTypeRuntime, *ast.TypeEnumFrom:
+			static = false
+		}
+	}
+
+	if static {
+		parsed, err := target.ParseOptionsWithResolutionByName(invoke.Args, map[string]string{})
+		if err != nil {
+			return nil, err
+		}
+
+		for name, value := range parsed {
+			result[name] = util.ShellQuote(value)
+		}
+
+		return result, nil
+	}
+
+	for _, opt := range target.Options {
+		value, given := invoke.Args[opt.OptionName]
+
+		switch {
+		case given:
+			result[opt.OptionAs] = util.SubEnvShellWord(value)
+		case opt.OptionDefault.Valid:
+			result[opt.OptionAs] = util.ShellQuote(opt.OptionDefault.String)
+		case opt.OptionType.InputType() == "bool":
+			result[opt.OptionAs] = "false"
+		default:
+			result[opt.OptionAs] = "''"
+		}
+	}
+
+	return result, nil
+}
+
+// Starts a branch. Chains of else and else-if branches share a variable recording whether one has run.
+func (e *bashExporter) exportBranch(start *ast.ConditionalStart) error {
+	tests := []string{}
+	variable := ""
+
+	switch {
+	case start.Else != nil:
+		variable = e.branches[start.Else]
+		tests = append(tests, fmt.Sprintf(`[ "$%s" = false ]`, variable))
+	case e.hasElse[start]:
+		variable = fmt.Sprintf("rd_branch_%d", len(e.branches)+1)
+		e.line("%s=false", variable)
+	}
+
+	if variable != "" {
+		e.branches[start] = variable
+	}
+
+	if start.HasIfScript() {
+		test, err := e.condition(start.GetIfScript())
+		if err != nil {
+			return err
+		}
+
+		tests = append(tests, test)
+	}
+
+	switch len(tests) {
+	case 0:
+		e.line("if true; then")
+	case 1:
+		e.line("if %s; then", tests[0])
+	default:
+		e.line("if { %s; }; then", strings.Join(tests, "; } && { "))
+	}
+
+	e.indent++
+	e.open = append(e.open, openBranch{start: start, body: e.out.Len()})
+
+	if variable != "" {
+		e.line("%s=true", variable)
+	}
+
+	return nil
+}
+
+// Ends a branch's if statement, along with any branches started inside it. Ends whose branch isn't open are ignored.
+func (e *bashExporter) closeBranch(start *ast.ConditionalStart) {
+	for i := len(e.open) - 1; i >= 0; i-- {
+		if e.open[i].start != start {
+			continue
+		}
+
+		for j := len(e.open) - 1; j >= i; j-- {
+			// Bash doesn't allow an empty if statement, such as a branch with only text.
+			if !hasCommand(e.out.String()[e.open[j].body:]) {
+				e.line(":")
+			}
+
+			e.indent--
+			e.line("fi")
+		}
+
+		e.open = e.open[:i]
+		return
+	}
+}
+
+// Whether the script has a line other than blanks and comments.
+func hasCommand(script string) bool {
+	for _, line := range strings.Split(script, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e *bashExporter) exportForeach(foreach *ast.Foreach) error {
+	if foreach.Matrix {
+		return errors.New("matrix foreach blocks can't be exported")
+	}
+
+	e.use("values")
+	e.line("")
+
+	if foreach.Parallel != 0 {
+		e.line("# Iterations run one after the other.")
+	}
+
+	for _, axis := range foreach.Axes {
+		e.line("for %s in $(rd_values %s); do", axis.Name, util.SubEnvShellWord(axis.Values))
+		e.indent++
+		e.line("export %s", axis.Name)
+	}
+
+	if err := e.exportChildren(foreach); err != nil {
+		return err
+	}
+
+	for range foreach.Axes {
+		e.indent--
+		e.line("done")
+	}
+
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := maps.Keys(m)
+	sort.Strings(keys)
+
+	return keys
+}
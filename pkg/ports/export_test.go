@@ -0,0 +1,236 @@
+package ports
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	rundown "github.com/elseano/rundown/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const exportSource = `
+# Build <r section="build"/>
+
+<r opt="target" type="string" default="web"/>
+
+<r spinner="Building $OPT_TARGET"/>
+
+` + "``` bash" + `
+echo "built $OPT_TARGET"
+` + "```" + `
+
+<r stop-ok if="$OPT_TARGET == 'none'"/>
+
+# Deploy <r section="deploy"/>
+
+<r opt="env" type="enum:staging|production" required/>
+<r opt="count" type="int" default="2"/>
+<r opt="dry-run" type="bool"/>
+
+<r dep="build" target="api"/>
+
+<r spinner="Preparing" capture-env="PREPARED"/>
+
+` + "``` bash" + `
+PREPARED="ready for $OPT_ENV"
+` + "```" + `
+
+<r save-as="config.yml" replace="REGION_HERE:$OPT_ENV"/>
+
+` + "``` yaml" + `
+region: REGION_HERE
+` + "```" + `
+
+<r if="$OPT_DRY_RUN == true">
+
+<r stdout-into="MODE"/>
+
+` + "``` bash" + `
+echo "dry run"
+` + "```" + `
+
+</r>
+<r else>
+
+<r stdout-into="MODE"/>
+
+` + "``` bash" + `
+echo "$PREPARED, $(cat "$CONFIG_FILE")"
+` + "```" + `
+
+</r>
+
+<r foreach="N" in="1,2">
+
+<r spinner="Done $MODE $N"/>
+
+` + "``` bash" + `
+true
+` + "```" + `
+
+</r>
+
+# Stop <r section="stop"/>
+
+<r invoke="build" target="none"/>
+
+<r spinner="Unreachable"/>
+
+` + "``` bash" + `
+echo unreachable
+` + "```" + `
+
+# Release <r section="release"/>
+
+<r opt="env" type="string" default="dev"/>
+
+<r spinner="Releasing"/>
+
+` + "``` bash" + `
+echo "releasing $OPT_ENV"
+` + "```" + `
+
+## Prod only <r if="$OPT_ENV == 'prod'"/>
+
+<r spinner="Tagging"/>
+
+` + "``` bash" + `
+echo "tagging prod"
+` + "```" + `
+
+# Fails <r section="fails"/>
+
+<r on-failure>Oh no</r>
+`
+
+func exportScript(t *testing.T, name string) string {
+	loaded, err := rundown.LoadString(exportSource, "deploy.md")
+	require.NoError(t, err)
+
+	for _, section := range loaded.GetSections() {
+		if section.Pointer.SectionName == name {
+			out := strings.Builder{}
+			require.NoError(t, ExportSection(&out, section, "bash"))
+
+			return out.String()
+		}
+	}
+
+	t.Fatalf("no section %s", name)
+	return ""
+}
+
+func runScript(t *testing.T, script string, args ...string) (string, error) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "script.sh")
+	require.NoError(t, os.WriteFile(file, []byte(script), 0755))
+
+	cmd := exec.Command("bash", append([]string{file}, args...)...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+
+	return string(out), err
+}
+
+func TestExportSection(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash isn't installed")
+	}
+
+	t.Run("runs", func(t *testing.T) {
+		out, err := runScript(t, exportScript(t, "deploy"), "--env", "staging")
+		require.NoError(t, err, out)
+
+		assert.Contains(t, out, "==> Building api\nbuilt api\n")
+		assert.Contains(t, out, "==> Preparing\n")
+		assert.Contains(t, out, "==> Done ready for staging, region: staging 1\n")
+		assert.Contains(t, out, "==> Done ready for staging, region: staging 2\n")
+	})
+
+	t.Run("branches", func(t *testing.T) {
+		out, err := runScript(t, exportScript(t, "deploy"), "--env=production", "--dry-run")
+		require.NoError(t, err, out)
+
+		assert.Contains(t, out, "==> Done dry run 1\n")
+	})
+
+	t.Run("checks options", func(t *testing.T) {
+		script := exportScript(t, "deploy")
+
+		out, err := runScript(t, script)
+		assert.Error(t, err)
+		assert.Equal(t, "Error: env: a value is required\n", out)
+
+		out, err = runScript(t, script, "--env", "test")
+		assert.Error(t, err)
+		assert.Equal(t, "Error: env: \"test\" must be one of: staging, production\n", out)
+
+		out, err = runScript(t, script, "--env", "staging", "--count", "many")
+		assert.Error(t, err)
+		assert.Equal(t, "Error: count: \"many\" must be a whole number\n", out)
+
+		out, err = runScript(t, script, "--colour")
+		assert.Error(t, err)
+		assert.Equal(t, "Error: unknown flag: --colour\n", out)
+	})
+
+	t.Run("usage", func(t *testing.T) {
+		out, err := runScript(t, exportScript(t, "deploy"), "--help")
+		require.NoError(t, err, out)
+
+		assert.Contains(t, out, "Usage: script.sh [flags]")
+		assert.Contains(t, out, "--env string")
+	})
+
+	t.Run("stops from invoked sections", func(t *testing.T) {
+		out, err := runScript(t, exportScript(t, "stop"))
+		require.NoError(t, err, out)
+
+		assert.Contains(t, out, "built none")
+		assert.NotContains(t, out, "unreachable")
+	})
+
+	t.Run("closes a trailing conditional heading", func(t *testing.T) {
+		script := exportScript(t, "release")
+
+		dir := t.TempDir()
+		file := filepath.Join(dir, "script.sh")
+		require.NoError(t, os.WriteFile(file, []byte(script), 0755))
+
+		out, err := exec.Command("bash", "-n", file).CombinedOutput()
+		require.NoError(t, err, string(out))
+
+		assert.Contains(t, script, "\nexport OPT_ENV\n")
+		assert.Contains(t, script, "\ncd \"$RUNDOWN_DIR\"\n")
+
+		out2, err := runScript(t, script, "--env", "prod")
+		require.NoError(t, err, out2)
+		assert.Contains(t, out2, "tagging prod")
+
+		out2, err = runScript(t, script)
+		require.NoError(t, err, out2)
+		assert.Contains(t, out2, "releasing dev")
+		assert.NotContains(t, out2, "tagging prod")
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		loaded, err := rundown.LoadString(exportSource, "deploy.md")
+		require.NoError(t, err)
+
+		for _, section := range loaded.GetSections() {
+			if section.Pointer.SectionName == "fails" {
+				err := ExportSection(&strings.Builder{}, section, "bash")
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), "on-failure handlers can't be exported")
+
+				err = ExportSection(&strings.Builder{}, section, "fish")
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), "unknown export format \"fish\"")
+			}
+		}
+	})
+}
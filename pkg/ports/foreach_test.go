@@ -0,0 +1,124 @@
+package ports
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	rundown "github.com/elseano/rundown/pkg"
+	"github.com/elseano/rundown/pkg/renderer/term"
+	"github.com/logrusorgru/aurora"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const foreachSource = `
+# Setup <r section="setup"/>
+
+<r spinner="Setting up"/>
+
+` + "``` bash" + `
+echo setup >> runs.log
+` + "```" + `
+
+# Each <r section="each"/>
+
+<r foreach="ITEM" in="one two three"{{attrs}}>
+
+<r dep="setup"/>
+
+<r spinner="Running $ITEM"/>
+
+` + "``` bash" + `
+[ "$ITEM" != "{{fail}}" ] || exit 1
+echo "$ITEM" >> runs.log
+` + "```" + `
+
+</r>
+
+<r dep="setup"/>
+
+<r spinner="Finishing"/>
+
+` + "``` bash" + `
+echo done >> runs.log
+` + "```" + `
+`
+
+func runForeach(t *testing.T, attrs string, fail string) (string, []string, error) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "tool.md")
+
+	source := strings.ReplaceAll(strings.ReplaceAll(foreachSource, "{{attrs}}", attrs), "{{fail}}", fail)
+	require.NoError(t, os.WriteFile(filename, []byte(source), 0644))
+
+	loaded, err := rundown.Load(filename)
+	require.NoError(t, err)
+
+	section := findSection(loaded, "each")
+	require.NotNil(t, section)
+
+	out := &bytes.Buffer{}
+	_, err = runSection(out, section, map[string]string{}, nil, false)
+
+	log, _ := os.ReadFile(filepath.Join(dir, "runs.log"))
+	return out.String(), strings.Split(strings.TrimSpace(string(log)), "\n"), err
+}
+
+func TestForeach(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash isn't installed")
+	}
+
+	term.Aurora = aurora.NewAurora(false)
+	term.ColorsEnabled = false
+
+	t.Run("in sequence", func(t *testing.T) {
+		out, log, err := runForeach(t, "", "")
+		require.NoError(t, err, out)
+
+		assert.Equal(t, []string{"setup", "one", "two", "three", "done"}, log)
+		assert.Contains(t, out, "▸ ITEM=one")
+		assert.Contains(t, out, "3 of 3 succeeded\n  ✔ ITEM=one\n  ✔ ITEM=two\n  ✔ ITEM=three\n")
+	})
+
+	t.Run("in parallel", func(t *testing.T) {
+		out, log, err := runForeach(t, " parallel", "")
+		require.NoError(t, err, out)
+
+		// The dependency runs once, before any of the iterations, and isn't run again after them.
+		require.Len(t, log, 5)
+		assert.Equal(t, "setup", log[0])
+		assert.ElementsMatch(t, []string{"one", "two", "three"}, log[1:4])
+		assert.Equal(t, "done", log[4])
+
+		assert.Contains(t, out, "3 of 3 succeeded")
+	})
+
+	t.Run("keeps going after a failure", func(t *testing.T) {
+		out, log, err := runForeach(t, "", "two")
+
+		assert.Error(t, err)
+		assert.Equal(t, []string{"setup", "one", "three"}, log)
+		assert.Contains(t, out, "2 of 3 succeeded\n  ✔ ITEM=one\n  ✖ ITEM=two\n  ✔ ITEM=three\n")
+	})
+
+	t.Run("fail fast", func(t *testing.T) {
+		out, log, err := runForeach(t, " fail-fast", "two")
+
+		assert.Error(t, err)
+		assert.Equal(t, []string{"setup", "one"}, log)
+		assert.Contains(t, out, "1 of 3 succeeded\n  ✔ ITEM=one\n  ✖ ITEM=two\n  ⋯ ITEM=three\n")
+	})
+
+	t.Run("fail fast in parallel", func(t *testing.T) {
+		out, log, err := runForeach(t, ` parallel="1" fail-fast`, "one")
+
+		assert.Error(t, err)
+		assert.Equal(t, []string{"setup"}, log)
+		assert.Contains(t, out, "0 of 3 succeeded\n  ✖ ITEM=one\n  ⋯ ITEM=two\n  ⋯ ITEM=three\n")
+	})
+}
@@ -120,25 +120,6 @@ func renderDoc(w http.ResponseWriter, r *http.Request, gm goldmark.Markdown, doc
 	}
 }
 
-func strPrt(str string) *string {
-	if str == "" {
-		return nil
-	} else {
-		return &str
-	}
-}
-
-func boolPtr(str string) *bool {
-	f := false
-	t := true
-
-	if str == "true" {
-		return &t
-	} else {
-		return &f
-	}
-}
-
 func ServeRundown(filename string, debug bool, port string) error {
 	if debug {
 		devNull, _ := os.Create("rundown.log")
@@ -175,31 +156,20 @@ func ServeRundown(filename string, debug bool, port string) error {
 
 				r.ParseForm()
 
-				optionEnv := map[string]optVal{}
-				for _, o := range section.Options {
-					opt := o
-					switch opt.OptionType.(type) {
-					case *ast.TypeString:
-						optionEnv[opt.OptionAs] = optVal{Str: strPrt(r.FormValue(opt.OptionName)), Option: opt}
-					case *ast.TypeBoolean:
-						optionEnv[opt.OptionAs] = optVal{Bool: boolPtr(r.FormValue(opt.OptionName)), Option: opt}
-					case *ast.TypeEnum:
-						optionEnv[opt.OptionAs] = optVal{Str: strPrt(r.FormValue(opt.OptionName)), Option: opt}
-					case *ast.TypeFilename:
-						optionEnv[opt.OptionAs] = optVal{Str: strPrt(r.FormValue(opt.OptionName)), Option: opt}
-					}
+				// Unchecked boxes and empty fields aren't given, so they take their defaults.
+				options := map[string]string{}
+				for _, opt := range section.Options {
+					options[opt.OptionAs] = r.FormValue(opt.OptionName)
 				}
 
-				for k, v := range optionEnv {
-					if err := v.Option.OptionType.Validate(v.String()); err != nil {
-						io.WriteString(w, fmt.Sprintf("%s: %s", v.Option.OptionName, err.Error()))
-					}
-
-					context.ImportEnv(map[string]string{
-						k: fmt.Sprintf("%v", v.String()),
-					})
+				parsed, err := section.ParseOptions(options, context.Env)
+				if err != nil {
+					io.WriteString(w, err.Error())
+					return
 				}
 
+				context.ImportEnv(parsed)
+
 				renderDoc(w, r, gm, doc, source, sections)
 			}
 		})
@@ -0,0 +1,85 @@
+package ports
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	rundown "github.com/elseano/rundown/pkg"
+	"github.com/elseano/rundown/pkg/renderer/term"
+	"github.com/elseano/rundown/pkg/renderer/term/spinner"
+	"github.com/logrusorgru/aurora"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const progressSource = `
+# Build <r section="build"/>
+
+<r spinner="Building" progress-from="(\d+)%"/>
+
+` + "``` bash" + `
+echo 50%
+echo 100%
+` + "```" + `
+
+# All <r section="all"/>
+
+<r invoke="build"/>
+`
+
+// Records the progress reported to it.
+type progressRecorder struct {
+	*spinner.NullSpinner
+	lock     *sync.Mutex
+	reported *[]string
+}
+
+func (s progressRecorder) Progress(current int, total int, label string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	*s.reported = append(*s.reported, fmt.Sprintf("%d/%d", current, total))
+}
+
+func TestProgressThroughInvokedSection(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash isn't installed")
+	}
+
+	term.Aurora = aurora.NewAurora(false)
+	term.ColorsEnabled = false
+
+	lock := &sync.Mutex{}
+	reported := []string{}
+
+	term.NewSpinnerFunc = func(w io.Writer) term.Spinner {
+		return progressRecorder{NullSpinner: spinner.NewNullSpinner(), lock: lock, reported: &reported}
+	}
+	defer func() { term.NewSpinnerFunc = nil }()
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "tool.md")
+	require.NoError(t, os.WriteFile(filename, []byte(progressSource), 0644))
+
+	loaded, err := rundown.Load(filename)
+	require.NoError(t, err)
+
+	section := findSection(loaded, "all")
+	require.NotNil(t, section)
+
+	out := &bytes.Buffer{}
+	_, err = runSection(out, section, map[string]string{}, nil, false)
+	require.NoError(t, err, out.String())
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	// The invoked block is a copy, which keeps its progress-from.
+	assert.Equal(t, []string{"50/100", "100/100"}, reported)
+}
@@ -0,0 +1,116 @@
+package ports
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	rundown "github.com/elseano/rundown/pkg"
+	"github.com/elseano/rundown/pkg/ast"
+	"github.com/elseano/rundown/pkg/errs"
+	rdutil "github.com/elseano/rundown/pkg/util"
+	goldast "github.com/yuin/goldmark/ast"
+	"golang.org/x/exp/maps"
+)
+
+// Runs the given section, using options keyed by their environment name (i.e. OPT_NAME).
+func RunSection(section *rundown.Section, options map[string]string, dumpAst bool) error {
+	_, err := runSection(os.Stdout, section, options, nil, dumpAst)
+	return err
+}
+
+// Runs the section, writing to out. The env is imported before the options, such as variables captured by
+// sections run before it. Returns the variables the section captured.
+func runSection(out io.Writer, section *rundown.Section, options map[string]string, env map[string]string, dumpAst bool) (map[string]string, error) {
+	sectionPointer := section.Pointer
+	doc := section.Document.Document
+
+	executionContext := section.Document.Context
+	executionContext.ImportRawEnv(os.Environ())
+	executionContext.ImportEnv(env)
+	executionContext.ApplyEnvDefaults()
+	executionContext.RundownFile = section.Document.Filename
+
+	parsed, err := sectionPointer.ParseOptions(options, executionContext.Env)
+
+	if err != nil {
+		return nil, err
+	}
+
+	executionContext.ImportEnv(parsed)
+
+	if err := ast.FillInvokeBlocks(doc, 10); err != nil {
+		return nil, err
+	}
+
+	doc = ast.PruneDocumentToSection(doc, sectionPointer.SectionName)
+	sectionPointer.SetIfScript("") // Ensure the requested section runs.
+
+	rdutil.Logger.Info().Msgf("Running %s in %s...\n\n", sectionPointer.SectionName, section.Document.Filename)
+
+	before := maps.Clone(executionContext.Env)
+	err = render(out, section.Document, doc, dumpAst)
+
+	captured := map[string]string{}
+	for k, v := range executionContext.Env {
+		if previous, ok := before[k]; !ok || previous != v {
+			captured[k] = v
+		}
+	}
+
+	return captured, err
+}
+
+// Runs the entire document from top to bottom. Used for documents which don't define any sections.
+func RunDocument(document *rundown.LoadedDocument, dumpAst bool) error {
+	executionContext := document.Context
+	executionContext.ImportRawEnv(os.Environ())
+	executionContext.ApplyEnvDefaults()
+	executionContext.RundownFile = document.Filename
+
+	if err := ast.FillInvokeBlocks(document.Document, 10); err != nil {
+		return err
+	}
+
+	rdutil.Logger.Info().Msgf("Running %s...\n\n", document.Filename)
+
+	return render(os.Stdout, document, document.Document, dumpAst)
+}
+
+func render(out io.Writer, document *rundown.LoadedDocument, doc goldast.Node, dumpAst bool) error {
+	source := document.Source
+
+	if dumpAst {
+		doc.Dump(source, 1)
+	}
+
+	// Sections can render in parallel, so the dump is written to a buffer rather than captured from os.Stdout.
+	if event := rdutil.Logger.Debug(); event.Enabled() {
+		dump := &strings.Builder{}
+		rdutil.WriteDump(dump, doc, source)
+		event.Msg(dump.String())
+	}
+
+	document.Context.SetDirectories()
+
+	if _, err := document.Context.Workspace(); err != nil {
+		return err
+	}
+
+	err := document.RenderNode(out, doc)
+
+	if kept, closeErr := document.Context.CloseWorkspace(); closeErr != nil {
+		rdutil.Logger.Warn().Msgf("Unable to remove workspace: %s", closeErr)
+	} else if kept != "" {
+		fmt.Fprintf(os.Stderr, "Workspace kept at %s\n", kept)
+	}
+
+	switch {
+	case errors.Is(err, errs.ErrStopOk):
+		return nil
+	default:
+		return err
+	}
+}
@@ -0,0 +1,263 @@
+package ports
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	rundown "github.com/elseano/rundown/pkg"
+	"github.com/elseano/rundown/pkg/errs"
+	"github.com/elseano/rundown/pkg/renderer"
+	"github.com/elseano/rundown/pkg/renderer/term"
+	"github.com/elseano/rundown/pkg/renderer/term/spinner"
+)
+
+// A section to run with RunSections, with its options keyed by their environment name (i.e. OPT_NAME).
+type SectionRun struct {
+	Name    string
+	Options map[string]string
+}
+
+// How RunSections runs its sections.
+type RunSectionsConfig struct {
+	// Runs the sections at the same time, writing each section's output once it finishes.
+	Parallel bool
+
+	// Runs the remaining sections after one fails, rather than stopping.
+	KeepGoing bool
+
+	// Passes the variables each section captures on to the sections after it. Can't be used with Parallel.
+	ShareEnv bool
+}
+
+// The outcome of a single section run by RunSections.
+type sectionResult struct {
+	Name string
+	Ran  bool
+	Err  error
+}
+
+// Runs several sections in one go, then writes a summary of their results. Dependencies the sections have
+// in common only run once.
+//
+// Running a section changes the documents, so load is called for a fresh copy of them for each section.
+func RunSections(out io.Writer, load func() (*rundown.LoadedDocuments, error), runs []SectionRun, config RunSectionsConfig) error {
+	if config.Parallel && config.ShareEnv {
+		return errors.New("sections running in parallel can't share their environment")
+	}
+
+	sections := make([]*rundown.Section, len(runs))
+
+	for i, run := range runs {
+		loaded, err := load()
+		if err != nil {
+			return err
+		}
+
+		if sections[i] = findSection(loaded, run.Name); sections[i] == nil {
+			return fmt.Errorf("section %s not found", run.Name)
+		}
+	}
+
+	var results []sectionResult
+	if config.Parallel {
+		results = runSectionsInParallel(out, sections, runs, config)
+	} else {
+		results = runSectionsInSequence(out, sections, runs, config)
+	}
+
+	writeSectionSummary(out, results)
+
+	return sectionsError(results)
+}
+
+func runSectionsInSequence(out io.Writer, sections []*rundown.Section, runs []SectionRun, config RunSectionsConfig) []sectionResult {
+	results := []sectionResult{}
+	deps := map[string]bool{}
+	env := map[string]string{}
+	stopping := false
+
+	for i, section := range sections {
+		if stopping {
+			results = append(results, sectionResult{Name: runs[i].Name})
+			continue
+		}
+
+		ctx := section.Document.Context
+		ctx.DepsCompleted = deps
+
+		captured, err := runSection(out, section, runs[i].Options, env, false)
+		writeSectionError(out, err)
+
+		// Sections which depend on this one don't need to run it again.
+		if err == nil {
+			deps[runs[i].Name] = true
+		}
+
+		if config.ShareEnv {
+			for k, v := range captured {
+				env[k] = v
+			}
+		}
+
+		results = append(results, sectionResult{Name: runs[i].Name, Ran: true, Err: err})
+		stopping = errors.Is(err, errs.ErrCancelled) || (err != nil && !config.KeepGoing)
+	}
+
+	return results
+}
+
+// Runs every section at the same time. Each section's output is held back and written in one piece once it
+// finishes, so the sections' output doesn't interleave. Unless keep-going is set, a failure cancels the others.
+func runSectionsInParallel(out io.Writer, sections []*rundown.Section, runs []SectionRun, config RunSectionsConfig) []sectionResult {
+	results := make([]sectionResult, len(sections))
+	deps := renderer.NewSharedDependencies(nil)
+
+	fmt.Fprintln(out, term.Aurora.Bold(fmt.Sprintf("Running %d sections...\n", len(sections))))
+
+	lock := &sync.Mutex{}
+	waiter := sync.WaitGroup{}
+	stopped := map[int]bool{}
+
+	for i, section := range sections {
+		results[i] = sectionResult{Name: runs[i].Name}
+
+		ctx := section.Document.Context
+		ctx.Buffered = true
+		ctx.SharedDeps = deps.ForSection()
+
+		waiter.Add(1)
+
+		go func(i int, section *rundown.Section) {
+			defer waiter.Done()
+
+			sectionDeps := section.Document.Context.SharedDeps
+
+			// Waits for sections running this one as a dependency, and stops others doing so while it runs.
+			sectionDeps.Claim(runs[i].Name)
+
+			output := &bytes.Buffer{}
+			_, err := runSection(output, section, runs[i].Options, nil, false)
+
+			if err == nil {
+				sectionDeps.Complete(runs[i].Name)
+			}
+
+			sectionDeps.Release()
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			mark := term.Aurora.Green(spinner.TICK)
+
+			switch {
+			case stopped[i] && errors.Is(err, errs.ErrCancelled):
+				// Cancelled because another section failed, so it didn't get to finish.
+				mark = term.Aurora.Faint(spinner.SKIP)
+				err = nil
+			case err != nil:
+				mark = term.Aurora.Red(spinner.CROSS)
+				fallthrough
+			default:
+				results[i].Ran = true
+				results[i].Err = err
+			}
+
+			fmt.Fprintln(out, term.Aurora.Bold(fmt.Sprintf("%s %s\n", mark, runs[i].Name)))
+			out.Write(output.Bytes())
+			writeSectionError(out, err)
+
+			// Sections which were cancelled part way through don't end with a gap before the next.
+			if !bytes.HasSuffix(output.Bytes(), []byte("\n\n")) {
+				fmt.Fprintln(out)
+			}
+
+			if err == nil || config.KeepGoing || errors.Is(err, errs.ErrCancelled) {
+				return
+			}
+
+			for j, other := range sections {
+				if j != i && !results[j].Ran && !stopped[j] {
+					stopped[j] = true
+					other.Document.Context.SetCancelled(true)
+				}
+			}
+		}(i, section)
+	}
+
+	waiter.Wait()
+
+	return results
+}
+
+// Writes errors which stopped the section before the renderer could show them, such as invalid options.
+func writeSectionError(out io.Writer, err error) {
+	if err == nil || errorShown(err) {
+		return
+	}
+
+	fmt.Fprintf(out, "Error: %s\n\n", err.Error())
+}
+
+// Returns true if the renderer has already shown the error.
+func errorShown(err error) bool {
+	var executionError *errs.ExecutionError
+
+	return errors.As(err, &executionError) || errors.Is(err, errs.ErrStopFail) || errors.Is(err, errs.ErrCancelled)
+}
+
+func writeSectionSummary(out io.Writer, results []sectionResult) {
+	succeeded := 0
+	for _, result := range results {
+		if result.Ran && result.Err == nil {
+			succeeded++
+		}
+	}
+
+	fmt.Fprintln(out, term.Aurora.Bold(fmt.Sprintf("%d of %d sections succeeded", succeeded, len(results))))
+
+	for _, result := range results {
+		switch {
+		case !result.Ran:
+			fmt.Fprintf(out, "  %s %s\n", term.Aurora.Faint(spinner.SKIP), term.Aurora.Faint(result.Name))
+		case result.Err != nil:
+			fmt.Fprintf(out, "  %s %s\n", term.Aurora.Red(spinner.CROSS), result.Name)
+		default:
+			fmt.Fprintf(out, "  %s %s\n", term.Aurora.Green(spinner.TICK), result.Name)
+		}
+	}
+}
+
+// Returns the error the run fails with. Cancellation takes precedence, otherwise it's the first failure.
+// Errors which have already been written are replaced with ErrStopFail, so they're not shown again.
+func sectionsError(results []sectionResult) error {
+	var first error
+
+	for _, result := range results {
+		if errors.Is(result.Err, errs.ErrCancelled) {
+			return result.Err
+		}
+
+		if first == nil && result.Err != nil {
+			first = result.Err
+		}
+	}
+
+	if first != nil && !errorShown(first) {
+		return errs.ErrStopFail
+	}
+
+	return first
+}
+
+func findSection(loaded *rundown.LoadedDocuments, name string) *rundown.Section {
+	for _, section := range loaded.GetSections() {
+		if section.Pointer.SectionName == name {
+			return section
+		}
+	}
+
+	return nil
+}
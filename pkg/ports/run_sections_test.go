@@ -0,0 +1,147 @@
+package ports
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	rundown "github.com/elseano/rundown/pkg"
+	"github.com/elseano/rundown/pkg/errs"
+	"github.com/elseano/rundown/pkg/renderer/term"
+	"github.com/logrusorgru/aurora"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sectionsSource = `
+# Setup <r section="setup"/>
+
+<r spinner="Setting up"/>
+
+` + "``` bash" + `
+sleep 0.2
+echo setup >> runs.log
+` + "```" + `
+
+# Build <r section="build"/>
+
+<r opt="mode" type="string" default="debug"/>
+<r opt="jobs" type="int" default="1"/>
+
+<r dep="setup"/>
+
+<r spinner="Building" capture-env="VERSION"/>
+
+` + "``` bash" + `
+VERSION="1.0-$OPT_MODE"
+echo "build $OPT_MODE" >> runs.log
+` + "```" + `
+
+# Test <r section="test"/>
+
+<r dep="setup"/>
+
+<r spinner="Testing"/>
+
+` + "``` bash" + `
+echo "test ${VERSION:-none}" >> runs.log
+` + "```" + `
+
+# Broken <r section="broken"/>
+
+<r spinner="Breaking"/>
+
+` + "``` bash" + `
+false
+` + "```" + `
+`
+
+func runSections(t *testing.T, runs []SectionRun, config RunSectionsConfig) (string, []string, error) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "tool.md")
+	require.NoError(t, os.WriteFile(filename, []byte(sectionsSource), 0644))
+
+	load := func() (*rundown.LoadedDocuments, error) {
+		return rundown.Load(filename)
+	}
+
+	out := &bytes.Buffer{}
+	err := RunSections(out, load, runs, config)
+
+	log, _ := os.ReadFile(filepath.Join(dir, "runs.log"))
+	return out.String(), strings.Split(strings.TrimSpace(string(log)), "\n"), err
+}
+
+func TestRunSections(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash isn't installed")
+	}
+
+	term.Aurora = aurora.NewAurora(false)
+	term.ColorsEnabled = false
+
+	t.Run("in sequence", func(t *testing.T) {
+		out, log, err := runSections(t, []SectionRun{{Name: "build", Options: map[string]string{"OPT_MODE": "release"}}, {Name: "test"}}, RunSectionsConfig{})
+		require.NoError(t, err, out)
+
+		assert.Equal(t, []string{"setup", "build release", "test none"}, log)
+		assert.Contains(t, out, "2 of 2 sections succeeded")
+	})
+
+	t.Run("sharing the environment", func(t *testing.T) {
+		out, log, err := runSections(t, []SectionRun{{Name: "build"}, {Name: "test"}}, RunSectionsConfig{ShareEnv: true})
+		require.NoError(t, err, out)
+
+		assert.Equal(t, []string{"setup", "build debug", "test 1.0-debug"}, log)
+	})
+
+	t.Run("sections count as dependencies", func(t *testing.T) {
+		out, log, err := runSections(t, []SectionRun{{Name: "setup"}, {Name: "test"}}, RunSectionsConfig{})
+		require.NoError(t, err, out)
+
+		assert.Equal(t, []string{"setup", "test none"}, log)
+	})
+
+	t.Run("stops at the first failure", func(t *testing.T) {
+		out, log, err := runSections(t, []SectionRun{{Name: "broken"}, {Name: "test"}}, RunSectionsConfig{})
+
+		var executionError *errs.ExecutionError
+		assert.True(t, errors.As(err, &executionError), err)
+		assert.Equal(t, []string{""}, log)
+		assert.Contains(t, out, "0 of 2 sections succeeded")
+	})
+
+	t.Run("keeps going", func(t *testing.T) {
+		out, log, err := runSections(t, []SectionRun{{Name: "broken"}, {Name: "test"}}, RunSectionsConfig{KeepGoing: true})
+
+		assert.Error(t, err)
+		assert.Equal(t, []string{"setup", "test none"}, log)
+		assert.Contains(t, out, "1 of 2 sections succeeded")
+	})
+
+	t.Run("in parallel", func(t *testing.T) {
+		out, log, err := runSections(t, []SectionRun{{Name: "build"}, {Name: "test"}}, RunSectionsConfig{Parallel: true})
+		require.NoError(t, err, out)
+
+		assert.ElementsMatch(t, []string{"setup", "build debug", "test none"}, log)
+		assert.Contains(t, out, "Running 2 sections...")
+		assert.Contains(t, out, "2 of 2 sections succeeded")
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		out, _, err := runSections(t, []SectionRun{{Name: "test", Options: map[string]string{}}, {Name: "build", Options: map[string]string{"OPT_JOBS": "many"}}}, RunSectionsConfig{})
+
+		assert.ErrorIs(t, err, errs.ErrStopFail)
+		assert.Contains(t, out, "Error: jobs: \"many\" must be a whole number")
+		assert.Contains(t, out, "1 of 2 sections succeeded")
+	})
+
+	t.Run("sharing the environment in parallel", func(t *testing.T) {
+		_, _, err := runSections(t, []SectionRun{{Name: "build"}, {Name: "test"}}, RunSectionsConfig{Parallel: true, ShareEnv: true})
+		assert.Error(t, err)
+	})
+}
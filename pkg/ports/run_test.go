@@ -0,0 +1,33 @@
+package ports
+
+import (
+	"bytes"
+	"testing"
+
+	rundown "github.com/elseano/rundown/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunSectionRequiredIfEnvironment(t *testing.T) {
+	source := `
+# Deploy <r section="deploy"/>
+
+<r opt="reason" type="string" required-if="$CI == 'true'"/>
+`
+
+	loaded, err := rundown.LoadString(source, "deploy.md")
+	require.NoError(t, err)
+
+	section := findSection(loaded, "deploy")
+	require.NotNil(t, section)
+
+	// Conditions see the run's environment, not just the options.
+	_, err = runSection(&bytes.Buffer{}, section, map[string]string{}, map[string]string{"CI": "true"}, false)
+	if assert.Error(t, err) {
+		assert.Equal(t, "reason: a value is required when $CI == 'true'", err.Error())
+	}
+
+	_, err = runSection(&bytes.Buffer{}, section, map[string]string{"OPT_REASON": "hotfix"}, map[string]string{"CI": "true"}, false)
+	assert.NoError(t, err)
+}
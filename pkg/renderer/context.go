@@ -3,63 +3,230 @@ package renderer
 import (
 	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
 	"path"
 	"regexp"
 	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/elseano/rundown/pkg/ast"
+	"github.com/elseano/rundown/pkg/exec/scripts"
 )
 
+// The environment variable holding the directory rundown was run from.
+const InvocationDirEnvName = "INVOCATION_DIR"
+
 type Context struct {
-	Env         map[string]string
-	Output      io.Writer
-	RundownFile string
+	Env          map[string]string
+	EnvDefaults  map[string]string
+	Interpreters scripts.Interpreters
+	Output       io.Writer
+	RundownFile  string
+
+	// The directory rundown was run from, as blocks run in the document's directory.
+	InvocationDir string
+
+	// Answers yes to confirmations, rather than asking. Set by --yes.
+	AssumeYes bool
+
+	// Keeps the workspace once the run finishes, rather than removing it. Set by --keep-workspace.
+	KeepWorkspace bool
+
+	// Set when the output is held back and written once the run finishes, such as sections run in parallel,
+	// so spinners write each step on its own line rather than animating.
+	Buffered bool
+
+	// Set when the user interrupts the run, so no further scripts are started. Shared with clones.
+	cancelled *atomic.Bool
+
+	workspace *Workspace
+
+	// The cleanup blocks the run has reached, which run once it finishes. Clones have their own.
+	cleanups []*ast.Cleanup
+
+	// Guards Env against scripts setting variables while they run, such as through the SDK.
+	envLock *sync.Mutex
 
 	DepsCompleted map[string]bool
+
+	// Set when sections run at the same time, so they run their common dependencies once. Nil otherwise.
+	SharedDeps *SectionDependencies
 }
 
 func NewContext(rundownFile string) *Context {
+	invocationDir, _ := os.Getwd()
+
 	return &Context{
+		InvocationDir: invocationDir,
 		Env:           map[string]string{},
+		EnvDefaults:   map[string]string{},
+		Interpreters:  scripts.Interpreters{},
 		RundownFile:   rundownFile,
 		DepsCompleted: map[string]bool{},
+		cancelled:     &atomic.Bool{},
+		envLock:       &sync.Mutex{},
 	}
 }
 
-// Opens a temporary file, and adds it's filename to the context environment.
+// Returns a copy of the context with its own environment, for running content alongside the original,
+// such as foreach iterations. The copy shares the workspace and cancellation.
+func (c *Context) Clone() *Context {
+	clone := *c
+
+	clone.Env = map[string]string{}
+	clone.envLock = &sync.Mutex{}
+	clone.ImportEnv(c.Env)
+
+	clone.DepsCompleted = map[string]bool{}
+	for k, v := range c.DepsCompleted {
+		clone.DepsCompleted[k] = v
+	}
+
+	clone.cleanups = nil
+
+	return &clone
+}
+
+// Registers a cleanup block the run has reached, so it runs once the run finishes.
+func (c *Context) AddCleanup(cleanup *ast.Cleanup) {
+	c.cleanups = append(c.cleanups, cleanup)
+}
+
+// Returns the cleanup blocks registered so far, in the order they were reached, and clears them.
+func (c *Context) TakeCleanups() []*ast.Cleanup {
+	cleanups := c.cleanups
+	c.cleanups = nil
+
+	return cleanups
+}
+
+// Opens a new file in the workspace, and adds it's filename to the context environment.
 func (c *Context) CreateTempFile(name string) (*os.File, error) {
 	nameParts := strings.SplitN(name, ".", 2)
-	if len(nameParts) == 1 {
-		nameParts = append(nameParts, "")
+
+	pattern := nameParts[0] + "-*"
+	if len(nameParts) == 2 {
+		pattern += "." + nameParts[1]
 	}
 
-	file, err := ioutil.TempFile("", fmt.Sprintf("rd-%s-*.%s", nameParts[0], nameParts[1]))
+	workspace, err := c.Workspace()
 	if err != nil {
 		return nil, err
 	}
 
-	envName := strings.ToUpper(nameParts[0])
-	envName = regexp.MustCompile(`[^A-Z0-9_]`).ReplaceAllString(envName, "_")
-	envName = fmt.Sprintf("%s_FILE", envName)
+	dir, err := workspace.Subdir("files")
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
 
-	c.Env[envName] = file.Name()
+	c.Env[TempFileEnvName(name)] = file.Name()
 
 	return file, nil
 }
 
+// Returns the environment variable holding the path of the file created by CreateTempFile, i.e. CONFIG_FILE for config.yml.
+func TempFileEnvName(name string) string {
+	envName := strings.ToUpper(strings.SplitN(name, ".", 2)[0])
+	envName = regexp.MustCompile(`[^A-Z0-9_]`).ReplaceAllString(envName, "_")
+
+	return fmt.Sprintf("%s_FILE", envName)
+}
+
+// Returns the run's workspace, creating it on first use, and exposes it to scripts as $RUNDOWN_WORKSPACE.
+func (c *Context) Workspace() (*Workspace, error) {
+	if c.workspace == nil {
+		workspace, err := NewWorkspace()
+		if err != nil {
+			return nil, err
+		}
+
+		c.workspace = workspace
+		c.AddEnv(WorkspaceEnvName, workspace.Dir)
+	}
+
+	return c.workspace, nil
+}
+
+// Removes the workspace once the run has finished, unless KeepWorkspace is set.
+// Returns the directory of a kept workspace, or an empty string.
+func (c *Context) CloseWorkspace() (string, error) {
+	if c.workspace == nil {
+		return "", nil
+	}
+
+	workspace := c.workspace
+
+	if c.KeepWorkspace {
+		return workspace.Dir, nil
+	}
+
+	c.workspace = nil
+	delete(c.Env, WorkspaceEnvName)
+
+	return "", workspace.Remove()
+}
+
 func (c *Context) ResetEnv() {
 	c.Env = map[string]string{}
 	c.ImportRawEnv(os.Environ())
+	c.ApplyEnvDefaults()
+	c.SetDirectories()
 
+	if c.workspace != nil {
+		c.Env[WorkspaceEnvName] = c.workspace.Dir
+	}
+}
+
+// Sets PWD to the document's directory, where blocks run by default, and INVOCATION_DIR to where rundown was run from.
+func (c *Context) SetDirectories() {
 	c.Env["PWD"] = path.Dir(c.RundownFile)
+	c.Env[InvocationDirEnvName] = c.InvocationDir
 }
 
 func (c *Context) ImportEnv(env map[string]string) {
+	c.envLock.Lock()
+	defer c.envLock.Unlock()
+
 	for k, v := range env {
 		c.Env[k] = v
 	}
 }
 
+// Adds interpreters declared by a document. Interpreters which already exist aren't replaced,
+// so the first document loaded takes precedence.
+func (c *Context) ImportInterpreters(interpreters scripts.Interpreters) {
+	for k, v := range interpreters {
+		if _, ok := c.Interpreters[k]; !ok {
+			c.Interpreters[k] = v
+		}
+	}
+}
+
+// Adds defaults for the environment. Defaults which already exist aren't replaced,
+// so the first document loaded takes precedence.
+func (c *Context) ImportEnvDefaults(env map[string]string) {
+	for k, v := range env {
+		if _, ok := c.EnvDefaults[k]; !ok {
+			c.EnvDefaults[k] = v
+		}
+	}
+}
+
+// Sets any environment defaults which aren't already set in the environment.
+func (c *Context) ApplyEnvDefaults() {
+	for k, v := range c.EnvDefaults {
+		if _, ok := c.Env[k]; !ok {
+			c.Env[k] = v
+		}
+	}
+}
+
 func (c *Context) ImportRawEnv(env []string) {
 	for _, v := range env {
 		parts := strings.SplitN(v, "=", 2)
@@ -72,6 +239,19 @@ func (c *Context) ImportRawEnv(env []string) {
 	}
 }
 
+// Sets an environment variable. Safe to call while a script is running.
 func (c *Context) AddEnv(key string, value string) {
+	c.envLock.Lock()
+	defer c.envLock.Unlock()
+
 	c.Env[key] = value
 }
+
+// Marks the run as cancelled, or clears it so cleanup scripts can run.
+func (c *Context) SetCancelled(cancelled bool) {
+	c.cancelled.Store(cancelled)
+}
+
+func (c *Context) Cancelled() bool {
+	return c.cancelled.Load()
+}
@@ -0,0 +1,115 @@
+package renderer
+
+import "sync"
+
+// Tracks the dependencies of sections running at the same time, so each dependency only runs once.
+// A section reaching a dependency another section is running waits for it to finish, rather than running it too.
+type SharedDependencies struct {
+	lock      sync.Mutex
+	completed map[string]bool
+	running   map[string]chan struct{}
+}
+
+func NewSharedDependencies(completed map[string]bool) *SharedDependencies {
+	deps := &SharedDependencies{completed: map[string]bool{}, running: map[string]chan struct{}{}}
+
+	for k, v := range completed {
+		deps.completed[k] = v
+	}
+
+	return deps
+}
+
+// Returns the completed dependencies.
+func (d *SharedDependencies) Completed() map[string]bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	result := map[string]bool{}
+	for k, v := range d.completed {
+		result[k] = v
+	}
+
+	return result
+}
+
+// Returns a view of the dependencies for a single section, which keeps track of the dependencies it's running.
+func (d *SharedDependencies) ForSection() *SectionDependencies {
+	return &SectionDependencies{shared: d, claimed: map[string]bool{}}
+}
+
+// A section's view of the shared dependencies. Parallel foreach iterations within the section each get their own.
+type SectionDependencies struct {
+	shared  *SharedDependencies
+	claimed map[string]bool
+}
+
+// Returns the dependencies shared with other sections.
+func (s *SectionDependencies) Shared() *SharedDependencies {
+	return s.shared
+}
+
+// Claims the dependency for the section to run. Returns false when it's already completed, waiting for it first
+// when another section is running it.
+func (s *SectionDependencies) Claim(name string) bool {
+	d := s.shared
+
+	for {
+		d.lock.Lock()
+
+		if d.completed[name] {
+			d.lock.Unlock()
+			return false
+		}
+
+		running, ok := d.running[name]
+		if !ok {
+			d.running[name] = make(chan struct{})
+			s.claimed[name] = true
+			d.lock.Unlock()
+			return true
+		}
+
+		d.lock.Unlock()
+
+		// If the other section fails to complete it, the dependency can be claimed again.
+		<-running
+	}
+}
+
+// Records a dependency claimed by the section as completed.
+func (s *SectionDependencies) Complete(name string) {
+	s.release(name, true)
+}
+
+// Gives up the dependencies the section claimed but didn't complete, such as when it failed part way through,
+// so sections waiting on them can run them instead.
+func (s *SectionDependencies) Release() {
+	s.shared.lock.Lock()
+	names := []string{}
+	for name := range s.claimed {
+		names = append(names, name)
+	}
+	s.shared.lock.Unlock()
+
+	for _, name := range names {
+		s.release(name, false)
+	}
+}
+
+func (s *SectionDependencies) release(name string, completed bool) {
+	d := s.shared
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if !s.claimed[name] {
+		return
+	}
+
+	delete(s.claimed, name)
+	d.completed[name] = completed
+
+	close(d.running[name])
+	delete(d.running, name)
+}
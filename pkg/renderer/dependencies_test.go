@@ -0,0 +1,46 @@
+package renderer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSharedDependencies(t *testing.T) {
+	deps := NewSharedDependencies(map[string]bool{"done": true})
+
+	first := deps.ForSection()
+	second := deps.ForSection()
+
+	assert.False(t, first.Claim("done"))
+	assert.True(t, first.Claim("setup"))
+
+	t.Run("waits while another section runs it", func(t *testing.T) {
+		claimed := make(chan bool)
+		go func() { claimed <- second.Claim("setup") }()
+
+		select {
+		case <-claimed:
+			t.Fatal("claimed a dependency which is running")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		first.Complete("setup")
+		assert.False(t, <-claimed)
+	})
+
+	t.Run("released dependencies can be claimed again", func(t *testing.T) {
+		assert.True(t, first.Claim("build"))
+
+		claimed := make(chan bool)
+		go func() { claimed <- second.Claim("build") }()
+
+		first.Release()
+		assert.True(t, <-claimed)
+
+		second.Complete("build")
+	})
+
+	assert.Equal(t, map[string]bool{"done": true, "setup": true, "build": true}, deps.Completed())
+}
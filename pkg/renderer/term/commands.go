@@ -1,6 +1,7 @@
 package term
 
 import (
+	"strconv"
 	"strings"
 
 	"github.com/elseano/rundown/pkg/renderer"
@@ -10,6 +11,7 @@ import (
 func HandleCommands(spinner Spinner, context *renderer.Context) func(s string) {
 	const changeSpinnerTitleCommand = "R;SETSPINNER "
 	const setEnvironmentCommand = "R;SETENV "
+	const progressCommand = "R;PROGRESS "
 
 	return func(command string) {
 		util.Logger.Debug().Msgf("Got command: %s", command)
@@ -28,7 +30,23 @@ func HandleCommands(spinner Spinner, context *renderer.Context) func(s string) {
 			args := strings.SplitN(_args, "=", 2)
 
 			util.Logger.Debug().Msgf("Got environment %s = %s", strings.TrimSpace(args[0]), args[1])
-			context.Env[strings.TrimSpace(args[0])] = args[1]
+			context.AddEnv(strings.TrimSpace(args[0]), args[1])
+
+		case strings.HasPrefix(command, progressCommand):
+			if current, total, label, ok := ParseProgress(command[len(progressCommand):]); ok {
+				spinner.Progress(current, total, label)
+			}
 		}
 	}
 }
+
+// Parses progress of the form "current/total [label]".
+func ParseProgress(progress string) (int, int, string, bool) {
+	counts, label, _ := strings.Cut(strings.TrimSpace(progress), " ")
+	current, total, _ := strings.Cut(counts, "/")
+
+	c, errC := strconv.Atoi(current)
+	t, errT := strconv.Atoi(total)
+
+	return c, t, strings.TrimSpace(label), errC == nil && errT == nil
+}
@@ -0,0 +1,96 @@
+package term
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	rundown_ast "github.com/elseano/rundown/pkg/ast"
+	"github.com/elseano/rundown/pkg/errs"
+	"github.com/elseano/rundown/pkg/exec"
+	"github.com/elseano/rundown/pkg/renderer/term/spinner"
+	rdutil "github.com/elseano/rundown/pkg/util"
+	"github.com/logrusorgru/aurora"
+	"github.com/manifoldco/promptui"
+	"github.com/mattn/go-isatty"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/util"
+)
+
+// Renders any warning inside the confirm block, then asks the user whether to continue.
+// Stops with a failure when the user says no, or when there's nobody to ask and --yes wasn't given.
+func (r *Renderer) renderConfirm(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		r.inlineStyles.Push(Color(aurora.YellowFg))
+
+		if node.ChildCount() > 0 {
+			w.WriteString(Aurora.Yellow("⚠ ").String())
+		}
+
+		return ast.WalkContinue, nil
+	}
+
+	r.inlineStyles.Pop()
+
+	if node.ChildCount() > 0 {
+		w.WriteString("\n")
+	}
+
+	confirm := node.(*rundown_ast.Confirm)
+	prompt := rdutil.SubEnv(r.Context.Env, confirm.Prompt)
+	expect := rdutil.SubEnv(r.Context.Env, confirm.Expect)
+
+	if r.Context.AssumeYes {
+		w.WriteString(fmt.Sprintf("%s %s %s\n\n", Aurora.Green(spinner.TICK), prompt, Aurora.Faint("(--yes)")))
+		w.Flush()
+		return ast.WalkContinue, nil
+	}
+
+	if GetCI().IsCI() || !isatty.IsTerminal(os.Stdin.Fd()) {
+		w.WriteString(fmt.Sprintf("%s %s\n  Confirmation required, re-run with --yes to continue.\n", Aurora.Red(spinner.CROSS), prompt))
+		w.Flush()
+		return ast.WalkStop, errs.ErrStopFail
+	}
+
+	w.Flush()
+
+	confirmed, err := askConfirm(prompt, expect)
+	if err != nil && !errors.Is(err, promptui.ErrInterrupt) {
+		return ast.WalkStop, err
+	}
+
+	if !confirmed {
+		w.WriteString(fmt.Sprintf("%s Aborted.\n", Aurora.Red(spinner.CROSS)))
+		w.Flush()
+		return ast.WalkStop, errs.ErrStopFail
+	}
+
+	w.WriteString("\n")
+
+	return ast.WalkContinue, nil
+}
+
+// Asks the user to confirm, either with a y/N answer, or by typing the expected value when one is given.
+func askConfirm(prompt string, expect string) (bool, error) {
+	stdin := exec.NewStdinReader()
+	defer stdin.Stop()
+
+	if expect == "" {
+		// Promptui adds its own question mark.
+		question := promptui.Prompt{Label: strings.TrimSuffix(prompt, "?"), IsConfirm: true, Stdin: stdin.Claim()}
+
+		_, err := question.Run()
+		if errors.Is(err, promptui.ErrAbort) {
+			return false, nil
+		}
+
+		return err == nil, err
+	}
+
+	question := promptui.Prompt{Label: fmt.Sprintf("%s Type %s to continue", prompt, expect), Stdin: stdin.Claim()}
+
+	answer, err := question.Run()
+
+	return err == nil && strings.TrimSpace(answer) == expect, err
+}
@@ -3,10 +3,13 @@ package term
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"path"
+	"path/filepath"
 	"regexp"
 	"sync"
 	"time"
@@ -34,8 +37,10 @@ import (
 	"github.com/eliukblau/pixterm/pkg/ansimage"
 
 	rundown_ast "github.com/elseano/rundown/pkg/ast"
+	"github.com/elseano/rundown/pkg/condition"
 	"github.com/elseano/rundown/pkg/errs"
 	"github.com/elseano/rundown/pkg/exec"
+	"github.com/elseano/rundown/pkg/exec/rpc"
 	rundown_renderer "github.com/elseano/rundown/pkg/renderer"
 	"github.com/elseano/rundown/pkg/renderer/term/spinner"
 	"github.com/elseano/rundown/pkg/text"
@@ -60,14 +65,12 @@ func (e *consoleRendererExt) Extend(m goldmark.Markdown) {
 
 // A Config struct has configurations for the HTML based renderers.
 type Config struct {
-	TempDir        string
 	RundownHandler RundownHandler
 	Level          int
 	ConsoleWidth   int
 	LevelChange    func(level int)
 }
 
-const optTempDir renderer.OptionName = "TempDir"
 const optRundownHandler renderer.OptionName = "RundownHandler"
 const optLevelLevel renderer.OptionName = "LevelLevel"
 const optConsoleWidth renderer.OptionName = "ConsoleWidth"
@@ -173,14 +176,7 @@ func WithConsoleWidth(width int) interface {
 
 // NewConfig returns a new Config with defaults.
 func NewConfig() Config {
-	tmpDir, err := ioutil.TempDir("", "rundown")
-
-	if err != nil {
-		panic(err)
-	}
-
 	return Config{
-		TempDir:      tmpDir,
 		ConsoleWidth: 80,
 	}
 }
@@ -188,8 +184,6 @@ func NewConfig() Config {
 // SetOption implements renderer.NodeRenderer.SetOption.
 func (c *Config) SetOption(name renderer.OptionName, value interface{}) {
 	switch name {
-	case optTempDir:
-		c.TempDir = value.(string)
 	case optRundownHandler:
 		c.RundownHandler = value.(RundownHandler)
 	case optLevelLevel:
@@ -226,6 +220,9 @@ type Renderer struct {
 	wrappingWriter    *wordwrap.WordWrap
 	nonWrappingWriter util.BufWriter
 	lastRendered      ast.Node
+
+	// Set when output is held back to be written later, such as parallel foreach iterations, so spinners can't animate.
+	buffered bool
 }
 
 // NewRenderer returns a new Renderer with given options.
@@ -404,19 +401,25 @@ func (r *Renderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
 
 	// other
 	reg.Register(rundown_ast.KindDescriptionBlock, r.supportSkipping(r.renderHollow))
+	reg.Register(rundown_ast.KindRequires, r.supportSkipping(r.renderHollow))
+	reg.Register(rundown_ast.KindDefaultSection, r.supportSkipping(r.renderHollow))
 	reg.Register(rundown_ast.KindEnvironmentSubstitution, r.wrapInline(r.supportSkipping(r.renderEnvironmentSubstitution)))
 	reg.Register(rundown_ast.KindContentReplace, r.wrapInline(r.supportSkipping(r.renderContentReplace)))
 	// reg.Register(rundown_ast.KindExecutionBlock, r.renderTodo))
 	reg.Register(rundown_ast.KindIgnoreBlock, r.supportSkipping(r.renderTodo("Ignore")))
 	reg.Register(rundown_ast.KindOnFailure, r.supportSkipping(r.renderOnFailure))
+	reg.Register(rundown_ast.KindCleanup, r.renderCleanup)
 	reg.Register(rundown_ast.KindRundownBlock, r.supportSkipping(r.renderTodo("Rundown")))
 	reg.Register(rundown_ast.KindSaveCodeBlock, r.supportSkipping(r.renderSaveCodeBlock))
 	reg.Register(rundown_ast.KindSectionOption, r.supportSkipping(r.renderHollow))
 	reg.Register(rundown_ast.KindSectionPointer, r.supportSkipping(r.renderHollow))
+	reg.Register(rundown_ast.KindConfirm, r.supportSkipping(r.renderConfirm))
 	reg.Register(rundown_ast.KindStopFail, r.supportSkipping(r.renderStopFail))
 	reg.Register(rundown_ast.KindStopOk, r.supportSkipping(r.renderStopOk))
 	reg.Register(rundown_ast.KindSubEnvBlock, r.supportSkipping(r.renderHollow))
 	reg.Register(rundown_ast.KindInvokeBlock, r.supportSkipping(r.renderInvokeBlock))
+	reg.Register(rundown_ast.KindForeach, r.supportSkipping(r.renderForeach))
+	reg.Register(rundown_ast.KindForeachIteration, r.supportSkipping(r.renderHollow))
 	reg.Register(rundown_ast.KindSkipBlock, r.renderSkipBlock)
 
 	// Conditional blocks are transparent, they shouldn't render.
@@ -468,6 +471,12 @@ var GlobalAttributeFilter = util.NewBytesFilter(
 func (r *Renderer) renderDocument(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
 	r.SetLevel(r.Config.Level)
 
+	if entering {
+		if requirements := rundown_ast.GetRequirements(node); len(requirements) > 0 && !r.checkRequirements(w, requirements) {
+			return ast.WalkStop, errs.ErrStopFail
+		}
+	}
+
 	if !entering {
 		r.ensureBlockSeparator(w, node)
 
@@ -479,6 +488,39 @@ func (r *Renderer) renderDocument(w util.BufWriter, source []byte, node ast.Node
 	return ast.WalkContinue, nil
 }
 
+// Checks each required tool is available before anything runs, writing a report of the results.
+// Returns false if any requirement isn't met.
+func (r *Renderer) checkRequirements(w util.BufWriter, requirements []rundown_ast.Requirement) bool {
+	ok := true
+
+	w.WriteString(Aurora.Bold("Checking requirements...\n").String())
+
+	for _, req := range requirements {
+		_, version, err := exec.LookupTool(req.Name, req.Operator != "")
+
+		switch {
+		case err != nil:
+			ok = false
+			w.WriteString(fmt.Sprintf("  %s %s not found\n", Aurora.Red(spinner.CROSS), req.Name))
+		case req.Operator != "" && version == "":
+			ok = false
+			w.WriteString(fmt.Sprintf("  %s %s version unknown, requires %s%s\n", Aurora.Red(spinner.CROSS), req.Name, req.Operator, req.Version))
+		case !req.Satisfied(version):
+			ok = false
+			w.WriteString(fmt.Sprintf("  %s %s %s installed, requires %s%s\n", Aurora.Red(spinner.CROSS), req.Name, version, req.Operator, req.Version))
+		case version != "":
+			w.WriteString(fmt.Sprintf("  %s %s %s\n", Aurora.Green(spinner.TICK), req.Name, version))
+		default:
+			w.WriteString(fmt.Sprintf("  %s %s\n", Aurora.Green(spinner.TICK), req.Name))
+		}
+	}
+
+	w.WriteString("\n")
+	w.Flush()
+
+	return ok
+}
+
 func (r *Renderer) StartAt(node ast.Node) {
 	r.skipUntil = node
 }
@@ -501,6 +543,12 @@ func (r *Renderer) renderInvokeBlock(w util.BufWriter, source []byte, node ast.N
 			return ast.WalkSkipChildren, nil
 		}
 
+		// Sections running alongside this one may have already run it, or be running it now.
+		if invoke.AsDependency && r.Context.SharedDeps != nil && !r.Context.SharedDeps.Claim(invoke.Invoke) {
+			r.Context.DepsCompleted[invoke.Invoke] = true
+			return ast.WalkSkipChildren, nil
+		}
+
 		// Otherwise, snapshot the environment, and reset it for the invoked code.
 		invoke.PreviousEnv = r.Context.Env
 		r.Context.ResetEnv()
@@ -521,33 +569,114 @@ func (r *Renderer) renderInvokeBlock(w util.BufWriter, source []byte, node ast.N
 
 		r.Context.ImportEnv(env)
 	} else {
+		// Dependencies which were skipped as they'd already run didn't replace the environment.
+		if invoke.PreviousEnv == nil {
+			return ast.WalkContinue, nil
+		}
+
 		r.Context.ResetEnv()
 		r.Context.ImportEnv(invoke.PreviousEnv)
 		invoke.PreviousEnv = nil
 
 		if invoke.AsDependency {
 			r.Context.DepsCompleted[invoke.Invoke] = true
+
+			if r.Context.SharedDeps != nil {
+				r.Context.SharedDeps.Complete(invoke.Invoke)
+			}
 		}
 	}
 
 	return ast.WalkContinue, nil
 }
 
-func runIfScript(ctx *rundown_renderer.Context, ifScript string) (bool, error) {
+// Creates a runner which writes its scripts into the run's workspace.
+func newRunner(ctx *rundown_renderer.Context) (*exec.Runner, error) {
+	workspace, err := ctx.Workspace()
+	if err != nil {
+		return nil, err
+	}
+
+	scriptsDir, err := workspace.Subdir("scripts")
+	if err != nil {
+		return nil, err
+	}
+
+	runner := exec.NewRunner()
+	runner.TempDir = scriptsDir
+	runner.AddInterpreters(ctx.Interpreters)
+
+	return runner, nil
+}
+
+// Removes the runner's scripts, unless the workspace is being kept for debugging.
+func removeScripts(ctx *rundown_renderer.Context, runner *exec.Runner) {
+	if !ctx.KeepWorkspace {
+		runner.RemoveScripts()
+	}
+}
+
+// Returns the directory the block runs in. Blocks without a cwd run in the document's directory.
+// Relative directories are resolved against the directory of the file the block was declared in.
+func (r *Renderer) workingDir(executionBlock *rundown_ast.ExecutionBlock) (string, error) {
+	if executionBlock.Cwd == "" {
+		return r.Context.Env["PWD"], nil
+	}
+
+	dir := rdutil.SubEnv(r.Context.Env, executionBlock.Cwd)
+
+	if !filepath.IsAbs(dir) {
+		base := path.Dir(r.Context.RundownFile)
+		if executionBlock.Source.File != "" {
+			base = filepath.Dir(executionBlock.Source.File)
+		}
+
+		dir = filepath.Join(base, dir)
+	}
+
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("working directory %s doesn't exist", dir)
+	}
+
+	return dir, nil
+}
+
+// Runs the if script in dir, returning true if it succeeded. An empty dir runs it in the document's directory.
+// Expressions are evaluated directly, only shell scripts are run.
+func runIfScript(ctx *rundown_renderer.Context, ifScript string, dir string) (bool, error) {
+	if expression, err := condition.Parse(ifScript); err == nil {
+		if dir == "" {
+			dir = ctx.Env["PWD"]
+		}
+
+		return expression.Evaluate(condition.Environment{Vars: ctx.Env, Dir: dir})
+	} else if !errors.Is(err, condition.ErrNotExpression) {
+		return false, fmt.Errorf("invalid condition %q: %w", ifScript, err)
+	}
+
 	// Allow unset variables here, typically the script will be checking for these.
 	ifScript = fmt.Sprintf("set +u\n%s\n", ifScript)
 
-	runner := exec.NewRunner()
+	runner, err := newRunner(ctx)
+	if err != nil {
+		return false, err
+	}
+
 	runner.ImportEnv(ctx.Env)
 
+	if dir != "" {
+		runner.ImportEnv(map[string]string{"PWD": dir})
+	}
+
 	outputBuffer := bytes.Buffer{}
 	outputWait := sync.WaitGroup{}
 
-	_, err := runner.SetScript("sh", "sh", []byte(ifScript))
+	_, err = runner.SetScript("sh", "sh", []byte(ifScript))
 
 	if err != nil {
 		return false, err
 	}
+	defer removeScripts(ctx, runner)
 
 	process, err := runner.Prepare()
 	if err != nil {
@@ -618,6 +747,27 @@ func (r *Renderer) renderOnFailure(w util.BufWriter, source []byte, node ast.Nod
 	return ast.WalkSkipChildren, nil
 }
 
+// Cleanup blocks are skipped where they appear, and rendered once the section has finished.
+// Only those the run reaches are registered, so blocks after a stop, or in a branch which didn't run, don't run.
+func (r *Renderer) renderCleanup(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	cleanup := node.(*rundown_ast.Cleanup)
+
+	// The run may have finished while skipping content, such as a false conditional heading at the end.
+	if cleanup.Triggered {
+		r.skipUntil = nil
+		return ast.WalkContinue, nil
+	}
+
+	return r.supportSkipping(func(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+		r.Context.AddCleanup(cleanup)
+		return ast.WalkSkipChildren, nil
+	})(w, source, node, entering)
+}
+
 func (r *Renderer) renderStopOk(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
 	if !entering {
 		w.WriteString("\n")
@@ -734,6 +884,27 @@ func createSpinner(writer io.Writer, env map[string]string) Spinner {
 	return spinner.NewSubenvSpinner(env, s)
 }
 
+// Creates the spinner for a block. Buffered output gets the same spinner as CI, which writes each step on its own line.
+func (r *Renderer) newSpinner(writer io.Writer) Spinner {
+	if (r.buffered || r.Context.Buffered) && NewSpinnerFunc == nil {
+		return spinner.NewSubenvSpinner(r.Context.Env, spinner.NewCISpinner(NewFlushingWriter(writer), Aurora))
+	}
+
+	return createSpinner(writer, r.Context.Env)
+}
+
+// Returns where in the markdown the script failed. That's the failing line when the error names one
+// inside the code block, otherwise the start of the code block.
+func failurePosition(executionBlock *rundown_ast.ExecutionBlock, details *exec.ErrorDetails) rundown_ast.SourcePosition {
+	position := executionBlock.Source
+
+	if details.ErrorSource != nil && details.ErrorSource.Line > 0 && details.ErrorSource.Line <= executionBlock.CodeBlock.Lines().Len() {
+		position = position.Offset(details.ErrorSource.Line - 1)
+	}
+
+	return position
+}
+
 func (r *Renderer) renderExecutionBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
 	// Check if we're currently skipping
 	executionBlock := node.(*rundown_ast.ExecutionBlock)
@@ -766,13 +937,24 @@ func (r *Renderer) renderExecutionBlock(w util.BufWriter, source []byte, node as
 	rdutil.Logger.Debug().Msgf("Command to run script is: %s", executionBlock.With)
 	rdutil.Logger.Debug().Msgf("Script is: %s", executionBlock.With)
 
-	runner := exec.NewRunner()
+	runner, err := newRunner(r.Context)
+	if err != nil {
+		return ast.WalkStop, err
+	}
+
 	script, err := runner.SetScript(executionBlock.With, executionBlock.Language, scriptContents)
 	if err != nil {
 		return ast.WalkStop, err
 	}
+	defer removeScripts(r.Context, runner)
+
+	dir, err := r.workingDir(executionBlock)
+	if err != nil {
+		return ast.WalkStop, err
+	}
 
 	runner.ImportEnv(r.Context.Env)
+	runner.ImportEnv(map[string]string{"PWD": dir})
 
 	/***** SPINNERS *****/
 	var theSpinner Spinner
@@ -783,9 +965,9 @@ func (r *Renderer) renderExecutionBlock(w util.BufWriter, source []byte, node as
 	case rundown_ast.SpinnerModeHidden:
 		theSpinner = spinner.NewNullSpinner()
 	case rundown_ast.SpinnerModeVisible:
-		theSpinner = createSpinner(w, r.Context.Env)
+		theSpinner = r.newSpinner(w)
 	case rundown_ast.SpinnerModeInlineAll:
-		theSpinner = createSpinner(w, r.Context.Env)
+		theSpinner = r.newSpinner(w)
 		rdutil.Logger.Debug().Msgf("Stepped spinners.")
 		script.Contents = exec.ChangeCommentsToSpinnerCommands(executionBlock.Language, script.Contents)
 	}
@@ -798,6 +980,12 @@ func (r *Renderer) renderExecutionBlock(w util.BufWriter, source []byte, node as
 	theSpinner.SetMessage(executionBlock.SpinnerName)
 	theSpinner.Start()
 
+	// Errors stopping the run close the spinner, so the error isn't written over it.
+	failed := func(err error) (ast.WalkStatus, error) {
+		theSpinner.Error("Failed")
+		return ast.WalkStop, err
+	}
+
 	ifResult, err := r.checkIfScript(executionBlock)
 
 	if err != nil {
@@ -812,15 +1000,37 @@ func (r *Renderer) renderExecutionBlock(w util.BufWriter, source []byte, node as
 
 	r.lastRendered = node
 
+	if r.Context.Cancelled() {
+		theSpinner.Error("Cancelled")
+		return ast.WalkStop, errs.ErrCancelled
+	}
+
 	/***** ENVIRONMENT CAPTURE *****/
 	if executionBlock.CaptureEnvironment != nil {
-		exec.AddEnvironmentCapture(executionBlock.Language, script, executionBlock.CaptureEnvironment)
+		exec.AddEnvironmentCapture(script, executionBlock.CaptureEnvironment)
 	}
 
+	/***** SDK *****/
+	workspace, err := r.Context.Workspace()
+	if err != nil {
+		return failed(err)
+	}
+
+	// STDOUT and STDERR are copied on their own goroutines, but may both be written to the screen, as may SDK logs.
+	displayLock := &sync.Mutex{}
+
+	sdk, err := rpc.StartSDK(workspace.Dir, NewSDKHandler(theSpinner, r.Context, w, displayLock))
+	if err != nil {
+		return failed(err)
+	}
+	defer sdk.Close()
+
+	runner.ImportEnv(map[string]string{rpc.SDKEnvironmentVariableName: sdk.EnvValue()})
+
 	/***** RUN COMMAND *****/
 	process, err := runner.Prepare()
 	if err != nil {
-		return ast.WalkStop, err
+		return failed(err)
 	}
 
 	/***** OUTPUT HANDLING *****/
@@ -840,7 +1050,7 @@ func (r *Renderer) renderExecutionBlock(w util.BufWriter, source []byte, node as
 
 	// Setup the screen writer. It also controls RPC functions as some of them affect output, such as spinners.
 	screenWriter := NewAnsiScreenWriter(stdoutDisplayTarget)
-	outputTargets = append(outputTargets, screenWriter)
+	outputTargets = append(outputTargets, NewLockedWriter(displayLock, screenWriter))
 
 	if executionBlock.ShowStdout {
 		screenWriter.BeforeFlush(func() { theSpinner.Stop(); theSpinner.StampShadow() })
@@ -849,6 +1059,26 @@ func (r *Renderer) renderExecutionBlock(w util.BufWriter, source []byte, node as
 
 	screenWriter.CommandHandler = HandleCommands(theSpinner, r.Context)
 
+	// The STDERR buffer is for on-failure matching, error reporting and stderr-into.
+	stderrTargets := []io.Writer{&stderrBuffer}
+
+	if executionBlock.ShowStderr {
+		stderrScreenWriter := NewAnsiScreenWriter(NewStderrWriter(indent.NewWriterPipe(w, 4, nil)))
+		stderrScreenWriter.BeforeFlush(func() { theSpinner.Stop(); theSpinner.StampShadow() })
+		stderrScreenWriter.AfterFlush(theSpinner.Start)
+
+		stderrTargets = append(stderrTargets, NewLockedWriter(displayLock, stderrScreenWriter))
+	}
+
+	if executionBlock.ProgressFrom != "" {
+		pattern, err := regexp.Compile(executionBlock.ProgressFrom)
+		if err != nil {
+			return failed(err)
+		}
+
+		outputTargets = append(outputTargets, NewProgressScraper(pattern, theSpinner))
+	}
+
 	// With the output handlers setup, spin up a multiwriter to write to them.
 	outputWriters := io.MultiWriter(outputTargets...)
 	outputWaiter.Add(1)
@@ -857,35 +1087,60 @@ func (r *Renderer) renderExecutionBlock(w util.BufWriter, source []byte, node as
 		outputWaiter.Done()
 	}()
 
-	// Capture stderr into a buffer too
+	stderrWriters := io.MultiWriter(stderrTargets...)
 	outputWaiter.Add(1)
 	go func() {
-		io.Copy(&stderrBuffer, process.Stderr)
+		io.Copy(stderrWriters, process.Stderr)
 		outputWaiter.Done()
 	}()
 
 	/***** WAIT FOR PROCESS TO COMPLETE *****/
 	err = process.Start()
 	if err != nil {
-		return ast.WalkStop, err
+		return failed(err)
 	}
 
 	outputWaiter.Wait() // Wait for the process's STDOUT to close.
 	exitCode, _, err := process.Wait()
+	sdk.Close() // Ensure all commands sent by the script are processed.
 
 	if err != nil {
 		rdutil.Logger.Debug().Msgf("Execution failed with %#v", err)
-		return ast.WalkStop, err
+		return failed(err)
 	}
 
 	// Flush any remaining writes.
 	type flushable interface{ Flush() error }
-	for _, t := range outputTargets {
+	for _, t := range append(outputTargets, stderrTargets...) {
 		if flusher, ok := t.(flushable); ok {
 			flusher.Flush()
 		}
 	}
 
+	if process.Cancelled() {
+		r.Context.SetCancelled(true)
+		theSpinner.Error("Cancelled")
+
+		return ast.WalkStop, errs.ErrCancelled
+	}
+
+	/***** ARTIFACTS *****/
+
+	// Collected whether or not the script succeeded, as they're often what's needed to work out why it failed.
+	if executionBlock.Artifacts != "" {
+		workspace, err := r.Context.Workspace()
+		if err != nil {
+			return failed(err)
+		}
+
+		artifacts, err := workspace.CollectArtifacts(rdutil.SubEnv(r.Context.Env, executionBlock.Artifacts), dir, executionBlock.Source.String())
+		if err != nil {
+			return failed(err)
+		}
+
+		rdutil.Logger.Debug().Msgf("Collected %d artifacts", len(artifacts))
+	}
+
 	/***** ERROR HANDLING *****/
 
 	if executionBlock.SkipOnSuccess {
@@ -916,7 +1171,7 @@ func (r *Renderer) renderExecutionBlock(w util.BufWriter, source []byte, node as
 
 	if err != nil || exitCode != 0 {
 		for onFailure, ok := executionBlock.NextSibling().(*rundown_ast.OnFailure); ok; onFailure, ok = onFailure.NextSibling().(*rundown_ast.OnFailure) {
-			if onFailure.MatchesError(stderrBuffer.Bytes()) {
+			if onFailure.MatchesOutput([]byte(outputBuffer.String()), stderrBuffer.Bytes()) {
 				theSpinner.Error("Handled")
 				onFailure.Triggered = true
 
@@ -927,7 +1182,7 @@ func (r *Renderer) renderExecutionBlock(w util.BufWriter, source []byte, node as
 
 	if err != nil {
 		rdutil.Logger.Debug().Msgf("Error: %s", err.Error())
-		return ast.WalkStop, err
+		return failed(err)
 	}
 
 	if exitCode != 0 {
@@ -968,13 +1223,18 @@ func (r *Renderer) renderExecutionBlock(w util.BufWriter, source []byte, node as
 		w.WriteString(Aurora.Red("Script Failed:\n").String())
 
 		resultErr := exec.ParseError(script, output)
+		position := failurePosition(executionBlock, resultErr)
+		if position.IsValid() && position.File != "" {
+			resultErr.Location = position.String()
+		}
+
 		r.writeLinesWithPrefix("  ", string(resultErr.String(Aurora)), w)
 
 		// Find on failure nodes
 		failureNodes := rundown_ast.GetOnFailureNodes(node)
 		insertAfterNode := node
 		for _, f := range failureNodes {
-			if f.MatchesError([]byte(output)) {
+			if f.MatchesOutput([]byte(outputBuffer.String()), stderrBuffer.Bytes()) {
 				newNode := f.ConvertToParagraph()
 				node.Parent().InsertAfter(node.Parent(), insertAfterNode, newNode)
 				insertAfterNode = newNode
@@ -983,7 +1243,11 @@ func (r *Renderer) renderExecutionBlock(w util.BufWriter, source []byte, node as
 
 		// Add GitHub annotation
 		if GetCI() == GitHubCI {
-			w.WriteString(fmt.Sprintf("::error::Script failed running '%s'", executionBlock.SpinnerName))
+			if position.IsValid() && position.File != "" {
+				w.WriteString(fmt.Sprintf("::error file=%s,line=%d::Script failed running '%s'\n", position.RelativeFile(), position.Line, executionBlock.SpinnerName))
+			} else {
+				w.WriteString(fmt.Sprintf("::error::Script failed running '%s'\n", executionBlock.SpinnerName))
+			}
 			w.Flush()
 		}
 
@@ -998,6 +1262,19 @@ func (r *Renderer) renderExecutionBlock(w util.BufWriter, source []byte, node as
 	if executionBlock.CaptureStdoutInto != "" {
 		outputTrimmed := strings.TrimSpace(outputBuffer.String())
 		r.Context.AddEnv(executionBlock.CaptureStdoutInto, outputTrimmed)
+
+		if err := r.saveOutput(executionBlock.CaptureStdoutInto, outputTrimmed); err != nil {
+			return failed(err)
+		}
+	}
+
+	if executionBlock.CaptureStderrInto != "" {
+		stderrTrimmed := strings.TrimSpace(stderrBuffer.String())
+		r.Context.AddEnv(executionBlock.CaptureStderrInto, stderrTrimmed)
+
+		if err := r.saveOutput(executionBlock.CaptureStderrInto, stderrTrimmed); err != nil {
+			return failed(err)
+		}
 	}
 
 	theSpinner.Success("")
@@ -1005,6 +1282,16 @@ func (r *Renderer) renderExecutionBlock(w util.BufWriter, source []byte, node as
 	return ast.WalkContinue, nil
 }
 
+// Keeps a copy of captured output in the workspace, for debugging the run afterwards.
+func (r *Renderer) saveOutput(name string, output string) error {
+	workspace, err := r.Context.Workspace()
+	if err != nil {
+		return err
+	}
+
+	return workspace.SaveOutput(name, output)
+}
+
 func (r *Renderer) renderRundownInline(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
 	// var buf bytes.Buffer
 	// var w2 = bufio.NewWriter(&buf)
@@ -1150,13 +1437,34 @@ func (r *Renderer) supportSkipping(renderFunc renderer.NodeRendererFunc) rendere
 }
 
 func (r *Renderer) checkIfScript(node ast.Node) (ast.WalkStatus, error) {
+	// Else branches are skipped once an earlier branch has run, and a plain else runs otherwise.
+	if branch, ok := node.(*rundown_ast.ConditionalStart); ok && branch.Else != nil && !branch.HasResult() {
+		if branch.EarlierBranchTaken() {
+			branch.SetResult(false)
+		} else if !branch.HasIfScript() {
+			branch.SetResult(true)
+		}
+
+		if branch.HasResult() && !branch.GetResult() {
+			return ast.WalkSkipChildren, nil
+		}
+	}
+
 	if container, ok := node.(rundown_ast.Conditional); ok {
 		rdutil.Logger.Debug().Msgf("Is a conditional: %T", node)
 		if container.HasIfScript() {
 			rdutil.Logger.Debug().Msgf("Has conditional script: %s", container.GetIfScript())
 
 			if !container.HasResult() {
-				result, err := runIfScript(r.Context, container.GetIfScript())
+				dir := ""
+				if executionBlock, ok := node.(*rundown_ast.ExecutionBlock); ok {
+					var err error
+					if dir, err = r.workingDir(executionBlock); err != nil {
+						return ast.WalkStop, err
+					}
+				}
+
+				result, err := runIfScript(r.Context, container.GetIfScript(), dir)
 
 				if err != nil {
 					rdutil.Logger.Error().Msgf("Error running conditional: %s", err.Error())
@@ -0,0 +1,278 @@
+package term
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	rundown_ast "github.com/elseano/rundown/pkg/ast"
+	"github.com/elseano/rundown/pkg/errs"
+	rundown_renderer "github.com/elseano/rundown/pkg/renderer"
+	"github.com/elseano/rundown/pkg/renderer/term/spinner"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+// The outcome of a single foreach iteration.
+type iterationResult struct {
+	Label string
+	Ran   bool
+	Err   error
+}
+
+// Runs the foreach's content once per combination of values, then writes a summary of the results.
+// Every iteration runs, unless fail-fast is set, and the foreach fails if any iteration failed.
+func (r *Renderer) renderForeach(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	foreach := node.(*rundown_ast.Foreach)
+	iterations := foreach.Iterations(r.Context.Env)
+
+	if len(iterations) == 0 {
+		return ast.WalkSkipChildren, nil
+	}
+
+	// Iterations live alongside the original content while they run, so they can find their section.
+	for _, iteration := range iterations {
+		foreach.AppendChild(foreach, iteration)
+	}
+
+	defer func() {
+		for _, iteration := range iterations {
+			foreach.RemoveChild(foreach, iteration)
+		}
+	}()
+
+	var results []iterationResult
+	if foreach.Parallel == 0 {
+		results = r.runIterationsInSequence(w, source, foreach, iterations)
+	} else {
+		results = r.runIterationsInParallel(w, source, foreach, iterations)
+	}
+
+	writeIterationSummary(w, results)
+
+	if err := iterationsError(results); err != nil {
+		return ast.WalkStop, err
+	}
+
+	if r.Context.Cancelled() {
+		return ast.WalkStop, errs.ErrCancelled
+	}
+
+	return ast.WalkSkipChildren, nil
+}
+
+func (r *Renderer) runIterationsInSequence(w util.BufWriter, source []byte, foreach *rundown_ast.Foreach, iterations []*rundown_ast.ForeachIteration) []iterationResult {
+	results := []iterationResult{}
+	stopping := false
+
+	for _, iteration := range iterations {
+		if stopping || r.Context.Cancelled() {
+			results = append(results, iterationResult{Label: iteration.Label})
+			continue
+		}
+
+		w.WriteString(Aurora.Bold(fmt.Sprintf("▸ %s\n\n", iteration.Label)).String())
+		w.Flush()
+
+		ctx := r.Context.Clone()
+		err := r.runIteration(w, source, iteration, ctx, false)
+		separateIteration(w, iteration)
+
+		// Later iterations don't need to run dependencies again.
+		for k, v := range ctx.DepsCompleted {
+			r.Context.DepsCompleted[k] = v
+		}
+
+		results = append(results, iterationResult{Label: iteration.Label, Ran: true, Err: err})
+		stopping = err != nil && foreach.FailFast
+	}
+
+	return results
+}
+
+// Runs iterations at the same time, up to the foreach's limit. Each iteration's output is held back
+// and written in one piece once it finishes, so the iterations' output doesn't interleave.
+func (r *Renderer) runIterationsInParallel(w util.BufWriter, source []byte, foreach *rundown_ast.Foreach, iterations []*rundown_ast.ForeachIteration) []iterationResult {
+	results := make([]iterationResult, len(iterations))
+
+	limit := foreach.Parallel
+	if limit < 0 || limit > len(iterations) {
+		limit = len(iterations)
+	}
+
+	theSpinner := r.newSpinner(w)
+	theSpinner.SetMessage(fmt.Sprintf("Running %d iterations...", len(iterations)))
+	theSpinner.Start()
+
+	// Iterations share their dependencies, so each dependency runs once however many iterations reach it.
+	var deps *rundown_renderer.SharedDependencies
+	if r.Context.SharedDeps != nil {
+		deps = r.Context.SharedDeps.Shared()
+	} else {
+		deps = rundown_renderer.NewSharedDependencies(r.Context.DepsCompleted)
+	}
+
+	lock := &sync.Mutex{}
+	slots := make(chan struct{}, limit)
+	waiter := sync.WaitGroup{}
+	finished := 0
+	failed := false
+
+	for i, iteration := range iterations {
+		results[i] = iterationResult{Label: iteration.Label}
+		slots <- struct{}{}
+
+		lock.Lock()
+		stopping := (failed && foreach.FailFast) || r.Context.Cancelled()
+		lock.Unlock()
+
+		if stopping {
+			<-slots
+			continue
+		}
+
+		ctx := r.Context.Clone()
+		ctx.SharedDeps = deps.ForSection()
+		waiter.Add(1)
+
+		go func(i int, iteration *rundown_ast.ForeachIteration) {
+			defer waiter.Done()
+			defer func() { <-slots }()
+
+			output := &bytes.Buffer{}
+			err := r.runIteration(output, source, iteration, ctx, true)
+			separateIteration(output, iteration)
+
+			// Dependencies the iteration didn't finish can be run by the others.
+			ctx.SharedDeps.Release()
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			results[i].Ran = true
+			results[i].Err = err
+			failed = failed || err != nil
+			finished++
+
+			mark := Aurora.Green(spinner.TICK)
+			if err != nil {
+				mark = Aurora.Red(spinner.CROSS)
+			}
+
+			theSpinner.Stop()
+			theSpinner.StampShadow()
+			w.WriteString(Aurora.Bold(fmt.Sprintf("%s %s\n\n", mark, iteration.Label)).String())
+			w.Write(output.Bytes())
+			w.Flush()
+			theSpinner.Progress(finished, len(iterations), "")
+			theSpinner.Start()
+		}(i, iteration)
+	}
+
+	waiter.Wait()
+
+	// Content after the foreach doesn't need to run the dependencies again.
+	for k, v := range deps.Completed() {
+		r.Context.DepsCompleted[k] = v
+	}
+
+	if failed {
+		theSpinner.Error("Failed")
+	} else {
+		theSpinner.Success("Complete")
+	}
+
+	return results
+}
+
+// Renders the iteration with its own copy of the context, followed by the finally blocks it reached.
+func (r *Renderer) runIteration(w io.Writer, source []byte, iteration *rundown_ast.ForeachIteration, ctx *rundown_renderer.Context, buffered bool) error {
+	ctx.ImportEnv(iteration.Env)
+
+	iterationRenderer := &Renderer{
+		Config:       r.Config,
+		blockStyles:  NewStyleStack(),
+		inlineStyles: NewStyleStack(),
+		currentLevel: r.currentLevel,
+		Context:      ctx,
+		buffered:     buffered,
+	}
+
+	gm := renderer.NewRenderer(renderer.WithNodeRenderers(util.Prioritized(iterationRenderer, 0)))
+
+	err := gm.Render(w, source, iteration)
+
+	for _, cleanup := range ctx.TakeCleanups() {
+		if cleanup.OnCancel && !ctx.Cancelled() {
+			continue
+		}
+
+		cleanup.Triggered = true
+
+		if cleanupErr := gm.Render(w, source, cleanup); cleanupErr != nil && err == nil {
+			err = cleanupErr
+		}
+	}
+
+	if errors.Is(err, errs.ErrStopOk) {
+		return nil
+	}
+
+	return err
+}
+
+// Leaves a blank line after the iteration's output. Paragraphs already end with one.
+func separateIteration(w io.Writer, iteration *rundown_ast.ForeachIteration) {
+	if _, ok := iteration.LastChild().(*ast.Paragraph); !ok {
+		io.WriteString(w, "\n")
+	}
+}
+
+func writeIterationSummary(w util.BufWriter, results []iterationResult) {
+	succeeded := 0
+	for _, result := range results {
+		if result.Ran && result.Err == nil {
+			succeeded++
+		}
+	}
+
+	w.WriteString(Aurora.Bold(fmt.Sprintf("%d of %d succeeded\n", succeeded, len(results))).String())
+
+	for _, result := range results {
+		switch {
+		case !result.Ran:
+			w.WriteString(fmt.Sprintf("  %s %s\n", Aurora.Faint(spinner.SKIP), Aurora.Faint(result.Label)))
+		case result.Err != nil:
+			w.WriteString(fmt.Sprintf("  %s %s\n", Aurora.Red(spinner.CROSS), result.Label))
+		default:
+			w.WriteString(fmt.Sprintf("  %s %s\n", Aurora.Green(spinner.TICK), result.Label))
+		}
+	}
+
+	w.WriteString("\n")
+	w.Flush()
+}
+
+// Returns the error the foreach fails with. Cancellation takes precedence, otherwise it's the first failure.
+func iterationsError(results []iterationResult) error {
+	var first error
+
+	for _, result := range results {
+		if errors.Is(result.Err, errs.ErrCancelled) {
+			return result.Err
+		}
+
+		if first == nil && result.Err != nil {
+			first = result.Err
+		}
+	}
+
+	return first
+}
@@ -0,0 +1,76 @@
+package term
+
+import (
+	"regexp"
+	"strconv"
+
+	rdutil "github.com/elseano/rundown/pkg/util"
+)
+
+// Scans each line of script output for progress, updating the spinner when found.
+//
+// With a single capture group, the match is a percentage. With two or more, the groups
+// are the current count, the total, and optionally a label.
+type ProgressScraper struct {
+	pattern *regexp.Regexp
+	spinner Spinner
+	line    []byte
+}
+
+func NewProgressScraper(pattern *regexp.Regexp, spinner Spinner) *ProgressScraper {
+	return &ProgressScraper{pattern: pattern, spinner: spinner}
+}
+
+func (p *ProgressScraper) Write(b []byte) (int, error) {
+	for _, c := range b {
+		// Progress output often redraws the line with a carriage return, so treat it as a line break.
+		if c == '\n' || c == '\r' {
+			p.scan()
+			p.line = p.line[:0]
+			continue
+		}
+
+		p.line = append(p.line, c)
+	}
+
+	return len(b), nil
+}
+
+// Scans any remaining partial line.
+func (p *ProgressScraper) Flush() error {
+	p.scan()
+	p.line = p.line[:0]
+
+	return nil
+}
+
+func (p *ProgressScraper) scan() {
+	if len(p.line) == 0 {
+		return
+	}
+
+	matches := p.pattern.FindStringSubmatch(rdutil.RemoveColors(string(p.line)))
+	if len(matches) < 2 {
+		return
+	}
+
+	if len(matches) == 2 {
+		if percent, err := strconv.ParseFloat(matches[1], 64); err == nil {
+			p.spinner.Progress(int(percent), 100, "")
+		}
+
+		return
+	}
+
+	current, errC := strconv.Atoi(matches[1])
+	total, errT := strconv.Atoi(matches[2])
+
+	label := ""
+	if len(matches) > 3 {
+		label = matches[3]
+	}
+
+	if errC == nil && errT == nil {
+		p.spinner.Progress(current, total, label)
+	}
+}
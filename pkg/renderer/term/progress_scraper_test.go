@@ -0,0 +1,42 @@
+package term
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Records the progress reported to it.
+type progressSpinner struct {
+	Spinner
+	reported []string
+}
+
+func (s *progressSpinner) Progress(current int, total int, label string) {
+	s.reported = append(s.reported, fmt.Sprintf("%d/%d %s", current, total, label))
+}
+
+func TestProgressScraperPercentages(t *testing.T) {
+	spinner := &progressSpinner{}
+	scraper := NewProgressScraper(regexp.MustCompile(`(\d+(?:\.\d+)?)%`), spinner)
+
+	// Redrawn lines, colours, lines without progress, and a final partial line.
+	scraper.Write([]byte("Downloading...\n 5%\r 42.5%\r\x1b[32m99%\x1b[0m\n"))
+	scraper.Write([]byte("done\n10"))
+	scraper.Write([]byte("0%"))
+	scraper.Flush()
+
+	assert.Equal(t, []string{"5/100 ", "42/100 ", "99/100 ", "100/100 "}, spinner.reported)
+}
+
+func TestProgressScraperCounts(t *testing.T) {
+	spinner := &progressSpinner{}
+	scraper := NewProgressScraper(regexp.MustCompile(`\[(\d+)/(\d+)\] (.*)`), spinner)
+
+	scraper.Write([]byte("[1/3] Compiling\n[2/3] Linking\nwarning: unused\n[3/3] Packaging\n"))
+	scraper.Flush()
+
+	assert.Equal(t, []string{"1/3 Compiling", "2/3 Linking", "3/3 Packaging"}, spinner.reported)
+}
@@ -0,0 +1,119 @@
+package term
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/elseano/rundown/pkg/exec"
+	"github.com/elseano/rundown/pkg/renderer"
+	rdutil "github.com/elseano/rundown/pkg/util"
+	"github.com/manifoldco/promptui"
+	"github.com/mattn/go-isatty"
+	"github.com/muesli/reflow/indent"
+	"github.com/yuin/goldmark/util"
+)
+
+var ErrNoTerminal = errors.New("no terminal available to ask the question")
+
+// Handles commands sent by scripts using the SDK protocol, see rpc.SDKHandler.
+type SDKHandler struct {
+	spinner Spinner
+	context *renderer.Context
+	out     util.BufWriter
+	lock    sync.Mutex
+
+	// Held while writing to out, as the script's stdout and stderr are written there too.
+	display *sync.Mutex
+}
+
+func NewSDKHandler(spinner Spinner, context *renderer.Context, out util.BufWriter, display *sync.Mutex) *SDKHandler {
+	return &SDKHandler{spinner: spinner, context: context, out: out, display: display}
+}
+
+func (h *SDKHandler) SetEnv(name string, value string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	rdutil.Logger.Debug().Msgf("SDK set environment %s = %s", name, value)
+	h.context.AddEnv(name, value)
+}
+
+func (h *SDKHandler) SetSpinner(title string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.spinner.NewStep(title)
+}
+
+func (h *SDKHandler) Progress(current int, total int, label string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.spinner.Progress(current, total, label)
+}
+
+func (h *SDKHandler) Log(message string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.display.Lock()
+	defer h.display.Unlock()
+
+	h.spinner.HideAndExecute(func() {
+		w := indent.NewWriterPipe(h.out, 4, nil)
+		fmt.Fprintln(w, message)
+		h.out.Flush()
+	})
+}
+
+func (h *SDKHandler) Prompt(label string) (string, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	var result string
+
+	err := h.ask(func(stdin *exec.StdinReader) (err error) {
+		prompt := promptui.Prompt{Label: label, Stdin: stdin.Claim()}
+		result, err = prompt.Run()
+		return err
+	})
+
+	return result, err
+}
+
+func (h *SDKHandler) Confirm(label string) (bool, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	err := h.ask(func(stdin *exec.StdinReader) error {
+		prompt := promptui.Prompt{Label: label, IsConfirm: true, Stdin: stdin.Claim()}
+		_, err := prompt.Run()
+		return err
+	})
+
+	if errors.Is(err, promptui.ErrAbort) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// Runs the question with the spinner hidden, provided there's a terminal to ask it on.
+func (h *SDKHandler) ask(question func(stdin *exec.StdinReader) error) error {
+	if !isatty.IsTerminal(os.Stdin.Fd()) {
+		return ErrNoTerminal
+	}
+
+	var err error
+
+	h.spinner.HideAndExecute(func() {
+		stdin := exec.NewStdinReader()
+		defer stdin.Stop()
+
+		err = question(stdin)
+	})
+
+	return err
+}
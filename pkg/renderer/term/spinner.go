@@ -14,6 +14,7 @@ type Spinner interface {
 	Skip()
 	SetMessage(message string)
 	NewStep(message string)
+	Progress(current int, total int, label string)
 	HideAndExecute(f func())
 	CurrentHeading() string
 	StampShadow()
@@ -16,6 +16,7 @@ type CISpinner struct {
 	startedAt        time.Time
 	substepStartedAt time.Time
 	colors           aurora.Aurora
+	progress         progressTracker
 }
 
 func NewCISpinner(out io.Writer, colors aurora.Aurora) *CISpinner {
@@ -82,10 +83,20 @@ func (s *CISpinner) NewStep(message string) {
 
 	s.substep = message
 	s.substepStartedAt = time.Now()
+	s.progress = progressTracker{}
 }
 
-func (s *CISpinner) HideAndExecute(f func()) {
+// Prints the progress as a line, at most once per ProgressLineInterval.
+func (s *CISpinner) Progress(current int, total int, label string) {
+	s.progress.update(current, total, label)
 
+	if s.progress.shouldPrint() {
+		s.out.Write([]byte(s.colors.Faint(fmt.Sprintf("    %s\n", s.progress.summary())).String()))
+	}
+}
+
+func (s *CISpinner) HideAndExecute(f func()) {
+	f()
 }
 
 func (s *CISpinner) CurrentHeading() string {
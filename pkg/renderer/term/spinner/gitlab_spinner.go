@@ -21,6 +21,7 @@ type GitlabSpinner struct {
 	sectionPrefix  string
 	sectionCounter int
 	colors         aurora.Aurora
+	progress       progressTracker
 }
 
 func NewGitlabSpinner(out io.Writer, colors aurora.Aurora) *GitlabSpinner {
@@ -81,8 +82,17 @@ func (s *GitlabSpinner) NewStep(message string) {
 	s.openSection(message)
 }
 
-func (s *GitlabSpinner) HideAndExecute(f func()) {
+// Prints the progress as a line, at most once per ProgressLineInterval.
+func (s *GitlabSpinner) Progress(current int, total int, label string) {
+	s.progress.update(current, total, label)
 
+	if s.progress.shouldPrint() {
+		s.out.Write([]byte(s.colors.Faint(fmt.Sprintf("    %s\n", s.progress.summary())).String()))
+	}
+}
+
+func (s *GitlabSpinner) HideAndExecute(f func()) {
+	f()
 }
 
 func (s *GitlabSpinner) CurrentHeading() string {
@@ -33,8 +33,11 @@ func (s *NullSpinner) SetMessage(message string) {
 func (s *NullSpinner) NewStep(message string) {
 }
 
-func (s *NullSpinner) HideAndExecute(f func()) {
+func (s *NullSpinner) Progress(current int, total int, label string) {
+}
 
+func (s *NullSpinner) HideAndExecute(f func()) {
+	f()
 }
 
 func (s *NullSpinner) CurrentHeading() string {
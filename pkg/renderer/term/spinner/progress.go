@@ -0,0 +1,100 @@
+package spinner
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const progressBarWidth = 20
+
+// How often spinners which print lines, rather than redrawing, report progress.
+var ProgressLineInterval = 10 * time.Second
+
+// Tracks the progress of a long running script, to estimate when it'll finish.
+type progressTracker struct {
+	startedAt   time.Time
+	startedFrom int
+	current     int
+	total       int
+	label       string
+	lastPrinted time.Time
+	lastPercent int
+}
+
+func (p *progressTracker) update(current int, total int, label string) {
+	if p.startedAt.IsZero() || current < p.current {
+		p.startedAt = time.Now()
+		p.startedFrom = current
+	}
+
+	p.current = current
+	p.total = total
+	p.label = label
+}
+
+func (p *progressTracker) percent() int {
+	if p.total <= 0 {
+		return 0
+	}
+
+	percent := p.current * 100 / p.total
+	if percent > 100 {
+		return 100
+	}
+
+	return percent
+}
+
+// Estimates the time remaining, assuming progress continues at the same rate as since the first update.
+func (p *progressTracker) eta() (time.Duration, bool) {
+	done := p.current - p.startedFrom
+	if done <= 0 || p.current >= p.total {
+		return 0, false
+	}
+
+	elapsed := time.Since(p.startedAt)
+	remaining := time.Duration(float64(elapsed) / float64(done) * float64(p.total-p.current)).Round(time.Second)
+
+	return remaining, remaining > 0
+}
+
+// Renders the progress as a bar, i.e. "[██████░░░░░░] 42% Copying (ETA 1m20s)".
+func (p *progressTracker) bar() string {
+	filled := p.percent() * progressBarWidth / 100
+
+	return "[" + strings.Repeat("█", filled) + strings.Repeat("░", progressBarWidth-filled) + "] " + p.summary()
+}
+
+// Renders the progress as text, i.e. "42% Copying (ETA 1m20s)".
+func (p *progressTracker) summary() string {
+	result := fmt.Sprintf("%d%%", p.percent())
+
+	if p.label != "" {
+		result += " " + p.label
+	}
+
+	if eta, ok := p.eta(); ok {
+		result += fmt.Sprintf(" (ETA %s)", eta)
+	}
+
+	return result
+}
+
+// Returns true when enough time has passed to print another progress line, or the progress is complete.
+func (p *progressTracker) shouldPrint() bool {
+	percent := p.percent()
+
+	if percent == p.lastPercent {
+		return false
+	}
+
+	if percent < 100 && time.Since(p.lastPrinted) < ProgressLineInterval {
+		return false
+	}
+
+	p.lastPrinted = time.Now()
+	p.lastPercent = percent
+
+	return true
+}
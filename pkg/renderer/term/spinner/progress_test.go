@@ -0,0 +1,36 @@
+package spinner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgressTracker(t *testing.T) {
+	p := progressTracker{}
+
+	p.update(10, 100, "rows")
+	require.Equal(t, "[██░░░░░░░░░░░░░░░░░░] 10% rows", p.bar())
+
+	// Half way there after 10 seconds, so another 10 to go.
+	p.startedAt = time.Now().Add(-10 * time.Second)
+	p.update(55, 100, "rows")
+	require.Equal(t, "55% rows (ETA 10s)", p.summary())
+
+	p.update(120, 100, "")
+	require.Equal(t, "100%", p.summary())
+}
+
+func TestProgressTrackerThrottles(t *testing.T) {
+	p := progressTracker{}
+
+	p.update(1, 10, "")
+	require.True(t, p.shouldPrint())
+
+	p.update(2, 10, "")
+	require.False(t, p.shouldPrint())
+
+	p.update(10, 10, "")
+	require.True(t, p.shouldPrint())
+}
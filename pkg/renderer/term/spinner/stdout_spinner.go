@@ -17,6 +17,7 @@ type StdoutSpinner struct {
 	out            io.Writer
 	lastStampTitle string
 	startedAt      time.Time
+	progress       progressTracker
 }
 
 const (
@@ -140,6 +141,7 @@ func (s *StdoutSpinner) NewStep(message string) {
 	s.substep = message
 	s.s = sp
 	s.startedAt = time.Time{}
+	s.progress = progressTracker{}
 
 	util.Logger.Debug().Msgf("Done")
 
@@ -157,6 +159,25 @@ func (s *StdoutSpinner) SetMessage(message string) {
 	}
 }
 
+// Shows a progress bar after the spinner's title.
+func (s *StdoutSpinner) Progress(current int, total int, label string) {
+	s.progress.update(current, total, label)
+
+	title := s.message
+	if s.substep != "" {
+		title = s.substep
+	}
+
+	// The spinner's goroutine reads the suffix as it animates.
+	s.s.Lock()
+	s.s.Suffix = " " + title + " " + s.colorMode.Faint(s.progress.bar()).String()
+	s.s.Unlock()
+
+	if s.s.Active() {
+		s.s.Repaint()
+	}
+}
+
 func (s *StdoutSpinner) HideAndExecute(f func()) {
 	s.s.HideAndExecute(f)
 }
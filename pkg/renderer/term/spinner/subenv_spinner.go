@@ -13,6 +13,7 @@ type wrappableSpinner interface {
 	Skip()
 	SetMessage(message string)
 	NewStep(message string)
+	Progress(current int, total int, label string)
 	HideAndExecute(f func())
 	CurrentHeading() string
 	StampShadow()
@@ -67,6 +68,10 @@ func (s *SubenvSpinner) NewStep(message string) {
 	s.spinner.NewStep(message)
 }
 
+func (s *SubenvSpinner) Progress(current int, total int, label string) {
+	s.spinner.Progress(current, total, s.SubEnv(label))
+}
+
 func (s *SubenvSpinner) HideAndExecute(f func()) {
 	s.spinner.HideAndExecute(f)
 }
@@ -0,0 +1,59 @@
+package term
+
+import (
+	"io"
+	"sync"
+)
+
+// Colours everything written to it, so STDERR stands out from STDOUT.
+type StderrWriter struct {
+	writer io.Writer
+}
+
+func NewStderrWriter(w io.Writer) *StderrWriter {
+	return &StderrWriter{writer: w}
+}
+
+func (s *StderrWriter) Write(b []byte) (int, error) {
+	if _, err := io.WriteString(s.writer, Aurora.Yellow(string(b)).String()); err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+func (s *StderrWriter) Flush() error {
+	if flusher, ok := s.writer.(interface{ Flush() error }); ok {
+		return flusher.Flush()
+	}
+
+	return nil
+}
+
+// Serialises writes to a shared destination, so STDOUT and STDERR don't interleave mid-write.
+type LockedWriter struct {
+	writer io.Writer
+	lock   *sync.Mutex
+}
+
+func NewLockedWriter(lock *sync.Mutex, w io.Writer) *LockedWriter {
+	return &LockedWriter{writer: w, lock: lock}
+}
+
+func (l *LockedWriter) Write(b []byte) (int, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.writer.Write(b)
+}
+
+func (l *LockedWriter) Flush() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if flusher, ok := l.writer.(interface{ Flush() error }); ok {
+		return flusher.Flush()
+	}
+
+	return nil
+}
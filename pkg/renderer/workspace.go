@@ -0,0 +1,167 @@
+package renderer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// The environment variable holding the workspace directory, available to every script.
+const WorkspaceEnvName = "RUNDOWN_WORKSPACE"
+
+// The manifest listing collected artifacts, inside the artifacts directory.
+const ArtifactManifestName = "manifest.json"
+
+// A directory holding everything a run creates: its scripts, saved files, captured outputs and artifacts.
+// Removed once the run finishes, unless --keep-workspace is given.
+type Workspace struct {
+	Dir       string
+	Artifacts []Artifact
+
+	lock sync.Mutex
+}
+
+// A file collected from the artifacts attribute.
+type Artifact struct {
+	Name   string `json:"name"`            // Path inside the artifacts directory.
+	Source string `json:"source"`          // Where the file was copied from.
+	Size   int64  `json:"size"`            // Size in bytes.
+	SHA256 string `json:"sha256"`          // Hex encoded checksum of the contents.
+	Block  string `json:"block,omitempty"` // The code block which produced it, as file:line.
+}
+
+func NewWorkspace() (*Workspace, error) {
+	dir, err := os.MkdirTemp("", "rundown-*")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Workspace{Dir: dir}, nil
+}
+
+// Returns the named directory inside the workspace, creating it if needed.
+func (w *Workspace) Subdir(name string) (string, error) {
+	dir := filepath.Join(w.Dir, name)
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	return dir, nil
+}
+
+// Writes a captured output into the outputs directory, named after the variable it was captured into.
+func (w *Workspace) SaveOutput(name string, contents string) error {
+	dir, err := w.Subdir("outputs")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(dir, name), []byte(contents), 0600)
+}
+
+// Copies files matching the comma separated glob patterns into the artifacts directory, keeping their path
+// relative to baseDir, and records them in the manifest. Returns the artifacts collected.
+func (w *Workspace) CollectArtifacts(patterns string, baseDir string, block string) ([]Artifact, error) {
+	// Sections running in parallel share the workspace, so collect one set of artifacts at a time.
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	dir, err := w.Subdir("artifacts")
+	if err != nil {
+		return nil, err
+	}
+
+	collected := []Artifact{}
+
+	for _, pattern := range strings.Split(patterns, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(baseDir, pattern)
+		}
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid artifacts pattern %q: %w", pattern, err)
+		}
+
+		for _, match := range matches {
+			if info, err := os.Stat(match); err != nil || info.IsDir() {
+				continue
+			}
+
+			name, err := filepath.Rel(baseDir, match)
+			if err != nil || strings.HasPrefix(name, "..") {
+				name = filepath.Base(match)
+			}
+
+			artifact, err := copyArtifact(match, filepath.Join(dir, name))
+			if err != nil {
+				return nil, err
+			}
+
+			artifact.Name = name
+			artifact.Block = block
+
+			collected = append(collected, artifact)
+		}
+	}
+
+	w.Artifacts = append(w.Artifacts, collected...)
+
+	return collected, w.writeManifest(dir)
+}
+
+func (w *Workspace) writeManifest(dir string) error {
+	manifest, err := json.MarshalIndent(w.Artifacts, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(dir, ArtifactManifestName), manifest, 0600)
+}
+
+func copyArtifact(from string, to string) (Artifact, error) {
+	artifact := Artifact{Source: from}
+	if abs, err := filepath.Abs(from); err == nil {
+		artifact.Source = abs
+	}
+
+	if err := os.MkdirAll(filepath.Dir(to), 0700); err != nil {
+		return artifact, err
+	}
+
+	source, err := os.Open(from)
+	if err != nil {
+		return artifact, err
+	}
+	defer source.Close()
+
+	dest, err := os.Create(to)
+	if err != nil {
+		return artifact, err
+	}
+	defer dest.Close()
+
+	hash := sha256.New()
+
+	artifact.Size, err = io.Copy(io.MultiWriter(dest, hash), source)
+	artifact.SHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	return artifact, err
+}
+
+// Removes the workspace and everything in it.
+func (w *Workspace) Remove() error {
+	return os.RemoveAll(w.Dir)
+}
@@ -0,0 +1,66 @@
+package renderer
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectArtifacts(t *testing.T) {
+	baseDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(baseDir, "dist"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(baseDir, "dist", "app.tgz"), []byte("app"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(baseDir, "dist", "notes.txt"), []byte("notes"), 0600))
+
+	workspace, err := NewWorkspace()
+	require.NoError(t, err)
+	defer workspace.Remove()
+
+	artifacts, err := workspace.CollectArtifacts("dist/*.tgz, missing/*", baseDir, "RUNDOWN.md:3")
+	require.NoError(t, err)
+
+	if assert.Len(t, artifacts, 1) {
+		assert.Equal(t, "dist/app.tgz", artifacts[0].Name)
+		assert.Equal(t, int64(3), artifacts[0].Size)
+		assert.Equal(t, "RUNDOWN.md:3", artifacts[0].Block)
+	}
+
+	copied, err := os.ReadFile(filepath.Join(workspace.Dir, "artifacts", "dist", "app.tgz"))
+	require.NoError(t, err)
+	assert.Equal(t, "app", string(copied))
+
+	manifestData, err := os.ReadFile(filepath.Join(workspace.Dir, "artifacts", ArtifactManifestName))
+	require.NoError(t, err)
+
+	manifest := []Artifact{}
+	require.NoError(t, json.Unmarshal(manifestData, &manifest))
+	assert.Equal(t, artifacts, manifest)
+}
+
+func TestWorkspaceRemovedUnlessKept(t *testing.T) {
+	context := NewContext("RUNDOWN.md")
+
+	workspace, err := context.Workspace()
+	require.NoError(t, err)
+	assert.Equal(t, workspace.Dir, context.Env[WorkspaceEnvName])
+
+	kept, err := context.CloseWorkspace()
+	require.NoError(t, err)
+	assert.Empty(t, kept)
+	assert.NoDirExists(t, workspace.Dir)
+
+	context.KeepWorkspace = true
+
+	workspace, err = context.Workspace()
+	require.NoError(t, err)
+	defer workspace.Remove()
+
+	kept, err = context.CloseWorkspace()
+	require.NoError(t, err)
+	assert.Equal(t, workspace.Dir, kept)
+	assert.DirExists(t, workspace.Dir)
+}
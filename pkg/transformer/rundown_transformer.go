@@ -1,10 +1,16 @@
 package transformer
 
 import (
+	"errors"
 	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/elseano/rundown/pkg/ast"
+	"github.com/elseano/rundown/pkg/condition"
 	"github.com/elseano/rundown/pkg/util"
 	"gopkg.in/guregu/null.v4"
 
@@ -25,13 +31,15 @@ type NodeProcessor interface {
 }
 
 type rundownASTTransformer struct {
-	Errors []error
+	Errors      []error
+	FrontMatter *ast.FrontMatter
+	Filename    string
 }
 
 // Rundown AST Transformer converts Rundown Elements in the markdown tree
 // into proper rundown nodes, and applies any effects.
 func NewRundownASTTransformer() *rundownASTTransformer {
-	return &rundownASTTransformer{Errors: []error{}}
+	return &rundownASTTransformer{Errors: []error{}, FrontMatter: &ast.FrontMatter{}}
 }
 
 type OpenTags struct {
@@ -125,7 +133,46 @@ func (a *rundownASTTransformer) Transform(doc *goldast.Document, reader goldtext
 	createRundownBlocks(doc, reader, pc)
 	mergeTextBlocks(doc, reader, pc)
 	a.convertRundownBlocks(doc, reader, pc)
+	a.insertFrontMatterRequires(doc)
 	ast.PopulateSkipTargets(doc)
+	a.checkConditions(doc)
+}
+
+// Reports conditions which are expressions, but invalid ones, such as calling an unknown function.
+// Shell script conditions can't be checked until they run.
+func (a *rundownASTTransformer) checkConditions(doc *goldast.Document) {
+	checked := map[string]bool{}
+
+	goldast.Walk(doc, func(node goldast.Node, entering bool) (goldast.WalkStatus, error) {
+		if conditional, ok := node.(ast.Conditional); ok && entering && conditional.HasIfScript() {
+			script := conditional.GetIfScript()
+
+			if !checked[script] {
+				checked[script] = true
+
+				if _, err := condition.Parse(script); err != nil && !errors.Is(err, condition.ErrNotExpression) {
+					a.Errors = append(a.Errors, fmt.Errorf("invalid condition %q: %w", script, err))
+				}
+			}
+		}
+
+		return goldast.WalkContinue, nil
+	})
+}
+
+// Requirements declared in the front matter apply to the whole document.
+func (a *rundownASTTransformer) insertFrontMatterRequires(doc *goldast.Document) {
+	if a.FrontMatter == nil || len(a.FrontMatter.Requires) == 0 {
+		return
+	}
+
+	requirements, err := ast.ParseRequirements(strings.Join(a.FrontMatter.Requires, ","))
+	if err != nil {
+		a.Errors = append(a.Errors, err)
+		return
+	}
+
+	doc.InsertBefore(doc, doc.FirstChild(), ast.NewRequires(requirements))
 }
 
 // Merges sequential text nodes into a single text block. This makes subsequent processing easier.
@@ -170,6 +217,19 @@ func (a *rundownASTTransformer) convertRundownBlocks(doc goldast.Node, reader go
 				a.Errors = append(a.Errors, err)
 			}
 
+			// Option values are listed like the document's scripts, in its working directory and with its environment.
+			if opt, ok := node.(*ast.SectionOption); ok {
+				if valuesFrom, ok := opt.OptionType.(*ast.TypeEnumFrom); ok {
+					a.applyValuesFrom(valuesFrom, opt)
+				}
+			}
+
+			if executionBlock, ok := node.(*ast.ExecutionBlock); ok {
+				a.applyFrontMatter(executionBlock, n)
+				a.applyWorkingDirectory(executionBlock)
+				executionBlock.Source = ast.CodeBlockPosition(a.Filename, executionBlock.CodeBlock, reader.Source())
+			}
+
 			util.Logger.Debug().Msgf("AST is now: \n%s", util.CaptureStdout(func() {
 				doc.Dump(reader.Source(), 0)
 			}))
@@ -209,6 +269,7 @@ func (a *rundownASTTransformer) convertRundownBlocks(doc goldast.Node, reader go
 				util.Logger.Trace().Msgf("Found section end\n")
 				ast.FillInvokeBlocks(section, 10)
 				PopulateSectionMetadata(section, reader)
+				a.Errors = append(a.Errors, checkOptionReferences(section)...)
 			}
 		}
 
@@ -218,6 +279,77 @@ func (a *rundownASTTransformer) convertRundownBlocks(doc goldast.Node, reader go
 	util.Logger.Trace().Msgf("Sections populated\n")
 }
 
+// Applies the document's front matter defaults to settings the block didn't specify itself.
+func (a *rundownASTTransformer) applyFrontMatter(executionBlock *ast.ExecutionBlock, node *ast.RundownBlock) {
+	fm := a.FrontMatter
+	if fm == nil {
+		return
+	}
+
+	if executionBlock.Language == "" && fm.Shell != "" {
+		executionBlock.Language = fm.Shell
+
+		if !node.HasAttr("with") {
+			executionBlock.With = fm.Shell
+
+			if fm.Interpreter != "" {
+				executionBlock.With = fm.Interpreter
+			}
+		}
+	}
+
+	if !node.HasAttr("stdout") {
+		executionBlock.ShowStdout = fm.Stdout
+	}
+
+	if mode, ok := fm.SpinnerMode(); ok && !node.HasAttr("spinner", "nospin", "named", "sub-spinners", "named-all") {
+		executionBlock.SpinnerMode = mode
+	}
+}
+
+// Blocks without a cwd inherit one from the closest section which has one, or from the front matter.
+func (a *rundownASTTransformer) applyWorkingDirectory(executionBlock *ast.ExecutionBlock) {
+	if executionBlock.Cwd != "" {
+		return
+	}
+
+	executionBlock.Cwd = a.inheritedCwd(executionBlock)
+}
+
+// Returns the cwd of the closest section around the node which has one, or the front matter's.
+func (a *rundownASTTransformer) inheritedCwd(node goldast.Node) string {
+	for section := ast.GetSectionForNode(node.Parent()); section != nil; section = ast.GetSectionForNode(section.Parent()) {
+		if section.Cwd != "" {
+			return section.Cwd
+		}
+	}
+
+	if a.FrontMatter != nil {
+		return a.FrontMatter.Cwd
+	}
+
+	return ""
+}
+
+// Runs the option's values command where the document's blocks run, with the document's environment defaults,
+// and identifies the option so its values can be cached between runs.
+func (a *rundownASTTransformer) applyValuesFrom(valuesFrom *ast.TypeEnumFrom, opt *ast.SectionOption) {
+	valuesFrom.Option = opt.OptionName
+	valuesFrom.Cwd = a.inheritedCwd(opt)
+
+	if a.FrontMatter != nil {
+		valuesFrom.Env = a.FrontMatter.Env
+	}
+
+	if a.Filename != "" {
+		valuesFrom.Dir = filepath.Dir(a.Filename)
+
+		if document, err := filepath.Abs(a.Filename); err == nil {
+			valuesFrom.Document = document
+		}
+	}
+}
+
 // Converts a RundownBlock into a proper instruction node. Returns the node to continue iterating from, or an error.
 func ConvertToRundownNode(node *ast.RundownBlock, reader goldtext.Reader) (goldast.Node, error) {
 	var nodeToReplace goldast.Node = node
@@ -248,6 +380,28 @@ func ConvertToRundownNode(node *ast.RundownBlock, reader goldtext.Reader) (golda
 		return importBlock, nil
 	}
 
+	if node.HasAttr("default-section") {
+		defaultSection := ast.NewDefaultSection(node.GetAttr("default-section").String)
+
+		Replace(nodeToReplace, defaultSection)
+
+		return defaultSection, nil
+	}
+
+	// On an option, requires names other options instead.
+	if node.HasAttr("requires") && !node.HasAttr("opt") {
+		requirements, err := ast.ParseRequirements(node.GetAttr("requires").String)
+		if err != nil {
+			return node, err
+		}
+
+		requires := ast.NewRequires(requirements)
+
+		Replace(nodeToReplace, requires)
+
+		return requires, nil
+	}
+
 	if node.HasAttr("skip") {
 		skipBlock := ast.NewSkipBlock()
 		if ifScript := node.GetAttr("if"); ifScript.Valid {
@@ -260,6 +414,10 @@ func ConvertToRundownNode(node *ast.RundownBlock, reader goldtext.Reader) (golda
 	}
 
 	if node.HasAttr("label", "section") {
+		if node.HasAttr("else", "else-if") {
+			return node, fmt.Errorf("sections can't be else branches, use else on a heading without a section instead")
+		}
+
 		name := node.GetAttr("section")
 		if !name.Valid {
 			name = node.GetAttr("label")
@@ -267,6 +425,7 @@ func ConvertToRundownNode(node *ast.RundownBlock, reader goldtext.Reader) (golda
 
 		start := ast.NewSectionPointer(name.String)
 		start.Silent = node.HasAttr("silent")
+		start.Cwd = node.GetAttr("cwd").String
 
 		if name.Valid {
 			if heading, ok := parentNode.(*goldast.Heading); ok {
@@ -299,6 +458,18 @@ func ConvertToRundownNode(node *ast.RundownBlock, reader goldtext.Reader) (golda
 					child = nextChild
 				}
 
+				if node.HasAttr("dangerous") {
+					prompt := node.GetAttr("dangerous").String
+					if prompt == "" {
+						prompt = fmt.Sprintf("%s is marked as dangerous. Continue?", start.DescriptionShort)
+					}
+
+					confirm := ast.NewConfirm(prompt)
+					confirm.Expect = node.GetAttr("expect").String
+
+					start.InsertAfter(start, heading, confirm)
+				}
+
 				Remove(node, reader)
 
 				return start, nil
@@ -309,9 +480,18 @@ func ConvertToRundownNode(node *ast.RundownBlock, reader goldtext.Reader) (golda
 	} else if h, ok := node.Parent().(*goldast.Heading); ok {
 		// Check if there's a conditional...
 
-		if ifScript := node.GetAttr("if"); ifScript.Valid {
+		if node.HasAttr("if", "else", "else-if") {
 			cond := ast.NewConditionalStart()
-			cond.SetIfScript(ifScript.String)
+
+			if node.HasAttr("else", "else-if") {
+				if cond.Else = previousHeadingBranch(h); cond.Else == nil {
+					return node, fmt.Errorf("%s on heading %q doesn't follow a heading at the same level with if or else-if", elseAttr(node), strings.TrimSpace(string(h.Text(reader.Source()))))
+				}
+			}
+
+			if err := setBranchScript(cond, node); err != nil {
+				return node, err
+			}
 
 			h.Parent().InsertBefore(h.Parent(), h, cond)
 
@@ -423,6 +603,18 @@ func ConvertToRundownNode(node *ast.RundownBlock, reader goldtext.Reader) (golda
 			opt.OptionAs = strings.ToUpper(as.String)
 		}
 
+		opt.Requires = strings.FieldsFunc(node.GetAttr("requires").String, isOptionListSeparator)
+		opt.Conflicts = strings.FieldsFunc(node.GetAttr("conflicts").String, isOptionListSeparator)
+		opt.Group = node.GetAttr("group").String
+
+		if requiredIf := node.GetAttr("required-if"); requiredIf.Valid {
+			if _, err := condition.Parse(requiredIf.String); err != nil {
+				return node, fmt.Errorf("required-if for option `%s` must be an expression, such as $OPT_MODE == 'restore': %w", opt.OptionName, err)
+			}
+
+			opt.RequiredIf = requiredIf.String
+		}
+
 		var err error
 
 		if node.HasAttr("type") {
@@ -431,6 +623,18 @@ func ConvertToRundownNode(node *ast.RundownBlock, reader goldtext.Reader) (golda
 			opt.OptionType, err = ast.BuildOptionType("string")
 		}
 
+		if valuesFrom := node.GetAttr("values-from"); err == nil && valuesFrom.Valid {
+			if _, ok := opt.OptionType.(*ast.TypeString); !ok {
+				err = fmt.Errorf("values-from can only be used with string options")
+			} else {
+				opt.OptionType = &ast.TypeEnumFrom{Command: valuesFrom.String}
+			}
+		}
+
+		if err == nil {
+			err = applyOptionConstraints(opt.OptionType, node)
+		}
+
 		if err != nil {
 			return node, fmt.Errorf("error for option `%s`: %w", opt.OptionName, err)
 		}
@@ -440,8 +644,14 @@ func ConvertToRundownNode(node *ast.RundownBlock, reader goldtext.Reader) (golda
 		if node.HasAttr("default") {
 			defaultVal := node.GetAttr("default")
 			if defaultVal.Valid {
-				if opt.OptionType.Validate(defaultVal.String) == nil {
-					def := opt.OptionType.Normalise(defaultVal.String)
+				def := opt.OptionType.Normalise(defaultVal.String)
+
+				// Files are checked when the option is used, as they're relative to where Rundown is run from.
+				// Listed values are too, so their command doesn't run when the document loads.
+				_, isFile := opt.OptionType.(ast.OptionTypeRuntime)
+				_, isListed := opt.OptionType.(*ast.TypeEnumFrom)
+
+				if isFile || isListed || opt.OptionType.Validate(def) == nil {
 					opt.OptionDefault = null.StringFrom(def)
 				} else {
 					return node, fmt.Errorf("default option type \"%s\" is invalid for option \"%s\"", defaultVal.String, opt.OptionName)
@@ -478,6 +688,26 @@ func ConvertToRundownNode(node *ast.RundownBlock, reader goldtext.Reader) (golda
 		return helpNode, nil
 	}
 
+	if node.HasAttr("foreach") {
+		foreach := ast.NewForeach()
+		foreach.Axes = []ast.ForeachAxis{{Name: node.GetAttr("foreach").String, Values: node.GetAttr("in").String}}
+
+		if !foreachVariable.MatchString(foreach.Axes[0].Name) {
+			return node, fmt.Errorf("foreach variable %q is invalid", foreach.Axes[0].Name)
+		}
+
+		if err := applyForeachPolicy(foreach, node); err != nil {
+			return node, err
+		}
+
+		if ifScript := node.GetAttr("if"); ifScript.Valid {
+			foreach.SetIfScript(ifScript.String)
+		}
+
+		ReplaceWithChildren(nodeToReplace, foreach, node)
+		return foreach, nil
+	}
+
 	if node.HasAttr("dep", "invoke") {
 		invoke := ast.NewInvokeBlock()
 
@@ -489,14 +719,54 @@ func ConvertToRundownNode(node *ast.RundownBlock, reader goldtext.Reader) (golda
 		}
 
 		for _, attr := range node.Attrs {
+			switch attr.Key {
+			case "matrix", "parallel", "fail-fast":
+				continue
+			}
+
 			invoke.Args[attr.Key] = attr.Val
 		}
 
+		if matrix := node.GetAttr("matrix"); matrix.Valid {
+			axes, err := ast.ParseMatrix(matrix.String)
+			if err != nil {
+				return node, err
+			}
+
+			foreach := ast.NewForeach()
+			foreach.Axes = axes
+			foreach.Matrix = true
+
+			if err := applyForeachPolicy(foreach, node); err != nil {
+				return node, err
+			}
+
+			foreach.AppendChild(foreach, invoke)
+			Replace(nodeToReplace, foreach)
+
+			return foreach, nil
+		}
+
 		Replace(nodeToReplace, invoke)
 
 		return invoke, nil
 	}
 
+	if node.HasAttr("confirm") {
+		confirm := ast.NewConfirm(node.GetAttr("confirm").String)
+		confirm.Expect = node.GetAttr("expect").String
+
+		ReplaceWithChildren(nodeToReplace, confirm, node)
+		return confirm, nil
+	}
+
+	if node.HasAttr("finally", "on-cancel") {
+		cleanup := ast.NewCleanup(node.HasAttr("on-cancel"))
+
+		ReplaceWithChildren(nodeToReplace, cleanup, node)
+		return cleanup, nil
+	}
+
 	if node.HasAttr("stop-fail") {
 		stop := ast.NewStopFail()
 
@@ -539,16 +809,31 @@ func ConvertToRundownNode(node *ast.RundownBlock, reader goldtext.Reader) (golda
 		fail := ast.NewOnFailure()
 		fail.FailureMessageRegexp = node.GetAttr("on-failure").String
 
+		if stream := node.GetAttr("stream"); stream.Valid {
+			switch ast.FailureStream(stream.String) {
+			case ast.FailureStreamBoth, ast.FailureStreamStdout, ast.FailureStreamStderr:
+				fail.Stream = ast.FailureStream(stream.String)
+			default:
+				return node, fmt.Errorf("invalid on-failure stream %q, expected stdout, stderr or both", stream.String)
+			}
+		}
+
 		ReplaceWithChildren(nodeToReplace, fail, node)
 
 		return fail, nil
 	}
 
-	if fcb, ok := nextNode.(*goldast.FencedCodeBlock); ok && node.HasAttr("if", "with", "spinner", "stdout", "subenv", "sub-env", "capture-env", "replace", "borg", "reveal", "reveal-only", "skip-on-success") {
+	if node.HasAttr("if", "else", "else-if") && node.HasChildren() {
+		return convertBranchBlock(nodeToReplace, node)
+	}
+
+	if fcb, ok := nextNode.(*goldast.FencedCodeBlock); ok && node.HasAttr("if", "with", "spinner", "stdout", "subenv", "sub-env", "capture-env", "replace", "borg", "reveal", "reveal-only", "skip-on-success", "progress-from", "stderr", "stdout-into", "stderr-into", "artifacts", "cwd") {
 		executionBlock := ast.NewExecutionBlock(fcb)
 
 		executionBlock.CaptureStdoutInto = node.GetAttr("stdout-into").String
-		executionBlock.ShowStdout = node.HasAttr("stdout")
+		executionBlock.CaptureStderrInto = node.GetAttr("stderr-into").String
+		executionBlock.Cwd = node.GetAttr("cwd").String
+		executionBlock.ShowStdout = node.HasAttr("stdout") && node.GetAttr("stdout").String != "false"
 		executionBlock.ShowStderr = node.HasAttr("stderr")
 		executionBlock.Reveal = node.HasAttr("reveal", "reveal-only")
 		executionBlock.Execute = !node.HasAttr("reveal-only", "norun")
@@ -556,12 +841,32 @@ func ConvertToRundownNode(node *ast.RundownBlock, reader goldtext.Reader) (golda
 		executionBlock.ReplaceProcess = node.HasAttr("borg")
 		executionBlock.SkipOnSuccess = node.HasAttr("skip-on-success")
 		executionBlock.SkipOnFailure = node.HasAttr("skip-on-failure")
-		executionBlock.Language = string(fcb.Info.Text(reader.Source()))
+		if fcb.Info != nil {
+			executionBlock.Language = string(fcb.Info.Text(reader.Source()))
+		}
 
 		if ifScript := node.GetAttr("if"); ifScript.Valid {
 			executionBlock.SetIfScript(ifScript.String)
 		}
 
+		if progressFrom := node.GetAttr("progress-from"); progressFrom.Valid {
+			if _, err := regexp.Compile(progressFrom.String); err != nil {
+				return node, fmt.Errorf("invalid progress-from: %w", err)
+			}
+
+			executionBlock.ProgressFrom = progressFrom.String
+		}
+
+		if artifacts := node.GetAttr("artifacts"); artifacts.Valid {
+			for _, pattern := range strings.Split(artifacts.String, ",") {
+				if _, err := filepath.Match(strings.TrimSpace(pattern), ""); err != nil {
+					return node, fmt.Errorf("invalid artifacts pattern %q: %w", pattern, err)
+				}
+			}
+
+			executionBlock.Artifacts = artifacts.String
+		}
+
 		if envCapture := node.GetAttr("capture-env"); envCapture.Valid {
 			executionBlock.CaptureEnvironment = strings.Split(envCapture.String, ",")
 
@@ -639,3 +944,204 @@ func ConvertToRundownNode(node *ast.RundownBlock, reader goldtext.Reader) (golda
 
 	return node, nil
 }
+
+// Wraps the children of an if, else-if or else block in a conditional. An <r else/> or <r else-if="..."/>
+// among the children of an if block starts the next branch, so <r if="...">A<r else/>B</r> works as expected.
+func convertBranchBlock(nodeToReplace goldast.Node, node *ast.RundownBlock) (goldast.Node, error) {
+	start := ast.NewConditionalStart()
+
+	if node.HasAttr("else", "else-if") {
+		end, ok := nodeToReplace.PreviousSibling().(*ast.ConditionalEnd)
+		if !ok {
+			return node, fmt.Errorf("%s must directly follow an if or else-if block", elseAttr(node))
+		}
+
+		start.Else = end.Start
+	}
+
+	if err := setBranchScript(start, node); err != nil {
+		return node, err
+	}
+
+	parent := nodeToReplace.Parent()
+	parent.InsertBefore(parent, nodeToReplace, start)
+
+	branch := start
+
+	for child := node.FirstChild(); child != nil; {
+		nextChild := child.NextSibling()
+
+		if divider := branchDivider(child); divider != nil {
+			next := ast.NewConditionalStart()
+			next.Else = branch
+
+			if err := setBranchScript(next, divider); err != nil {
+				return node, err
+			}
+
+			parent.InsertBefore(parent, nodeToReplace, branch.End)
+			parent.InsertBefore(parent, nodeToReplace, next)
+			node.RemoveChild(node, child)
+
+			branch = next
+		} else {
+			parent.InsertBefore(parent, nodeToReplace, child)
+		}
+
+		child = nextChild
+	}
+
+	parent.InsertBefore(parent, nodeToReplace, branch.End)
+	parent.RemoveChild(parent, nodeToReplace)
+
+	return start, nil
+}
+
+// Returns the <r else/> or <r else-if="..."/> tag when the node is one, either alone or as the only child of a paragraph.
+func branchDivider(node goldast.Node) *ast.RundownBlock {
+	if para, ok := node.(*goldast.Paragraph); ok && para.ChildCount() == 1 {
+		node = para.FirstChild()
+	}
+
+	if tag, ok := node.(*ast.RundownBlock); ok && tag.HasAttr("else", "else-if") && !tag.HasChildren() {
+		return tag
+	}
+
+	return nil
+}
+
+// Finds the branch before an else or else-if heading, which is the conditional heading at the same level ending just before it.
+func previousHeadingBranch(h *goldast.Heading) *ast.ConditionalStart {
+	for prev := h.PreviousSibling(); prev != nil; prev = prev.PreviousSibling() {
+		end, ok := prev.(*ast.ConditionalEnd)
+		if !ok {
+			return nil
+		}
+
+		if heading, ok := end.Start.NextSibling().(*goldast.Heading); ok && heading.Level == h.Level {
+			return end.Start
+		}
+	}
+
+	return nil
+}
+
+// Sets the branch's script from if or else-if. A plain else has no script.
+func setBranchScript(branch *ast.ConditionalStart, node *ast.RundownBlock) error {
+	if node.HasAttr("else-if") {
+		if node.HasAttr("if", "else") {
+			return fmt.Errorf("else-if can't be combined with if or else")
+		}
+
+		branch.SetIfScript(node.GetAttr("else-if").String)
+	} else if node.HasAttr("else") {
+		if node.HasAttr("if") {
+			return fmt.Errorf("else can't be combined with if, use else-if instead")
+		}
+	} else {
+		branch.SetIfScript(node.GetAttr("if").String)
+	}
+
+	if node.HasAttr("if", "else-if") && !branch.HasIfScript() {
+		return fmt.Errorf("if and else-if need a condition")
+	}
+
+	return nil
+}
+
+func elseAttr(node *ast.RundownBlock) string {
+	if node.HasAttr("else-if") {
+		return "else-if"
+	}
+
+	return "else"
+}
+
+// Option names in requires and conflicts are separated by commas or spaces.
+func isOptionListSeparator(r rune) bool {
+	return r == ',' || unicode.IsSpace(r)
+}
+
+// Checks the options named by requires and conflicts exist in the section.
+func checkOptionReferences(section *ast.SectionPointer) []error {
+	errs := []error{}
+
+	for _, option := range section.Options {
+		for _, name := range append(append([]string{}, option.Requires...), option.Conflicts...) {
+			if section.GetOption(name) == nil {
+				errs = append(errs, fmt.Errorf("option `%s` in section %s refers to unknown option `%s`", option.OptionName, section.SectionName, name))
+			}
+		}
+	}
+
+	return errs
+}
+
+// Applies the pattern, min, max and separator attributes of an option to its type.
+func applyOptionConstraints(optionType ast.OptionType, node *ast.RundownBlock) error {
+	if pattern := node.GetAttr("pattern"); pattern.Valid {
+		stringType, ok := optionType.(*ast.TypeString)
+		if !ok {
+			return fmt.Errorf("pattern can only be used with string options")
+		}
+
+		matcher, err := regexp.Compile(pattern.String)
+		if err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
+
+		stringType.Pattern = matcher
+	}
+
+	if node.HasAttr("min", "max") {
+		ranged, ok := optionType.(ast.OptionTypeRanged)
+		if !ok {
+			return fmt.Errorf("min and max can only be used with int and float options")
+		}
+
+		if err := ranged.SetRange(node.GetAttr("min"), node.GetAttr("max")); err != nil {
+			return err
+		}
+	}
+
+	if separator := node.GetAttr("separator"); separator.Valid {
+		list, ok := optionType.(*ast.TypeList)
+		if !ok {
+			return fmt.Errorf("separator can only be used with list options")
+		}
+
+		if separator.String == "" {
+			return fmt.Errorf("separator can't be empty")
+		}
+
+		list.Separator = separator.String
+	}
+
+	return nil
+}
+
+var foreachVariable = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Reads the parallel and fail-fast attributes. A bare parallel runs every iteration at once.
+func applyForeachPolicy(foreach *ast.Foreach, node *ast.RundownBlock) error {
+	foreach.FailFast = node.HasAttr("fail-fast")
+
+	if !node.HasAttr("parallel") {
+		return nil
+	}
+
+	parallel := node.GetAttr("parallel").String
+	if parallel == "" {
+		foreach.Parallel = -1
+		return nil
+	}
+
+	limit, err := strconv.Atoi(parallel)
+	if err != nil || limit < 1 {
+		return fmt.Errorf("parallel must be a number greater than zero, got %q", parallel)
+	}
+
+	foreach.Parallel = limit
+
+	return nil
+}
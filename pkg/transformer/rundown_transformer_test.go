@@ -2,6 +2,8 @@ package transformer
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -718,3 +720,980 @@ func TestIgnoreBlock(t *testing.T) {
 	assert.Nil(t, target)
 
 }
+
+func TestDefaultSection(t *testing.T) {
+	source := []byte(`
+<r default-section="build"/>
+
+# Build <r section="build"/>
+`)
+
+	gm := goldmark.New(
+		goldmark.WithParserOptions(
+			parser.WithASTTransformers(util.PrioritizedValue{
+				Value:    NewRundownASTTransformer(),
+				Priority: 0,
+			}),
+		),
+	)
+
+	doc := gm.Parser().Parse(text.NewReader(source))
+
+	doc.Dump(source, 0)
+
+	target := doc.FirstChild()
+
+	if assert.NotNil(t, target) && assert.Equal(t, "DefaultSection", target.Kind().String()) {
+		assert.Equal(t, "build", target.(*ast.DefaultSection).SectionName)
+		assert.Equal(t, "build", ast.GetDefaultSection(doc))
+	}
+}
+
+func TestFrontMatterDefaults(t *testing.T) {
+	source := []byte(`---
+shell: bash
+stdout: true
+spinner: hidden
+---
+
+<r spinner="Runs"/>
+
+` + "```" + `
+echo Hi
+` + "```" + `
+
+<r stdout="false" with="zsh"/>
+
+` + "```" + `
+echo Bye
+` + "```" + `
+`)
+
+	frontMatter, source, err := ast.ParseFrontMatter(source)
+	require.NoError(t, err)
+	assert.Equal(t, "bash", frontMatter.Shell)
+
+	transformer := NewRundownASTTransformer()
+	transformer.FrontMatter = frontMatter
+
+	gm := goldmark.New(
+		goldmark.WithParserOptions(
+			parser.WithASTTransformers(util.PrioritizedValue{
+				Value:    transformer,
+				Priority: 0,
+			}),
+		),
+	)
+
+	doc := gm.Parser().Parse(text.NewReader(source))
+
+	doc.Dump(source, 0)
+
+	target := doc.FirstChild()
+
+	if assert.NotNil(t, target) && assert.Equal(t, "ExecutionBlock", target.Kind().String()) {
+		eb := target.(*ast.ExecutionBlock)
+		assert.Equal(t, "bash", eb.Language)
+		assert.Equal(t, "bash", eb.With)
+		assert.True(t, eb.ShowStdout)
+		assert.Equal(t, ast.SpinnerModeVisible, eb.SpinnerMode)
+	}
+
+	target = target.NextSibling()
+
+	if assert.NotNil(t, target) && assert.Equal(t, "ExecutionBlock", target.Kind().String()) {
+		eb := target.(*ast.ExecutionBlock)
+		assert.Equal(t, "zsh", eb.With)
+		assert.False(t, eb.ShowStdout)
+		assert.Equal(t, ast.SpinnerModeHidden, eb.SpinnerMode)
+	}
+}
+
+func TestFrontMatterShebang(t *testing.T) {
+	for _, source := range []string{"#!/usr/bin/env rundown --ask\n# Title\n", "#!/usr/bin/env rundown --ask\n---\nshell: bash\n---\n# Title\n"} {
+		_, blanked, err := ast.ParseFrontMatter([]byte(source))
+		require.NoError(t, err)
+
+		assert.Len(t, blanked, len(source))
+		assert.True(t, strings.HasPrefix(string(blanked), strings.Repeat(" ", len("#!/usr/bin/env rundown --ask"))+"\n"))
+		assert.True(t, strings.HasSuffix(string(blanked), "\n# Title\n"))
+	}
+}
+
+func TestRequires(t *testing.T) {
+	source := []byte(`
+<r requires="docker>=24, kubectl, jq = 1.6"/>
+
+# Build <r section="build"/>
+
+<r requires="go"/>
+`)
+
+	gm := goldmark.New(
+		goldmark.WithParserOptions(
+			parser.WithASTTransformers(util.PrioritizedValue{
+				Value:    NewRundownASTTransformer(),
+				Priority: 0,
+			}),
+		),
+	)
+
+	doc := gm.Parser().Parse(text.NewReader(source))
+
+	doc.Dump(source, 0)
+
+	target := doc.FirstChild()
+
+	if assert.NotNil(t, target) && assert.Equal(t, "Requires", target.Kind().String()) {
+		assert.Equal(t, []ast.Requirement{
+			{Name: "docker", Operator: ">=", Version: "24"},
+			{Name: "kubectl"},
+			{Name: "jq", Operator: "=", Version: "1.6"},
+		}, target.(*ast.Requires).Requirements)
+	}
+
+	pruned := ast.PruneDocumentToSection(doc, "build")
+
+	names := []string{}
+	for _, r := range ast.GetRequirements(pruned) {
+		names = append(names, r.String())
+	}
+
+	assert.Equal(t, []string{"docker>=24", "kubectl", "jq=1.6", "go"}, names)
+
+	// The document keeps its requirements.
+	if assert.Equal(t, "Requires", doc.FirstChild().Kind().String()) {
+		assert.Len(t, doc.FirstChild().(*ast.Requires).Requirements, 3)
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	source := []byte(`
+<r confirm="Drop the database?" expect="$OPT_ENV">No undo!</r>
+
+# Reset <r section="reset" dangerous/>
+
+Resetting.
+`)
+
+	gm := goldmark.New(
+		goldmark.WithParserOptions(
+			parser.WithASTTransformers(util.PrioritizedValue{
+				Value:    NewRundownASTTransformer(),
+				Priority: 0,
+			}),
+		),
+	)
+
+	doc := gm.Parser().Parse(text.NewReader(source))
+
+	doc.Dump(source, 0)
+
+	confirm, ok := doc.FirstChild().(*ast.Confirm)
+	if assert.True(t, ok, "Expected a Confirm node") {
+		assert.Equal(t, "Drop the database?", confirm.Prompt)
+		assert.Equal(t, "$OPT_ENV", confirm.Expect)
+		assert.Equal(t, "No undo!", string(confirm.Text(source)))
+	}
+
+	section, ok := confirm.NextSibling().(*ast.SectionPointer)
+	if assert.True(t, ok, "Expected a SectionPointer node") {
+		dangerous, ok := section.StartNode.NextSibling().(*ast.Confirm)
+		if assert.True(t, ok, "Expected the section to start with a Confirm node") {
+			assert.Equal(t, "Reset is marked as dangerous. Continue?", dangerous.Prompt)
+		}
+	}
+}
+
+func TestOnFailureStream(t *testing.T) {
+	source := []byte(`
+<r on-failure="boom">Either</r>
+
+<r on-failure="boom" stream="stdout">Stdout</r>
+`)
+
+	gm := goldmark.New(
+		goldmark.WithParserOptions(
+			parser.WithASTTransformers(util.PrioritizedValue{
+				Value:    NewRundownASTTransformer(),
+				Priority: 0,
+			}),
+		),
+	)
+
+	doc := gm.Parser().Parse(text.NewReader(source))
+
+	doc.Dump(source, 0)
+
+	either, ok := doc.FirstChild().(*ast.OnFailure)
+	if assert.True(t, ok, "Expected an OnFailure node") {
+		assert.Equal(t, ast.FailureStreamBoth, either.Stream)
+		assert.True(t, either.MatchesOutput([]byte(""), []byte("boom")))
+		assert.True(t, either.MatchesOutput([]byte("boom"), []byte("")))
+	}
+
+	stdout, ok := either.NextSibling().(*ast.OnFailure)
+	if assert.True(t, ok, "Expected an OnFailure node") {
+		assert.Equal(t, ast.FailureStreamStdout, stdout.Stream)
+		assert.False(t, stdout.MatchesOutput([]byte(""), []byte("boom")))
+		assert.True(t, stdout.MatchesOutput([]byte("boom"), []byte("")))
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	source := []byte(`
+<r finally>
+
+<r spinner="Tearing down..."/>
+
+~~~ bash
+true
+~~~
+
+</r>
+
+<r on-cancel>Cancelled.</r>
+`)
+
+	gm := goldmark.New(
+		goldmark.WithParserOptions(
+			parser.WithASTTransformers(util.PrioritizedValue{
+				Value:    NewRundownASTTransformer(),
+				Priority: 0,
+			}),
+		),
+	)
+
+	doc := gm.Parser().Parse(text.NewReader(source))
+
+	doc.Dump(source, 0)
+
+	cleanups := []*ast.Cleanup{}
+	for child := doc.FirstChild(); child != nil; child = child.NextSibling() {
+		if cleanup, ok := child.(*ast.Cleanup); ok {
+			cleanups = append(cleanups, cleanup)
+		}
+	}
+
+	if assert.Len(t, cleanups, 2) {
+		assert.False(t, cleanups[0].OnCancel)
+		assert.Equal(t, "ExecutionBlock", cleanups[0].FirstChild().Kind().String())
+
+		assert.True(t, cleanups[1].OnCancel)
+	}
+}
+
+func TestExecutionBlockSourcePosition(t *testing.T) {
+	source := []byte(`---
+shell: bash
+---
+
+# Deploy
+
+<r spinner="Deploying..."/>
+
+` + "```" + `
+echo Deploying
+` + "```" + `
+`)
+
+	frontMatter, source, err := ast.ParseFrontMatter(source)
+	require.NoError(t, err)
+
+	transformer := NewRundownASTTransformer()
+	transformer.FrontMatter = frontMatter
+	transformer.Filename = "docs/deploy.md"
+
+	gm := goldmark.New(
+		goldmark.WithParserOptions(
+			parser.WithASTTransformers(util.PrioritizedValue{
+				Value:    transformer,
+				Priority: 0,
+			}),
+		),
+	)
+
+	doc := gm.Parser().Parse(text.NewReader(source))
+
+	doc.Dump(source, 0)
+
+	target := doc.FirstChild().NextSibling()
+
+	if assert.NotNil(t, target) && assert.Equal(t, "ExecutionBlock", target.Kind().String()) {
+		eb := target.(*ast.ExecutionBlock)
+		assert.Equal(t, ast.SourcePosition{File: "docs/deploy.md", Line: 10}, eb.Source)
+		assert.Equal(t, "docs/deploy.md:10", eb.Source.String())
+	}
+}
+
+func TestArtifacts(t *testing.T) {
+	source := []byte(`
+<r artifacts="dist/*.tgz"/>
+
+~~~ bash
+make package
+~~~
+
+<r artifacts="dist/[.tgz"/>
+
+~~~ bash
+make package
+~~~
+`)
+
+	transformer := NewRundownASTTransformer()
+
+	gm := goldmark.New(
+		goldmark.WithParserOptions(
+			parser.WithASTTransformers(util.PrioritizedValue{
+				Value:    transformer,
+				Priority: 0,
+			}),
+		),
+	)
+
+	doc := gm.Parser().Parse(text.NewReader(source))
+
+	doc.Dump(source, 0)
+
+	eb, ok := doc.FirstChild().(*ast.ExecutionBlock)
+	if assert.True(t, ok, "Expected an ExecutionBlock node") {
+		assert.Equal(t, "dist/*.tgz", eb.Artifacts)
+	}
+
+	if assert.Len(t, transformer.Errors, 1) {
+		assert.Contains(t, transformer.Errors[0].Error(), "invalid artifacts pattern")
+	}
+}
+
+func TestWorkingDirectory(t *testing.T) {
+	source := []byte(`---
+cwd: services
+---
+
+<r spinner="Document"/>
+
+~~~ bash
+pwd
+~~~
+
+## Api <r section="api" cwd="services/api"/>
+
+<r spinner="Section"/>
+
+~~~ bash
+pwd
+~~~
+
+### Tests <r section="api:tests"/>
+
+<r spinner="Block" cwd="$INVOCATION_DIR"/>
+
+~~~ bash
+pwd
+~~~
+
+<r spinner="Nested"/>
+
+~~~ bash
+pwd
+~~~
+`)
+
+	frontMatter, source, err := ast.ParseFrontMatter(source)
+	require.NoError(t, err)
+
+	transformer := NewRundownASTTransformer()
+	transformer.FrontMatter = frontMatter
+
+	gm := goldmark.New(
+		goldmark.WithParserOptions(
+			parser.WithASTTransformers(util.PrioritizedValue{
+				Value:    transformer,
+				Priority: 0,
+			}),
+		),
+	)
+
+	doc := gm.Parser().Parse(text.NewReader(source))
+
+	doc.Dump(source, 0)
+
+	cwds := map[string]string{}
+
+	goldast.Walk(doc, func(node goldast.Node, entering bool) (goldast.WalkStatus, error) {
+		if eb, ok := node.(*ast.ExecutionBlock); ok && entering {
+			cwds[eb.SpinnerName] = eb.Cwd
+		}
+
+		return goldast.WalkContinue, nil
+	})
+
+	assert.Equal(t, map[string]string{
+		"Document": "services",
+		"Section":  "services/api",
+		"Block":    "$INVOCATION_DIR",
+		"Nested":   "services/api",
+	}, cwds)
+}
+
+func TestForeach(t *testing.T) {
+	source := []byte(`
+<r foreach="REGION" in="us-east-1, eu-west-1" parallel="2" fail-fast>
+
+<r spinner="Deploying to $REGION..."/>
+
+~~~ bash
+true
+~~~
+
+</r>
+
+<r invoke="deploy" matrix="region=a,b; tier=web,worker" parallel/>
+
+## Deploy <r section="deploy"/>
+
+<r opt="region" type="string" required/>
+`)
+
+	gm := goldmark.New(
+		goldmark.WithParserOptions(
+			parser.WithASTTransformers(util.PrioritizedValue{
+				Value:    NewRundownASTTransformer(),
+				Priority: 0,
+			}),
+		),
+	)
+
+	doc := gm.Parser().Parse(text.NewReader(source))
+
+	doc.Dump(source, 0)
+
+	foreaches := []*ast.Foreach{}
+
+	goldast.Walk(doc, func(node goldast.Node, entering bool) (goldast.WalkStatus, error) {
+		if foreach, ok := node.(*ast.Foreach); ok && entering {
+			foreaches = append(foreaches, foreach)
+		}
+
+		return goldast.WalkContinue, nil
+	})
+
+	require.Len(t, foreaches, 2)
+
+	loop := foreaches[0]
+	assert.Equal(t, []ast.ForeachAxis{{Name: "REGION", Values: "us-east-1, eu-west-1"}}, loop.Axes)
+	assert.False(t, loop.Matrix)
+	assert.Equal(t, 2, loop.Parallel)
+	assert.True(t, loop.FailFast)
+	assert.Equal(t, "ExecutionBlock", loop.FirstChild().Kind().String())
+
+	iterations := loop.Iterations(map[string]string{})
+	if assert.Len(t, iterations, 2) {
+		assert.Equal(t, "REGION=us-east-1", iterations[0].Label)
+		assert.Equal(t, map[string]string{"REGION": "eu-west-1"}, iterations[1].Env)
+		assert.Equal(t, "ExecutionBlock", iterations[1].FirstChild().Kind().String())
+	}
+
+	matrix := foreaches[1]
+	assert.Equal(t, []ast.ForeachAxis{{Name: "region", Values: "a,b"}, {Name: "tier", Values: "web,worker"}}, matrix.Axes)
+	assert.True(t, matrix.Matrix)
+	assert.Equal(t, -1, matrix.Parallel)
+
+	invoke, ok := matrix.FirstChild().(*ast.InvokeBlock)
+	require.True(t, ok)
+	assert.Equal(t, map[string]string{"invoke": "deploy"}, invoke.Args)
+
+	iterations = matrix.Iterations(map[string]string{})
+	if assert.Len(t, iterations, 4) {
+		assert.Equal(t, "region=a, tier=worker", iterations[1].Label)
+		assert.Empty(t, iterations[1].Env)
+		assert.Equal(t, map[string]string{"invoke": "deploy", "region": "a", "tier": "worker"}, iterations[1].FirstChild().(*ast.InvokeBlock).Args)
+		assert.Equal(t, map[string]string{"invoke": "deploy"}, invoke.Args)
+	}
+}
+
+func TestForeachErrors(t *testing.T) {
+	for _, source := range []string{
+		`<r foreach="NOT-VALID" in="a,b"/>`,
+		`<r foreach="X" in="a,b" parallel="none"/>`,
+		`<r invoke="deploy" matrix="region"/>`,
+	} {
+		transformer := NewRundownASTTransformer()
+
+		gm := goldmark.New(
+			goldmark.WithParserOptions(
+				parser.WithASTTransformers(util.PrioritizedValue{
+					Value:    transformer,
+					Priority: 0,
+				}),
+			),
+		)
+
+		gm.Parser().Parse(text.NewReader([]byte(source)))
+
+		assert.Len(t, transformer.Errors, 1, source)
+	}
+}
+
+func TestInvalidConditions(t *testing.T) {
+	source := []byte(`
+<r stop-ok if="unknown($X)"/>
+
+<r stop-ok if="test -f go.mod"/>
+
+<r stop-ok if="$X == 'y'"/>
+`)
+
+	transformer := NewRundownASTTransformer()
+
+	gm := goldmark.New(
+		goldmark.WithParserOptions(
+			parser.WithASTTransformers(util.PrioritizedValue{
+				Value:    transformer,
+				Priority: 0,
+			}),
+		),
+	)
+
+	gm.Parser().Parse(text.NewReader(source))
+
+	if assert.Len(t, transformer.Errors, 1) {
+		assert.Contains(t, transformer.Errors[0].Error(), "unknown function unknown()")
+	}
+}
+
+func TestElseBranches(t *testing.T) {
+	source := []byte(`
+## Production <r if="$TARGET == 'prod'"/>
+
+### Nested <r if="true"/>
+
+## Staging <r else-if="$TARGET == 'staging'"/>
+
+## Other <r else/>
+
+## After
+
+<r if="$TARGET == 'prod'">
+
+Prod.
+
+<r else/>
+
+Not prod.
+
+</r>
+<r else-if="true">
+
+Never.
+
+</r>
+`)
+
+	gm := goldmark.New(
+		goldmark.WithParserOptions(
+			parser.WithASTTransformers(util.PrioritizedValue{
+				Value:    NewRundownASTTransformer(),
+				Priority: 0,
+			}),
+		),
+	)
+
+	doc := gm.Parser().Parse(text.NewReader(source))
+
+	branches := []*ast.ConditionalStart{}
+
+	goldast.Walk(doc, func(node goldast.Node, entering bool) (goldast.WalkStatus, error) {
+		if branch, ok := node.(*ast.ConditionalStart); ok && entering {
+			branches = append(branches, branch)
+		}
+
+		return goldast.WalkContinue, nil
+	})
+
+	require.Len(t, branches, 7)
+
+	production, nested, staging, other := branches[0], branches[1], branches[2], branches[3]
+	assert.Nil(t, production.Else)
+	assert.Nil(t, nested.Else)
+	assert.Equal(t, production, staging.Else)
+	assert.Equal(t, "$TARGET == 'staging'", staging.GetIfScript())
+	assert.Equal(t, staging, other.Else)
+	assert.False(t, other.HasIfScript())
+
+	prod, notProd, never := branches[4], branches[5], branches[6]
+	assert.Nil(t, prod.Else)
+	assert.Equal(t, prod, notProd.Else)
+	assert.Equal(t, notProd, never.Else)
+	assert.Equal(t, "Paragraph", prod.NextSibling().Kind().String())
+	assert.Equal(t, prod.End, notProd.PreviousSibling())
+
+	copied := goldast.NewDocument()
+	ast.CopyChildren(doc, copied)
+
+	copiedBranches := []*ast.ConditionalStart{}
+	for child := copied.FirstChild(); child != nil; child = child.NextSibling() {
+		if branch, ok := child.(*ast.ConditionalStart); ok {
+			copiedBranches = append(copiedBranches, branch)
+		}
+	}
+
+	if assert.Len(t, copiedBranches, 7) {
+		assert.Equal(t, copiedBranches[2], copiedBranches[3].Else)
+	}
+}
+
+func TestElseErrors(t *testing.T) {
+	for _, source := range []string{
+		"## A\n\n## B <r else/>\n",
+		"## A <r if=\"true\"/>\n\n### B <r else/>\n",
+		"## A <r section=\"a\" else/>\n",
+		"Text.\n\n<r else>\n\nNever.\n\n</r>\n",
+		"<r if=\"true\" else>\n\nNever.\n\n</r>\n",
+	} {
+		transformer := NewRundownASTTransformer()
+
+		gm := goldmark.New(
+			goldmark.WithParserOptions(
+				parser.WithASTTransformers(util.PrioritizedValue{
+					Value:    transformer,
+					Priority: 0,
+				}),
+			),
+		)
+
+		gm.Parser().Parse(text.NewReader([]byte(source)))
+
+		assert.NotEmpty(t, transformer.Errors, source)
+	}
+}
+
+func TestOptionTypes(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tool"), []byte("#!/bin/sh"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte(""), 0600))
+
+	source := []byte(`
+## Release <r section="release"/>
+
+<r opt="version" pattern="^v\d+\.\d+\.\d+$"/>
+<r opt="count" type="int" min="1" max="5"/>
+<r opt="ratio" type="float" max="1"/>
+<r opt="regions" type="list" separator=";"/>
+<r opt="site" type="url"/>
+<r opt="owner" type="email"/>
+<r opt="timeout" type="duration"/>
+<r opt="on" type="date"/>
+<r opt="release" type="semver"/>
+<r opt="tool" type="file:exist,executable"/>
+<r opt="out" type="dir:exist,writable"/>
+<r opt="new" type="file:not-exist"/>
+`)
+
+	gm := goldmark.New(
+		goldmark.WithParserOptions(
+			parser.WithASTTransformers(util.PrioritizedValue{
+				Value:    NewRundownASTTransformer(),
+				Priority: 0,
+			}),
+		),
+	)
+
+	doc := gm.Parser().Parse(text.NewReader(source))
+
+	section := ast.FindSectionInDocument(doc, "release")
+	require.NotNil(t, section)
+
+	parsed, err := section.ParseOptionsWithResolutionByName(map[string]string{
+		"version": "v1.2.3",
+		"count":   "5",
+		"ratio":   "0.5",
+		"regions": "us-east-1; eu-west-1 ;",
+		"site":    "https://example.com/path",
+		"owner":   "ops@example.com",
+		"timeout": "1m30s",
+		"on":      "2024-02-29",
+		"release": "v1.2.3-beta.1+build.5",
+		"tool":    filepath.Join(dir, "tool"),
+		"out":     dir,
+		"new":     filepath.Join(dir, "new.txt"),
+	}, map[string]string{})
+
+	require.NoError(t, err)
+
+	assert.Equal(t, "us-east-1;eu-west-1", parsed["OPT_REGIONS"])
+	assert.Equal(t, "2", parsed["OPT_REGIONS_COUNT"])
+	assert.Equal(t, "us-east-1", parsed["OPT_REGIONS_0"])
+	assert.Equal(t, "eu-west-1", parsed["OPT_REGIONS_1"])
+	assert.Equal(t, "90", parsed["OPT_TIMEOUT_SECONDS"])
+	assert.Equal(t, filepath.Join(dir, "tool"), parsed["OPT_TOOL"])
+
+	for name, value := range map[string]string{
+		"version": "1.2.3",
+		"count":   "6",
+		"ratio":   "1.5",
+		"regions": " ; ",
+		"site":    "example.com",
+		"owner":   "ops",
+		"timeout": "90",
+		"on":      "2024-02-30",
+		"release": "1.2",
+		"tool":    filepath.Join(dir, "notes.txt"),
+		"out":     filepath.Join(dir, "notes.txt"),
+		"new":     filepath.Join(dir, "tool"),
+	} {
+		_, err := section.ParseOptionsWithResolutionByName(map[string]string{name: value}, map[string]string{})
+		assert.Error(t, err, name)
+	}
+
+	// Options which weren't given aren't checked.
+	parsed, err = section.ParseOptionsWithResolutionByName(map[string]string{"count": ""}, map[string]string{})
+	require.NoError(t, err)
+	assert.Equal(t, "", parsed["OPT_COUNT"])
+}
+
+func TestInvalidOptionConstraints(t *testing.T) {
+	for _, source := range []string{
+		`<r opt="count" type="int" pattern="\d+"/>`,
+		`<r opt="name" type="string" min="1"/>`,
+		`<r opt="count" type="int" min="one"/>`,
+		`<r opt="name" type="string" separator=";"/>`,
+		`<r opt="name" pattern="("/>`,
+		`<r opt="file" type="file:exist,not-exist"/>`,
+		`<r opt="file" type="file:big"/>`,
+		`<r opt="count" type="int" max="5" default="10"/>`,
+	} {
+		transformer := NewRundownASTTransformer()
+
+		gm := goldmark.New(
+			goldmark.WithParserOptions(
+				parser.WithASTTransformers(util.PrioritizedValue{
+					Value:    transformer,
+					Priority: 0,
+				}),
+			),
+		)
+
+		gm.Parser().Parse(text.NewReader([]byte(source)))
+
+		assert.NotEmpty(t, transformer.Errors, source)
+	}
+}
+
+func TestOptionValuesFrom(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "namespaces.txt"), []byte("default\n\nkube-system\n"), 0600))
+
+	source := []byte(`
+## Deploy <r section="deploy"/>
+
+<r opt="namespace" values-from="echo listed >> runs.txt; cat namespaces.txt"/>
+<r opt="branch" type="enum-from:printf 'main\nrelease'" default="main"/>
+`)
+
+	transformer := NewRundownASTTransformer()
+	transformer.Filename = filepath.Join(dir, "RUNDOWN.md")
+
+	gm := goldmark.New(
+		goldmark.WithParserOptions(
+			parser.WithASTTransformers(util.PrioritizedValue{
+				Value:    transformer,
+				Priority: 0,
+			}),
+		),
+	)
+
+	doc := gm.Parser().Parse(text.NewReader(source))
+	require.Empty(t, transformer.Errors)
+
+	section := ast.FindSectionInDocument(doc, "deploy")
+	require.NotNil(t, section)
+
+	// Nothing runs until the values are needed.
+	assert.NoFileExists(t, filepath.Join(dir, "runs.txt"))
+
+	namespace := section.GetOption("namespace").OptionType.(*ast.TypeEnumFrom)
+	assert.Equal(t, dir, namespace.Dir)
+
+	values, err := namespace.Values()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"default", "kube-system"}, values)
+
+	assert.NoError(t, namespace.Validate("kube-system"))
+	assert.Error(t, namespace.Validate("production"))
+
+	runs, err := os.ReadFile(filepath.Join(dir, "runs.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "listed\n", string(runs), "the command runs once")
+
+	_, err = section.ParseOptionsWithResolutionByName(map[string]string{"branch": "release"}, map[string]string{})
+	assert.NoError(t, err)
+
+	_, err = section.ParseOptionsWithResolutionByName(map[string]string{"branch": "develop"}, map[string]string{})
+	assert.Error(t, err)
+
+	failing := &ast.TypeEnumFrom{Command: "echo broken >&2; exit 1"}
+	if err := failing.Validate("x"); assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "broken")
+	}
+}
+
+func parseValuesFrom(t *testing.T, filename string, source string) *ast.TypeEnumFrom {
+	frontMatter, body, err := ast.ParseFrontMatter([]byte(source))
+	require.NoError(t, err)
+
+	transformer := NewRundownASTTransformer()
+	transformer.Filename = filename
+	transformer.FrontMatter = frontMatter
+
+	gm := goldmark.New(
+		goldmark.WithParserOptions(
+			parser.WithASTTransformers(util.PrioritizedValue{
+				Value:    transformer,
+				Priority: 0,
+			}),
+		),
+	)
+
+	doc := gm.Parser().Parse(text.NewReader(body))
+	require.Empty(t, transformer.Errors)
+
+	section := ast.FindSectionInDocument(doc, "deploy")
+	require.NotNil(t, section)
+
+	return section.GetOption("namespace").OptionType.(*ast.TypeEnumFrom)
+}
+
+func TestOptionValuesFromCache(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "config"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config", "namespaces.txt"), []byte("default\n"), 0600))
+
+	filename := filepath.Join(dir, "RUNDOWN.md")
+	source := `---
+env:
+  PREFIX: team
+cwd: config
+---
+
+## Deploy <r section="deploy"/>
+
+<r opt="namespace" values-from="echo listed >> ../runs.txt; sed s/^/$PREFIX-/ namespaces.txt"/>
+`
+
+	values, err := parseValuesFrom(t, filename, source).Values()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"team-default"}, values, "runs in the document's cwd with its environment")
+
+	// Each completion is a new process, with the document loaded again.
+	values, err = parseValuesFrom(t, filename, source).Values()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"team-default"}, values)
+
+	runs, err := os.ReadFile(filepath.Join(dir, "runs.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "listed\n", string(runs), "later runs use the cache")
+
+	// Values added since they were cached are found by running the command again.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config", "namespaces.txt"), []byte("default\nstaging\n"), 0600))
+	assert.NoError(t, parseValuesFrom(t, filename, source).Validate("team-staging"))
+
+	runs, err = os.ReadFile(filepath.Join(dir, "runs.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "listed\nlisted\n", string(runs))
+
+	// A different command for the option isn't given the old values.
+	values, err = parseValuesFrom(t, filename, strings.Replace(source, "sed", "echo other; sed", 1)).Values()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"other", "team-default", "team-staging"}, values)
+}
+
+func TestOptionRelationships(t *testing.T) {
+	source := []byte(`
+## Database <r section="db"/>
+
+<r opt="mode" type="enum:backup|restore" default="backup"/>
+<r opt="from" required-if="$OPT_MODE == 'restore'" group="Restore"/>
+<r opt="drop" type="bool" requires="from" group="Restore"/>
+<r opt="full" type="bool" conflicts="incremental, since" group="Backup"/>
+<r opt="incremental" type="bool" group="Backup"/>
+<r opt="since" type="string"/>
+`)
+
+	transformer := NewRundownASTTransformer()
+
+	gm := goldmark.New(
+		goldmark.WithParserOptions(
+			parser.WithASTTransformers(util.PrioritizedValue{
+				Value:    transformer,
+				Priority: 0,
+			}),
+		),
+	)
+
+	doc := gm.Parser().Parse(text.NewReader(source))
+	require.Empty(t, transformer.Errors)
+
+	section := ast.FindSectionInDocument(doc, "db")
+	require.NotNil(t, section)
+
+	full := section.GetOption("full")
+	assert.Equal(t, []string{"incremental", "since"}, full.Conflicts)
+	assert.Equal(t, "Backup", full.Group)
+	assert.Equal(t, []string{"from"}, section.GetOption("drop").Requires)
+
+	parsed, err := section.ParseOptionsWithResolutionByName(map[string]string{}, map[string]string{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"OPT_MODE": "backup", "OPT_FROM": "", "OPT_DROP": "false", "OPT_FULL": "false", "OPT_INCREMENTAL": "false", "OPT_SINCE": ""}, parsed)
+
+	for _, test := range []struct {
+		options map[string]string
+		err     string
+	}{
+		{map[string]string{"mode": "restore"}, "from: a value is required when $OPT_MODE == 'restore'"},
+		{map[string]string{"mode": "restore", "from": "monday"}, ""},
+		{map[string]string{"drop": "true"}, "drop: requires from"},
+		{map[string]string{"drop": "false"}, ""},
+		{map[string]string{"drop": "true", "from": "monday"}, ""},
+		{map[string]string{"full": "true", "incremental": "true"}, "full: can't be used with incremental"},
+		{map[string]string{"full": "true", "since": "monday"}, "full: can't be used with since"},
+		{map[string]string{"full": "true", "incremental": "false"}, ""},
+	} {
+		_, err := section.ParseOptionsWithResolutionByName(test.options, map[string]string{})
+
+		if test.err == "" {
+			assert.NoError(t, err, test.options)
+		} else if assert.Error(t, err, test.options) {
+			assert.Equal(t, test.err, err.Error())
+		}
+	}
+}
+
+func TestInvalidOptionRelationships(t *testing.T) {
+	for _, source := range []string{
+		"## A <r section=\"a\"/>\n\n<r opt=\"x\" requires=\"missing\"/>\n",
+		"## A <r section=\"a\"/>\n\n<r opt=\"x\" conflicts=\"missing\"/>\n",
+		"## A <r section=\"a\"/>\n\n<r opt=\"x\" required-if=\"test -f go.mod\"/>\n",
+		"## A <r section=\"a\"/>\n\n<r opt=\"x\" required-if=\"nope($OPT_Y)\"/>\n",
+	} {
+		transformer := NewRundownASTTransformer()
+
+		gm := goldmark.New(
+			goldmark.WithParserOptions(
+				parser.WithASTTransformers(util.PrioritizedValue{
+					Value:    transformer,
+					Priority: 0,
+				}),
+			),
+		)
+
+		gm.Parser().Parse(text.NewReader([]byte(source)))
+
+		assert.NotEmpty(t, transformer.Errors, source)
+	}
+}
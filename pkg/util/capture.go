@@ -2,8 +2,10 @@ package util
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"os"
+	"strings"
 
 	"github.com/yuin/goldmark/ast"
 )
@@ -13,16 +15,43 @@ func CaptureStdout(f func()) string {
 	r, w, _ := os.Pipe()
 	os.Stdout = w
 
+	// Read while f writes, as f blocks once it's written more than the pipe holds.
+	var buf bytes.Buffer
+	read := make(chan struct{})
+	go func() {
+		io.Copy(&buf, r)
+		close(read)
+	}()
+
 	f()
 
 	w.Close()
 	os.Stdout = old
 
-	var buf bytes.Buffer
-	io.Copy(&buf, r)
+	<-read
 	return buf.String()
 }
 
 func DumpNode(node ast.Node, source []byte) string {
 	return CaptureStdout(func() { node.Dump(source, 1) })
 }
+
+// Writes the node's tree to w, one kind per line, along with the text of text nodes. Unlike the node's own Dump,
+// this doesn't go through os.Stdout, so it's safe while other goroutines are writing output.
+func WriteDump(w io.Writer, node ast.Node, source []byte) {
+	writeDump(w, node, source, 0)
+}
+
+func writeDump(w io.Writer, node ast.Node, source []byte, level int) {
+	fmt.Fprintf(w, "%s%s", strings.Repeat("    ", level), node.Kind())
+
+	if text, ok := node.(*ast.Text); ok {
+		fmt.Fprintf(w, " %q", text.Segment.Value(source))
+	}
+
+	fmt.Fprintln(w)
+
+	for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+		writeDump(w, child, source, level+1)
+	}
+}
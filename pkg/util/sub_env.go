@@ -44,3 +44,54 @@ func SubEnv(environment map[string]string, source string) string {
 
 	return source
 }
+
+// Returns the source as a double quoted shell word, which substitutes the same variables SubEnv does.
+// Unset variables are substituted with an empty string, so the word is safe to use with set -u.
+func SubEnvShellWord(source string) string {
+	result := strings.Builder{}
+	result.WriteString(`"`)
+
+	for source != "" {
+		loc := VariableDetection.FindStringSubmatchIndex(source)
+		if loc == nil {
+			result.WriteString(escapeShellDoubleQuoted(source))
+			break
+		}
+
+		result.WriteString(escapeShellDoubleQuoted(source[:loc[0]]))
+
+		group := func(i int) string {
+			if loc[i*2] < 0 {
+				return ""
+			}
+
+			return source[loc[i*2]:loc[i*2+1]]
+		}
+
+		switch modifier := group(4); {
+		case group(1) != "":
+			result.WriteString("${" + group(1) + "-}")
+		case modifier == "-", modifier == ":-":
+			result.WriteString("${" + group(2) + ":-" + escapeShellDoubleQuoted(group(5)) + "}")
+		case modifier == "+", modifier == ":+":
+			result.WriteString("${" + group(2) + ":+" + escapeShellDoubleQuoted(group(5)) + "}")
+		default:
+			result.WriteString("${" + group(2) + "-}")
+		}
+
+		source = source[loc[1]:]
+	}
+
+	result.WriteString(`"`)
+
+	return result.String()
+}
+
+func escapeShellDoubleQuoted(source string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`", "$", `\$`).Replace(source)
+}
+
+// Returns the source as a single quoted shell word, which is never substituted.
+func ShellQuote(source string) string {
+	return "'" + strings.ReplaceAll(source, "'", `'\''`) + "'"
+}
@@ -25,3 +25,14 @@ func TestSubEnv(t *testing.T) {
 
 	require.Equal(t, "Test , when blah... is `:-f` or more", SubEnv(env, "Test ${UNSET+something}, when $CI_BRANCH... is `$NOPE:-f` or ${CIRCLE_BRANCH:-blah}"))
 }
+
+func TestSubEnvShellWord(t *testing.T) {
+	require.Equal(t, `"Test ${CI_BRANCH-}"`, SubEnvShellWord("Test $CI_BRANCH"))
+	require.Equal(t, `"Test ${CI_BRANCH-}!"`, SubEnvShellWord("Test ${CI_BRANCH}!"))
+	require.Equal(t, `"Test ${NOPE:-some thing}"`, SubEnvShellWord("Test ${NOPE-some thing}"))
+	require.Equal(t, `"Test ${CI:+yes}"`, SubEnvShellWord("Test ${CI+yes}"))
+	require.Equal(t, `"Test ${CI-}"`, SubEnvShellWord("Test ${CI%%something}"))
+	require.Equal(t, "\"Say \\\"hi\\\" \\`now\\` \\$(date) \\\\ \\$\"", SubEnvShellWord("Say \"hi\" `now` $(date) \\ $"))
+
+	require.Equal(t, `'it'\''s'`, ShellQuote("it's"))
+}
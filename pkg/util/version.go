@@ -0,0 +1,42 @@
+package util
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var versionMatcher = regexp.MustCompile(`\d+(\.\d+)*`)
+
+// Extracts the first version number (i.e. 1.2.3) from the given text, or an empty string if there isn't one.
+func FindVersion(text string) string {
+	return versionMatcher.FindString(text)
+}
+
+// Compares two dotted version numbers, ignoring any leading "v" and trailing pre-release text.
+// Returns -1 when a is older than b, 0 when they're the same, and 1 when a is newer.
+func CompareVersions(a string, b string) int {
+	aParts := strings.Split(FindVersion(a), ".")
+	bParts := strings.Split(FindVersion(b), ".")
+
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var aNum, bNum int
+
+		if i < len(aParts) {
+			aNum, _ = strconv.Atoi(aParts[i])
+		}
+
+		if i < len(bParts) {
+			bNum, _ = strconv.Atoi(bParts[i])
+		}
+
+		switch {
+		case aNum < bNum:
+			return -1
+		case aNum > bNum:
+			return 1
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,20 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindVersion(t *testing.T) {
+	require.Equal(t, "24.0.7", FindVersion("Docker version 24.0.7, build afdd53b"))
+	require.Equal(t, "3", FindVersion("v3"))
+	require.Equal(t, "", FindVersion("no version here"))
+}
+
+func TestCompareVersions(t *testing.T) {
+	require.Equal(t, 0, CompareVersions("1.2.0", "1.2"))
+	require.Equal(t, -1, CompareVersions("1.2", "1.10"))
+	require.Equal(t, 1, CompareVersions("2.0", "1.99.9"))
+	require.Equal(t, 1, CompareVersions("v1.0.1", "1.0"))
+}